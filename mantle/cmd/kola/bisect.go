@@ -0,0 +1,334 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	cosa "github.com/coreos/coreos-assembler/pkg/builds"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/reporters"
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+	"github.com/coreos/coreos-assembler/mantle/kola"
+	"github.com/coreos/coreos-assembler/mantle/util"
+)
+
+var (
+	cmdBisect = &cobra.Command{
+		Use:     "bisect --good BUILD --bad BUILD [glob pattern...]",
+		Short:   "Find the first build in which kola tests fail",
+		PreRunE: preRun,
+		RunE:    runBisect,
+		Long: `
+Binary search the builds in builds.json between a good and a bad build to
+find the first build in which the given tests fail.
+
+Each candidate build is tested by running "kola run --build" with the
+global options given to kola bisect. Additional options for kola run can be
+passed with --run-arg. A build is bad if any of the tests failed. Once the
+first bad build is found, the packages which changed between it and the
+build before it are printed.
+`,
+
+		SilenceUsage: true,
+	}
+
+	bisectGood    string
+	bisectBad     string
+	bisectRunArgs []string
+)
+
+// bisectSkipFlags are the global flags which are not passed down to kola run
+// because kola bisect sets them per build or they would clobber each other.
+var bisectSkipFlags = map[string]bool{
+	"build":        true,
+	"workdir":      true,
+	"output-dir":   true,
+	"tapfile":      true,
+	"junit-file":   true,
+	"event-stream": true,
+}
+
+func init() {
+	cmdBisect.Flags().StringVar(&bisectGood, "good", "", "build ID in which the tests pass")
+	cmdBisect.Flags().StringVar(&bisectBad, "bad", "", "build ID in which the tests fail")
+	cmdBisect.Flags().StringArrayVar(&bisectRunArgs, "run-arg", nil, "additional argument for kola run (repeatable)")
+	root.AddCommand(cmdBisect)
+}
+
+func runBisect(cmd *cobra.Command, args []string) error {
+	if bisectGood == "" || bisectBad == "" {
+		return fmt.Errorf("both --good and --bad are required")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("no test patterns specified")
+	}
+	if kola.Options.CosaWorkdir == "" || kola.Options.CosaWorkdir == "none" {
+		kola.Options.CosaWorkdir = "."
+	}
+	workdir := kola.Options.CosaWorkdir
+	arch := kola.Options.CosaBuildArch
+
+	var err error
+	for _, id := range []*string{&bisectGood, &bisectBad} {
+		if strings.HasPrefix(*id, "-") {
+			*id, err = util.GetRelativeLocalBuildId(workdir, *id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	candidates, err := getBisectBuilds(workdir, arch, bisectGood, bisectBad)
+	if err != nil {
+		return err
+	}
+
+	outputDir, err = kola.SetupOutputDir(outputDir, "bisect-"+kolaPlatform)
+	if err != nil {
+		return err
+	}
+	runArgs, err := getBisectRunArgs()
+	if err != nil {
+		return err
+	}
+
+	// candidates[good] is known to pass and candidates[bad] to fail
+	good, bad := 0, len(candidates)-1
+	fmt.Printf("Bisecting %d builds between %s and %s\n", bad-good-1, bisectGood, bisectBad)
+	for bad-good > 1 {
+		mid := (good + bad) / 2
+		id := candidates[mid]
+		fmt.Printf("Testing build %s (runs left: about %d)\n", id, bisectSteps(bad-good-1))
+		failed, err := runBisectBuild(id, filepath.Join(outputDir, id), runArgs, args)
+		if err != nil {
+			return errors.Wrapf(err, "testing build %s", id)
+		}
+		if failed {
+			fmt.Printf("Build %s is bad\n", id)
+			bad = mid
+		} else {
+			fmt.Printf("Build %s is good\n", id)
+			good = mid
+		}
+	}
+
+	fmt.Printf("\nFirst bad build: %s\n", candidates[bad])
+	fmt.Printf("Last good build: %s\n", candidates[good])
+	fmt.Printf("Test output: %s\n\n", outputDir)
+
+	diff, err := getPackageDiff(workdir, arch, candidates[bad])
+	if err != nil {
+		return errors.Wrapf(err, "reading package diff")
+	}
+	if len(diff) == 0 {
+		fmt.Println("No package changes")
+	} else {
+		fmt.Println("Package changes:")
+		for _, line := range diff {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	return nil
+}
+
+// getBisectBuilds returns the IDs of the builds for arch from good to bad,
+// oldest first.
+func getBisectBuilds(workdir, arch, good, bad string) ([]string, error) {
+	builds, err := cosa.GetBuilds(filepath.Join(workdir, "builds"))
+	if err != nil {
+		return nil, err
+	}
+	// builds.json lists the newest build first
+	var ids []string
+	for i := len(builds.Builds) - 1; i >= 0; i-- {
+		for _, a := range builds.Builds[i].Arches {
+			if a == arch {
+				ids = append(ids, builds.Builds[i].ID)
+				break
+			}
+		}
+	}
+
+	goodIdx, badIdx := -1, -1
+	for i, id := range ids {
+		switch id {
+		case good:
+			goodIdx = i
+		case bad:
+			badIdx = i
+		}
+	}
+	if goodIdx < 0 {
+		return nil, fmt.Errorf("good build %s not found for %s in builds.json", good, arch)
+	}
+	if badIdx < 0 {
+		return nil, fmt.Errorf("bad build %s not found for %s in builds.json", bad, arch)
+	}
+	if goodIdx >= badIdx {
+		return nil, fmt.Errorf("good build %s is not older than bad build %s", good, bad)
+	}
+
+	candidates := ids[goodIdx : badIdx+1]
+	for _, id := range candidates {
+		if _, err := util.GetLocalBuild(workdir, id, arch); err != nil {
+			return nil, errors.Wrapf(err, "reading build %s", id)
+		}
+	}
+	return candidates, nil
+}
+
+// getBisectRunArgs returns the arguments passed to kola run for every build.
+func getBisectRunArgs() ([]string, error) {
+	workdir, err := filepath.Abs(kola.Options.CosaWorkdir)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"run", "--workdir", workdir}
+	root.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed || bisectSkipFlags[f.Name] {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range sv.GetSlice() {
+				args = append(args, fmt.Sprintf("--%s=%s", f.Name, v))
+			}
+		} else {
+			args = append(args, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+		}
+	})
+	return append(args, bisectRunArgs...), nil
+}
+
+// runBisectBuild runs the tests on build id and returns whether any failed.
+func runBisectBuild(id, dir string, runArgs, patterns []string) (bool, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return false, err
+	}
+	args := append([]string{}, runArgs...)
+	// the runs of bisect would skew the flake history of the builds
+	args = append(args, "--build", id, "--output-dir", dir, "--flake-history=false", "--")
+	args = append(args, patterns...)
+
+	c := exec.Command(self, args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	runErr := c.Run()
+
+	// kola run exits non-zero when tests fail, so go by the report
+	report, err := reporters.DeserialiseReport(filepath.Join(dir, "reports", "report.json"))
+	if err != nil {
+		if runErr != nil {
+			return false, runErr
+		}
+		return false, err
+	}
+	ran := false
+	for _, test := range report.Tests {
+		switch test.Result {
+		case testresult.Fail:
+			return true, nil
+		case testresult.Pass, testresult.Warn:
+			// failures of tests with warn: true don't make the build bad
+			ran = true
+		}
+	}
+	if !ran {
+		return false, fmt.Errorf("no tests ran")
+	}
+	return false, nil
+}
+
+// bisectSteps returns the number of test runs needed to bisect n untested
+// builds.
+func bisectSteps(n int) int {
+	steps := 0
+	for ; n > 0; n /= 2 {
+		steps++
+	}
+	return steps
+}
+
+// pkgdiffChange is the last element of the entries of the pkgdiff of
+// meta.json, which are [name, kind, change] like those of rpm-ostree db diff
+// --format=json. The packages are [name, evr, arch].
+type pkgdiffChange struct {
+	PreviousPackage []string
+	NewPackage      []string
+}
+
+const (
+	pkgdiffAdded = iota
+	pkgdiffRemoved
+	pkgdiffUpgraded
+	pkgdiffDowngraded
+)
+
+// getPackageDiff returns the packages added, removed and changed in a build
+// since the build before it, from the pkgdiff of its meta.json, sorted by
+// name.
+func getPackageDiff(workdir, arch, id string) ([]string, error) {
+	build, err := util.GetLocalBuild(workdir, id, arch)
+	if err != nil {
+		return nil, err
+	}
+	if build.Meta.PkgdiffBetweenBuilds == nil {
+		return nil, fmt.Errorf("no pkgdiff in meta.json of %s", id)
+	}
+
+	var diff []string
+	for _, item := range build.Meta.PkgdiffBetweenBuilds {
+		// decode the untyped entry
+		buf, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var entry []json.RawMessage
+		var name string
+		var kind int
+		var change pkgdiffChange
+		if err := json.Unmarshal(buf, &entry); err != nil || len(entry) != 3 {
+			return nil, fmt.Errorf("invalid pkgdiff entry %s in %s", buf, id)
+		}
+		if json.Unmarshal(entry[0], &name) != nil || json.Unmarshal(entry[1], &kind) != nil || json.Unmarshal(entry[2], &change) != nil {
+			return nil, fmt.Errorf("invalid pkgdiff entry %s in %s", buf, id)
+		}
+		switch {
+		case kind == pkgdiffAdded && len(change.NewPackage) == 3:
+			diff = append(diff, fmt.Sprintf("+ %s %s.%s", name, change.NewPackage[1], change.NewPackage[2]))
+		case kind == pkgdiffRemoved && len(change.PreviousPackage) == 3:
+			diff = append(diff, fmt.Sprintf("- %s %s.%s", name, change.PreviousPackage[1], change.PreviousPackage[2]))
+		case (kind == pkgdiffUpgraded || kind == pkgdiffDowngraded) && len(change.PreviousPackage) == 3 && len(change.NewPackage) == 3:
+			diff = append(diff, fmt.Sprintf("~ %s %s.%s -> %s.%s", name,
+				change.PreviousPackage[1], change.PreviousPackage[2], change.NewPackage[1], change.NewPackage[2]))
+		default:
+			return nil, fmt.Errorf("invalid pkgdiff entry %s in %s", buf, id)
+		}
+	}
+	// by name, after the +, - or ~
+	sort.Slice(diff, func(i, j int) bool { return diff[i][2:] < diff[j][2:] })
+	return diff, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+var (
+	cmdBootPerf = &cobra.Command{
+		RunE:    runBootPerf,
+		PreRunE: preRun,
+		Use:     "bootperf [--baseline FILE | --baseline-image IMAGE] [--save FILE]",
+		Short:   "Check boot time for regressions",
+		Long: `
+Boot several instances of the build under test one after the other and
+collect their systemd-analyze timings: the firmware, loader, kernel,
+initrd and userspace phases, the activation time of each unit, and the
+critical chain.
+
+The timings are compared with a baseline, either stored by a previous run
+with --save, or collected by booting another QEMU image with
+--baseline-image, alternating with the build under test. A timing
+regressed if it is significantly slower according to a one-sided
+Mann-Whitney U test, and its median grew by more than both --threshold
+and --min-delta. The comparison is written as JSON, and the command fails
+if any phase regressed; units which regressed are only reported.
+
+Boots which end degraded are rejected. At least 5 boots of each build are
+needed for a regression to be significant at the default --alpha.
+`,
+		SilenceUsage: true,
+	}
+
+	bootPerfCount         int
+	bootPerfBaseline      string
+	bootPerfBaselineImage string
+	bootPerfSave          string
+	bootPerfOutput        string
+	bootPerfAlpha         float64
+	bootPerfThreshold     float64
+	bootPerfMinDelta      float64
+)
+
+func init() {
+	cmdBootPerf.Flags().IntVar(&bootPerfCount, "count", 10, "number of boots of each build")
+	cmdBootPerf.Flags().StringVar(&bootPerfBaseline, "baseline", "", "compare with the timings saved in this file")
+	cmdBootPerf.Flags().StringVar(&bootPerfBaselineImage, "baseline-image", "", "compare with boots of this QEMU image")
+	cmdBootPerf.Flags().StringVar(&bootPerfSave, "save", "", "save the timings of the build under test to this file, for use as a baseline")
+	cmdBootPerf.Flags().StringVar(&bootPerfOutput, "output", "", "write the comparison to this file instead of stdout")
+	cmdBootPerf.Flags().Float64Var(&bootPerfAlpha, "alpha", 0.05, "family-wise significance level of regressions of the phases, and separately of the units")
+	cmdBootPerf.Flags().Float64Var(&bootPerfThreshold, "threshold", 0.1, "minimum slowdown reported, relative to the baseline")
+	cmdBootPerf.Flags().Float64Var(&bootPerfMinDelta, "min-delta", 0.5, "minimum slowdown reported, in seconds")
+	root.AddCommand(cmdBootPerf)
+}
+
+func runBootPerf(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("No args accepted")
+	}
+	if bootPerfBaseline != "" && bootPerfBaselineImage != "" {
+		return fmt.Errorf("Cannot use both --baseline and --baseline-image")
+	}
+	if bootPerfBaseline == "" && bootPerfBaselineImage == "" && bootPerfSave == "" {
+		return fmt.Errorf("Need --baseline, --baseline-image or --save")
+	}
+	if bootPerfBaselineImage != "" && kolaPlatform != "qemu" {
+		return fmt.Errorf("--baseline-image is only supported on qemu")
+	}
+	if bootPerfCount < 2 {
+		return fmt.Errorf("--count must be at least 2")
+	}
+
+	var baseline kola.BootPerfSamples
+	baselineCount := bootPerfCount
+	if bootPerfBaseline != "" {
+		if err := readJSONFile(bootPerfBaseline, &baseline); err != nil {
+			return fmt.Errorf("reading baseline: %w", err)
+		}
+		baselineCount = len(baseline.Samples)
+	} else if bootPerfBaselineImage != "" {
+		baseline.Build = bootPerfBaselineImage
+	}
+	// Don't boot for nothing if nothing can regress
+	if bootPerfBaseline != "" || bootPerfBaselineImage != "" {
+		if err := kola.CheckBootPerfCounts(baselineCount, bootPerfCount, bootPerfAlpha); err != nil {
+			return err
+		}
+	}
+	var current kola.BootPerfSamples
+	if kola.CosaBuild != nil {
+		current.Build = kola.CosaBuild.Meta.BuildID
+	}
+
+	var err error
+	outputDir, err = kola.SetupOutputDir(outputDir, kolaPlatform)
+	if err != nil {
+		return err
+	}
+	flight, err := kola.NewFlight(kolaPlatform)
+	if err != nil {
+		return err
+	}
+	defer flight.Destroy()
+	cluster, err := flight.NewCluster(&platform.RuntimeConfig{
+		OutputDir: outputDir,
+	})
+	if err != nil {
+		return err
+	}
+	defer cluster.Destroy()
+
+	// Boot the builds alternately so that load changes on the host affect
+	// both alike.
+	for i := 0; i < bootPerfCount; i++ {
+		if bootPerfBaselineImage != "" {
+			times, err := bootAndCollect(cluster, platform.MachineOptions{OverrideBackingFile: bootPerfBaselineImage})
+			if err != nil {
+				return fmt.Errorf("booting baseline: %w", err)
+			}
+			baseline.Samples = append(baseline.Samples, *times)
+		}
+		times, err := bootAndCollect(cluster, platform.MachineOptions{})
+		if err != nil {
+			return err
+		}
+		current.Samples = append(current.Samples, *times)
+		fmt.Fprintf(os.Stderr, "Boot %d/%d: %.3fs\n", i+1, bootPerfCount, times.Phases["total"])
+	}
+
+	if bootPerfSave != "" {
+		if err := writeBootPerfJSON(bootPerfSave, current); err != nil {
+			return err
+		}
+	}
+	if len(baseline.Samples) == 0 {
+		return nil
+	}
+
+	report, err := kola.CompareBootTimes(baseline, current, bootPerfAlpha, bootPerfThreshold, bootPerfMinDelta)
+	if err != nil {
+		return err
+	}
+	if bootPerfOutput != "" {
+		err = writeBootPerfJSON(bootPerfOutput, report)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Regressions) > 0 {
+		return fmt.Errorf("boot time regressed: %s", strings.Join(report.Regressions, ", "))
+	}
+	return nil
+}
+
+// bootAndCollect boots a machine and returns its boot timings.
+func bootAndCollect(cluster platform.Cluster, options platform.MachineOptions) (*kola.BootTimes, error) {
+	m, err := cluster.NewMachineWithOptions(nil, options)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Destroy()
+	return kola.CollectBootTimes(m)
+}
+
+func writeBootPerfJSON(path string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(buf, '\n'), 0644)
+}
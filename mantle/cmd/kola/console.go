@@ -55,6 +55,10 @@ func runCheckConsole(cmd *cobra.Command, args []string) error {
 		args = append(args, "-")
 	}
 
+	if err := kola.ParseConsoleChecksYaml(kolaPlatform); err != nil {
+		return err
+	}
+
 	errorcount := 0
 	for _, arg := range args {
 		var console []byte
@@ -0,0 +1,102 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+)
+
+var (
+	cmdExplain = &cobra.Command{
+		Use:     "explain [glob pattern...]",
+		Short:   "Explain why kola tests would run or be skipped",
+		PreRunE: preRun,
+		RunE:    runExplain,
+		Long: `
+Show, for every registered test, whether "kola run" with the same patterns
+and options would run it, and why.
+
+Tests are skipped because of their tags, platforms, architectures,
+distributions or firmwares, the --no-net option, kola-denylist.yaml and
+--denylist-test, or --sharding. For tests which run, the reasons why their
+failures would only be warnings are shown as well.
+`,
+
+		SilenceUsage: true,
+	}
+
+	explainJSON bool
+	explainAll  bool
+)
+
+func init() {
+	cmdExplain.Flags().StringArrayVarP(&runExternals, "exttest", "E", nil, "Externally defined tests (will be found in DIR/tests/kola)")
+	cmdExplain.Flags().BoolVar(&explainJSON, "json", false, "format output in JSON")
+	cmdExplain.Flags().BoolVar(&explainAll, "all", false, "also show tests which don't match the patterns")
+	root.AddCommand(cmdExplain)
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	patterns := args
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+	if err := registerExternals(); err != nil {
+		return err
+	}
+
+	explanations, err := kola.ExplainTests(patterns, kolaPlatform)
+	if err != nil {
+		return err
+	}
+	if !explainAll {
+		var matching []kola.TestExplanation
+		for _, e := range explanations {
+			if match, _ := kola.MatchesPatterns(e.Name, patterns); match || e.Run {
+				matching = append(matching, e)
+			}
+		}
+		explanations = matching
+	}
+
+	if explainJSON {
+		out, err := json.MarshalIndent(explanations, "", "\t")
+		if err != nil {
+			return errors.Wrapf(err, "marshalling test explanations")
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "Test Name\tResult\tReason")
+	for _, e := range explanations {
+		if e.Run {
+			fmt.Fprintf(w, "%s\trun\t%s\n", e.Name, strings.Join(e.Notes, "; "))
+		} else {
+			fmt.Fprintf(w, "%s\tskip\t%s\n", e.Name, e.Reason)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+)
+
+var (
+	cmdFlakes = &cobra.Command{
+		Use:     "flakes",
+		Short:   "Show the flake history of kola tests",
+		PreRunE: preRun,
+		RunE:    runFlakes,
+		Long: `
+Show the flake history recorded by previous kola runs.
+
+Every "kola run" of a cosa build records the results of the tests it ran,
+and of their rerun with --rerun, in the flake database, per test, platform
+and architecture. Runs with --multiply and "kola rerun" are not recorded. A
+run counts as a flake if the test failed and then passed on rerun, or if it
+failed once in between two passing runs. The flake score is the fraction of recorded runs
+which were flakes.
+`,
+
+		SilenceUsage: true,
+	}
+
+	flakesJSON     bool
+	flakesMinScore float64
+)
+
+func init() {
+	cmdFlakes.Flags().StringVar(&kola.FlakeDBPath, "flake-db", "", "flake history database (default: cache/kola-flakes.json in the workdir)")
+	cmdFlakes.Flags().BoolVar(&flakesJSON, "json", false, "format output in JSON")
+	cmdFlakes.Flags().Float64Var(&flakesMinScore, "min-score", 0, "only show tests with at least this flake score")
+	root.AddCommand(cmdFlakes)
+}
+
+func runFlakes(cmd *cobra.Command, args []string) error {
+	path := kola.GetFlakeDBPath()
+	if path == "" {
+		return fmt.Errorf("no flake database; specify --flake-db or --workdir")
+	}
+	history, err := kola.LoadFlakeHistory(path)
+	if err != nil {
+		return err
+	}
+
+	var records []*kola.FlakeRecord
+	for _, record := range history.Tests {
+		if cmd.Flags().Changed("platform") && record.Platform != kolaPlatform {
+			continue
+		}
+		if record.Score() < flakesMinScore {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	if flakesJSON {
+		out, err := json.MarshalIndent(records, "", "\t")
+		if err != nil {
+			return errors.Wrapf(err, "marshalling flake history")
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "Test Name\tPlatform\tArch\tRuns\tFailures\tFlakes\tScore\tLast Run")
+	for _, record := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%.2f\t%s\n", record.Test, record.Platform, record.Arch,
+			len(record.Runs), record.Failures(), record.Flakes(), record.Score(), record.LastRun().Format("2006-01-02"))
+	}
+	return w.Flush()
+}
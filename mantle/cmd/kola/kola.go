@@ -17,7 +17,6 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -34,10 +33,12 @@ import (
 	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
 	"github.com/coreos/coreos-assembler/mantle/kola"
 	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/kola/tests/upgrade"
 	"github.com/coreos/coreos-assembler/mantle/system"
 	"github.com/coreos/coreos-assembler/mantle/util"
 	cosa "github.com/coreos/coreos-assembler/pkg/builds"
 	coreosarch "github.com/coreos/stream-metadata-go/arch"
+	"github.com/coreos/stream-metadata-go/release"
 
 	// register OS test suite
 	_ "github.com/coreos/coreos-assembler/mantle/kola/registry"
@@ -121,10 +122,15 @@ This can be useful for e.g. serving locally built OSTree repos to qemu.
 	qemuImageDir       string
 	qemuImageDirIsTemp bool
 
+	upgradeGraph        int
+	upgradeGraphIndex   string
+	upgradeGraphUpdates string
+
 	runExternals      []string
 	runMultiply       int
 	runRerunFlag      bool
 	allowRerunSuccess string
+	runFlakeHistory   bool
 
 	nonexclusiveWrapperMatch = regexp.MustCompile(`^non-exclusive-test-bucket-[0-9]$`)
 )
@@ -135,6 +141,14 @@ func init() {
 	cmdRun.Flags().IntVar(&runMultiply, "multiply", 0, "Run the provided tests N times (useful to find race conditions)")
 	cmdRun.Flags().BoolVar(&runRerunFlag, "rerun", false, "re-run failed tests once (succeeds if tests pass on rerun)")
 	cmdRun.Flags().StringVar(&allowRerunSuccess, "allow-rerun-success", "", "Deprecated: this option is no longer supported and has no effect")
+	cmdRun.Flags().StringVar(&kola.FlakeDBPath, "flake-db", "", "flake history database (default: cache/kola-flakes.json in the workdir)")
+	cmdRun.Flags().BoolVar(&runFlakeHistory, "flake-history", true, "record the results in the flake history database")
+	cmdRun.Flags().StringSliceVar(&kola.Workers, "worker", nil, "dispatch tests over SSH to these hosts instead of running them locally")
+	cmdRun.Flags().IntVar(&kola.WorkerParallelism, "worker-parallel", 1, "number of tests to run at once on each worker")
+	cmdRun.Flags().StringVar(&kola.WorkerWorkdir, "worker-workdir", "", "coreos-assembler working directory on the workers (default: login directory)")
+	cmdRun.Flags().StringVar(&kola.WorkerKola, "worker-kola", "kola", "command used to run kola on the workers")
+	cmdRun.Flags().StringArrayVar(&kola.WorkerArgs, "worker-arg", nil, "additional argument for kola run on the workers")
+	cmdRun.Flags().Float64Var(&kola.FlakeQuarantineThreshold, "flake-quarantine-threshold", 0, "treat failures of tests with at least this flake score as warnings (0 disables)")
 
 	root.AddCommand(cmdList)
 	cmdList.Flags().StringArrayVarP(&runExternals, "exttest", "E", nil, "Externally defined tests in directory")
@@ -148,6 +162,9 @@ func init() {
 	root.AddCommand(cmdRunUpgrade)
 	cmdRunUpgrade.Flags().BoolVar(&findParentImage, "find-parent-image", false, "automatically find parent image if not provided -- note on qemu, this will download the image")
 	cmdRunUpgrade.Flags().StringVar(&qemuImageDir, "qemu-image-dir", "", "directory in which to cache QEMU images if --fetch-parent-image is enabled")
+	cmdRunUpgrade.Flags().IntVar(&upgradeGraph, "upgrade-graph", 0, "test upgrades from the N latest releases of the stream and across its latest barrier -- note on qemu, this will download the images")
+	cmdRunUpgrade.Flags().StringVar(&upgradeGraphIndex, "upgrade-graph-index", "", "path to the releases.json of the stream for --upgrade-graph, instead of fetching it")
+	cmdRunUpgrade.Flags().StringVar(&upgradeGraphUpdates, "upgrade-graph-updates", "", "path to the updates metadata of the stream for --upgrade-graph, instead of fetching it")
 	cmdRunUpgrade.Flags().BoolVar(&runRerunFlag, "rerun", false, "re-run failed tests once (succeeds if tests pass on rerun)")
 	cmdRunUpgrade.Flags().StringVar(&allowRerunSuccess, "allow-rerun-success", "", "Deprecated: this option is no longer supported and has no effect")
 
@@ -207,7 +224,10 @@ func runRun(cmd *cobra.Command, args []string) error {
 		patterns = args
 	}
 
-	return kolaRunPatterns(patterns, runRerunFlag)
+	// Only record plain runs of the tests: with --multiply, the copies of
+	// a test would be recorded as separate tests.
+	recordFlakes := runFlakeHistory && runMultiply <= 1
+	return kolaRunPatterns(patterns, runRerunFlag, recordFlakes)
 }
 
 func runRerun(cmd *cobra.Command, args []string) error {
@@ -233,10 +253,11 @@ func runRerun(cmd *cobra.Command, args []string) error {
 			}
 		}
 	}
-	return kolaRunPatterns(patterns, false)
+	// the failures were recorded by the run being rerun
+	return kolaRunPatterns(patterns, false, false)
 }
 
-func kolaRunPatterns(patterns []string, rerun bool) error {
+func kolaRunPatterns(patterns []string, rerun, recordFlakes bool) error {
 	var err error
 	outputDir, err = kola.SetupOutputDir(outputDir, kolaPlatform)
 	if err != nil {
@@ -254,6 +275,12 @@ func kolaRunPatterns(patterns []string, rerun bool) error {
 		return err
 	}
 
+	if recordFlakes {
+		if err := kola.RecordFlakeHistory(outputDir, kolaPlatform); err != nil {
+			plog.Warningf("Failed to record flake history: %v", err)
+		}
+	}
+
 	return runErr
 }
 
@@ -492,10 +519,11 @@ func (i item) String() string {
 func runHTTPServer(cmd *cobra.Command, args []string) error {
 	directory := "."
 
-	http.Handle("/", http.FileServer(http.Dir(directory)))
+	srv := kola.NewHTTPServer(directory)
+	srv.Addr = fmt.Sprintf(":%d", httpPort)
 
 	fmt.Fprintf(os.Stdout, "Serving HTTP on port: %d\n", httpPort)
-	return http.ListenAndServe(fmt.Sprintf(":%d", httpPort), nil)
+	return srv.ListenAndServe()
 }
 
 func preRunUpgrade(cmd *cobra.Command, args []string) error {
@@ -521,6 +549,14 @@ func preRunUpgrade(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if upgradeGraph > 0 {
+		err = syncUpgradeGraphOptions()
+		if err != nil {
+			runUpgradeCleanup()
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -538,21 +574,9 @@ func syncFindParentImageOptions() error {
 	skipSignature := false
 	switch kola.Options.Distribution {
 	case "fcos":
-		var stream string
-		if kola.CosaBuild.Meta.CosaImportedOciImage {
-			s, ok := kola.CosaBuild.Meta.OciLabels["com.coreos.stream"]
-			if !ok {
-				return errors.New("label 'com.coreos.stream' not found in build metadata")
-			}
-			stream = string(s)
-		} else {
-			// We still support this legacy hack for now, but eventually when we're
-			// confident that latest kola doesn't need to handle builds built with the
-			// legacy path, we can remove this code.
-			if kola.CosaBuild.Meta.BuildRef == "" {
-				return errors.New("no ref in build metadata")
-			}
-			stream = filepath.Base(kola.CosaBuild.Meta.BuildRef)
+		stream, err := getFcosStream()
+		if err != nil {
+			return err
 		}
 		parentBaseURL, err = getParentFcosBuildBase(stream)
 		if err != nil {
@@ -587,11 +611,8 @@ func syncFindParentImageOptions() error {
 	// based on its cosa build metadata
 	switch kolaPlatform {
 	case "qemu":
-		if qemuImageDir == "" {
-			if qemuImageDir, err = os.MkdirTemp("/var/tmp", "kola-run-upgrade"); err != nil {
-				return err
-			}
-			qemuImageDirIsTemp = true
+		if err := ensureQemuImageDir(); err != nil {
+			return err
 		}
 		if parentCosaBuild.BuildArtifacts.Qemu == nil {
 			return fmt.Errorf("No QEMU in parent meta.json")
@@ -620,6 +641,157 @@ func syncFindParentImageOptions() error {
 	return nil
 }
 
+// getFcosStream returns the stream of the build under test.
+func getFcosStream() (string, error) {
+	if kola.CosaBuild.Meta.CosaImportedOciImage {
+		s, ok := kola.CosaBuild.Meta.OciLabels["com.coreos.stream"]
+		if !ok {
+			return "", errors.New("label 'com.coreos.stream' not found in build metadata")
+		}
+		return string(s), nil
+	}
+	// We still support this legacy hack for now, but eventually when we're
+	// confident that latest kola doesn't need to handle builds built with the
+	// legacy path, we can remove this code.
+	if kola.CosaBuild.Meta.BuildRef == "" {
+		return "", errors.New("no ref in build metadata")
+	}
+	return filepath.Base(kola.CosaBuild.Meta.BuildRef), nil
+}
+
+// ensureQemuImageDir creates a temporary --qemu-image-dir if none was given.
+func ensureQemuImageDir() error {
+	if qemuImageDir != "" {
+		return nil
+	}
+	var err error
+	if qemuImageDir, err = os.MkdirTemp("/var/tmp", "kola-run-upgrade"); err != nil {
+		return err
+	}
+	qemuImageDirIsTemp = true
+	return nil
+}
+
+// syncUpgradeGraphOptions handles --upgrade-graph: it picks the starting
+// points from the release index and the barriers from the updates metadata
+// of the stream, downloads the QEMU images of the starting points and the
+// OCI archives of the barrier releases, and registers a test for each
+// starting point.
+func syncUpgradeGraphOptions() error {
+	if kola.Options.Distribution != "fcos" {
+		return fmt.Errorf("--upgrade-graph not yet supported for distro %s", kola.Options.Distribution)
+	}
+	if kolaPlatform != "qemu" {
+		return fmt.Errorf("--upgrade-graph not yet supported for platform %s", kolaPlatform)
+	}
+	if kola.CosaBuild.Meta.BuildArtifacts.Ostree.Path == "" {
+		return errors.New("no ostree OCI archive in build metadata")
+	}
+	stream, err := getFcosStream()
+	if err != nil {
+		return err
+	}
+
+	var index *release.Index
+	if upgradeGraphIndex != "" {
+		err = readJSONFile(upgradeGraphIndex, &index)
+	} else {
+		index, err = fcos.FetchAndParseCanonicalReleaseIndex(stream)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting release index of stream %s", stream)
+	}
+	var updates *fcos.Updates
+	if upgradeGraphUpdates != "" {
+		err = readJSONFile(upgradeGraphUpdates, &updates)
+	} else {
+		updates, err = fcos.FetchAndParseCanonicalUpdates(stream)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting updates metadata of stream %s", stream)
+	}
+
+	barriers := make(map[string]bool)
+	for _, r := range updates.Releases {
+		if r.Metadata.Barrier != nil {
+			barriers[r.Version] = true
+		}
+	}
+	// the releases for our architecture older than the build under test
+	var releases []string
+	for _, r := range index.Releases {
+		if r.Version == kola.CosaBuild.Meta.OstreeVersion {
+			break
+		}
+		for _, entry := range r.OciImages {
+			if entry.Architecture == kola.Options.CosaBuildArch {
+				releases = append(releases, r.Version)
+				break
+			}
+		}
+	}
+	paths := kola.PlanUpgradePaths(releases, barriers, upgradeGraph)
+	if len(paths) == 0 {
+		return fmt.Errorf("no releases on stream %s for %s", stream, kola.Options.CosaBuildArch)
+	}
+
+	if err := ensureQemuImageDir(); err != nil {
+		return err
+	}
+	archives := make(map[string]string)
+	for i := range paths {
+		path := &paths[i]
+		baseURL := fcos.GetCosaBuildURL(stream, path.Start, kola.Options.CosaBuildArch)
+		build, err := cosa.FetchAndParseBuild(baseURL + "meta.json")
+		if err != nil {
+			return err
+		}
+		if build.BuildArtifacts.Qemu == nil {
+			return fmt.Errorf("No QEMU in meta.json of %s", path.Start)
+		}
+		qcowLocal := filepath.Join(qemuImageDir, build.BuildArtifacts.Qemu.Path)
+		path.DiskImage, err = util.DownloadImageAndDecompress(baseURL+build.BuildArtifacts.Qemu.Path, qcowLocal, false)
+		if err != nil {
+			return err
+		}
+
+		for j := range path.Hops {
+			hop := &path.Hops[j]
+			if archive, ok := archives[hop.Version]; ok {
+				hop.OciArchive = archive
+				continue
+			}
+			baseURL := fcos.GetCosaBuildURL(stream, hop.Version, kola.Options.CosaBuildArch)
+			build, err := cosa.FetchAndParseBuild(baseURL + "meta.json")
+			if err != nil {
+				return err
+			}
+			if build.BuildArtifacts.Ostree.Path == "" {
+				return fmt.Errorf("No ostree OCI archive in meta.json of %s", hop.Version)
+			}
+			archiveLocal := filepath.Join(qemuImageDir, build.BuildArtifacts.Ostree.Path)
+			hop.OciArchive, err = util.DownloadImageAndDecompress(baseURL+build.BuildArtifacts.Ostree.Path, archiveLocal, false)
+			if err != nil {
+				return err
+			}
+			archives[hop.Version] = hop.OciArchive
+		}
+		plog.Noticef("Testing upgrade from %s through %d barrier release(s)", path.Start, len(path.Hops))
+	}
+
+	upgrade.RegisterGraphTests(paths)
+	return nil
+}
+
+func readJSONFile(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(v)
+}
+
 // Returns the URL to a parent build that can be used as a base for upgrade
 // testing.
 func getParentFcosBuildBase(stream string) (string, error) {
@@ -680,7 +852,10 @@ func runRunUpgrade(cmd *cobra.Command, args []string) error {
 	}
 
 	var patterns []string
-	if len(args) == 0 {
+	if len(args) == 0 && upgradeGraph > 0 && !findParentImage {
+		// the other upgrade tests start from the parent image
+		patterns = []string{"fcos.upgrade.graph.*"}
+	} else if len(args) == 0 {
 		patterns = []string{"*"} // run all tests by default
 	} else {
 		patterns = args
@@ -0,0 +1,88 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+)
+
+var (
+	cmdLintExt = &cobra.Command{
+		Use:   "lint-ext DIR...",
+		Short: "Check externally defined tests without running them",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runLintExt,
+		Long: `
+Find the tests in DIR/tests/kola the same way "kola run -E DIR" does and
+check their metadata from kola.json and "## kola:" blocks for problems,
+without booting any machines.
+
+Problems include unknown architectures, platforms and distributions,
+conflicts on exclusive tests or with unknown tests, machine options on
+non-exclusive tests, malformed creation dates, invalid configs, files
+missing their executable bit, dangling data symlinks, and duplicate test
+names. The command fails if any problem other than a warning is found.
+`,
+
+		SilenceUsage: true,
+	}
+
+	lintJSON bool
+)
+
+func init() {
+	cmdLintExt.Flags().BoolVar(&lintJSON, "json", false, "format output in JSON")
+	root.AddCommand(cmdLintExt)
+}
+
+func runLintExt(cmd *cobra.Command, args []string) error {
+	var problems []kola.LintProblem
+	for _, dir := range args {
+		found, err := kola.LintExternalTests(dir, kolaPlatforms, kolaDistros)
+		if err != nil {
+			return errors.Wrapf(err, "linting %s", dir)
+		}
+		problems = append(problems, found...)
+	}
+
+	if lintJSON {
+		out, err := json.MarshalIndent(problems, "", "\t")
+		if err != nil {
+			return errors.Wrapf(err, "marshalling lint problems")
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+	}
+
+	errs := 0
+	for _, p := range problems {
+		if !p.Warning {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("found %d problems in external tests", errs)
+	}
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -70,6 +71,11 @@ var (
 
 	sshCommand string
 
+	agentCommand string
+	agentTimeout time.Duration
+
+	consoleScript string
+
 	additionalNics int
 
 	netboot    string
@@ -104,6 +110,9 @@ func init() {
 	cmdQemuExec.Flags().StringVarP(&consoleFile, "console-to-file", "", "", "Filepath in which to save serial console logs")
 	cmdQemuExec.Flags().IntVarP(&additionalNics, "additional-nics", "", 0, "Number of additional NICs to add")
 	cmdQemuExec.Flags().StringVarP(&sshCommand, "ssh-command", "x", "", "Command to execute instead of spawning a shell")
+	cmdQemuExec.Flags().StringVarP(&consoleScript, "console-script", "", "", "Path to a script of expect/send steps to run on the serial console (requires --console-to-file)")
+	cmdQemuExec.Flags().StringVarP(&agentCommand, "agent-command", "", "", "Command to execute as root through the kolet agent, without SSH")
+	cmdQemuExec.Flags().DurationVar(&agentTimeout, "agent-timeout", platform.DefaultAgentTimeout, "Timeout of --agent-command, including booting; 0 means none")
 	cmdQemuExec.Flags().StringVarP(&netboot, "netboot", "", "", "Filepath to BOOTP program (e.g. PXELINUX/GRUB binary or iPXE script")
 	cmdQemuExec.Flags().StringVarP(&netbootDir, "netboot-dir", "", "", "Directory to serve over TFTP (default: BOOTP parent dir). If specified, --netboot is relative to this dir.")
 	cmdQemuExec.Flags().StringVarP(&usernetAddr, "usernet-addr", "", "", "Guest IP network (QEMU default is '10.0.2.0/24')")
@@ -230,6 +239,15 @@ func runQemuExec(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if consoleScript != "" {
+		if devshellConsole {
+			return fmt.Errorf("Cannot use --console-script with console devshell")
+		}
+		if consoleFile == "" {
+			return fmt.Errorf("--console-script requires --console-to-file")
+		}
+	}
+
 	if ignition != "" && butane != "" {
 		return fmt.Errorf("Cannot use both --ignition and --butane")
 	}
@@ -378,8 +396,10 @@ func runQemuExec(cmd *cobra.Command, args []string) error {
 		}
 		builder.AddAdditionalNics(additionalNics)
 	}
-	builder.InheritConsole = true
+	// Keep the console out of the output of the agent command
+	builder.InheritConsole = agentCommand == ""
 	builder.ConsoleFile = consoleFile
+	builder.InteractiveConsole = consoleScript != ""
 	builder.Append(args...)
 
 	// IBM Secure Execution
@@ -398,7 +418,15 @@ func runQemuExec(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if devshell && !devshellConsole {
+	if agentCommand != "" {
+		if directIgnition {
+			return fmt.Errorf("Cannot use --agent-command with --ignition-direct")
+		}
+		ensureConfig()
+		if err := builder.EnableAgent(config); err != nil {
+			return err
+		}
+	} else if devshell && !devshellConsole && consoleScript == "" {
 		return runDevShellSSH(ctx, builder, config, sshCommand)
 	}
 	if config != nil {
@@ -417,6 +445,16 @@ func runQemuExec(cmd *cobra.Command, args []string) error {
 	}
 	defer inst.Destroy()
 
+	if consoleScript != "" {
+		if err := runConsoleScript(inst, consoleScript); err != nil {
+			return err
+		}
+	}
+
+	if agentCommand != "" {
+		return runAgentCommand(inst, agentCommand)
+	}
+
 	if propagateInitramfsFailure {
 		err := inst.WaitAll(ctx)
 		if err != nil {
@@ -426,3 +464,41 @@ func runQemuExec(cmd *cobra.Command, args []string) error {
 	}
 	return inst.Wait()
 }
+
+// runAgentCommand runs cmd through the kolet agent of inst, which works
+// even if the guest never starts sshd, and prints its output.
+func runAgentCommand(inst *platform.QemuInstance, cmd string) error {
+	agent, err := inst.Agent()
+	if err != nil {
+		return err
+	}
+	agent.Timeout = agentTimeout
+	stdout, stderr, err := agent.Exec(cmd)
+	if len(stdout) > 0 {
+		fmt.Println(string(stdout))
+	}
+	if len(stderr) > 0 {
+		fmt.Fprintln(os.Stderr, string(stderr))
+	}
+	return err
+}
+
+// runConsoleScript runs the console script at path on the serial console of
+// inst, printing the transcript.
+func runConsoleScript(inst *platform.QemuInstance, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	steps, err := platform.ParseConsoleScript(f)
+	if err != nil {
+		return errors.Wrapf(err, "parsing %s", path)
+	}
+	console, err := inst.Console()
+	if err != nil {
+		return err
+	}
+	console.Transcript = os.Stdout
+	return console.RunScript(steps)
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/coreos/coreos-assembler/mantle/cli"
 	"github.com/coreos/coreos-assembler/mantle/kola"
 	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform"
 
 	// Register any tests that we may wish to execute in kolet.
 	_ "github.com/coreos/coreos-assembler/mantle/kola/registry"
@@ -167,6 +168,13 @@ var (
 		Short: "Start an HTTP server to serve the contents of the file system",
 		RunE:  runHttpd,
 	}
+
+	cmdAgent = &cobra.Command{
+		Use:          "agent DEVICE",
+		Short:        "Serve harness requests on a virtio-serial device",
+		RunE:         runAgent,
+		SilenceUsage: true,
+	}
 )
 
 func run(cmd *cobra.Command, args []string) {
@@ -267,17 +275,14 @@ func dispatchRunExtUnit(ctx context.Context, unitname string, sdconn *systemddbu
 	}
 }
 
-func initiateReboot(mark string) error {
-	systemdjournal.Print(systemdjournal.PriInfo, "Processing reboot request")
-	res := kola.KoletResult{
-		Reboot: string(mark),
-	}
+// printKoletResult passes res to the harness
+func printKoletResult(res kola.KoletResult) error {
 	buf, err := json.Marshal(&res)
 	if err != nil {
 		return errors.Wrapf(err, "serializing KoletResult")
 	}
 	fmt.Println(string(buf))
-	systemdjournal.Print(systemdjournal.PriInfo, "Acknowledged reboot request with mark: %s", buf)
+	systemdjournal.Print(systemdjournal.PriInfo, "Passed result to the harness: %s", buf)
 	return nil
 }
 
@@ -296,20 +301,6 @@ func mkfifo(path string) error {
 	return nil
 }
 
-func initiateSoftReboot(mark string) error {
-	systemdjournal.Print(systemdjournal.PriInfo, "Processing soft-reboot request")
-	res := kola.KoletResult{
-		SoftReboot: string(mark),
-	}
-	buf, err := json.Marshal(&res)
-	if err != nil {
-		return errors.Wrapf(err, "serializing KoletResult")
-	}
-	fmt.Println(string(buf))
-	systemdjournal.Print(systemdjournal.PriInfo, "Acknowledged soft-reboot request with mark: %s", buf)
-	return nil
-}
-
 func runExtUnit(cmd *cobra.Command, args []string) error {
 	rebootOff, _ := cmd.Flags().GetBool("deny-reboots")
 	// Write the autopkgtest wrappers
@@ -379,24 +370,49 @@ func runExtUnit(cmd *cobra.Command, args []string) error {
 	if !strings.HasSuffix(unitname, ".service") {
 		unitname = unitname + ".service"
 	}
+
+	// Collect the subtest results the test writes, and pass them on
+	// whether it succeeds, fails or reboots
+	if err := os.MkdirAll(kola.KoletExtResultsDir, 0755); err != nil {
+		return err
+	}
+	results := kola.NewExtResultsReader(kola.ExtTestResultsPath(unitname))
+	res, err := watchExtUnit(ctx, unitname, results, rebootChan, softRebootChan, errChan)
+	subtests, collectErr := results.Collect()
+	if collectErr != nil {
+		systemdjournal.Print(systemdjournal.PriWarning, "Collecting subtest results: %v", collectErr)
+	}
+	res.Subtests = subtests
+	if res.Reboot != "" || res.SoftReboot != "" || len(res.Subtests) > 0 {
+		if err := printKoletResult(res); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// watchExtUnit starts the test unit and waits until it completes or requests
+// a reboot
+func watchExtUnit(ctx context.Context, unitname string, results *kola.ExtResultsReader, rebootChan, softRebootChan chan string, errChan chan error) (kola.KoletResult, error) {
+	var res kola.KoletResult
 	sdconn, err := systemddbus.NewSystemConnectionContext(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "systemd connection")
+		return res, errors.Wrapf(err, "systemd connection")
 	}
 
 	// Start the unit; it's not started by default because we need to
 	// do some preparatory work above (and some is done in the harness)
 	if _, err := sdconn.StartUnitContext(ctx, unitname, "fail", nil); err != nil {
-		return errors.Wrapf(err, "starting unit")
+		return res, errors.Wrapf(err, "starting unit")
 	}
 
 	if err := sdconn.Subscribe(); err != nil {
-		return err
+		return res, err
 	}
 	// Check the status now to avoid any race conditions
 	_, err = dispatchRunExtUnit(ctx, unitname, sdconn)
 	if err != nil {
-		return err
+		return res, err
 	}
 	// Watch for changes in the target unit
 	filterFunc := func(n string) bool {
@@ -404,16 +420,27 @@ func runExtUnit(cmd *cobra.Command, args []string) error {
 	}
 	compareFunc := func(u1, u2 *systemddbus.UnitStatus) bool { return *u1 != *u2 }
 	unitevents, uniterrs := sdconn.SubscribeUnitsCustomContext(ctx, time.Second, 0, compareFunc, filterFunc)
+	// Poll the results regularly so that subtests are timed
+	resultsTicker := time.NewTicker(time.Second)
+	defer resultsTicker.Stop()
 
+	systemdjournal.Print(systemdjournal.PriInfo, "Awaiting events")
 	for {
-		systemdjournal.Print(systemdjournal.PriInfo, "Awaiting events")
 		select {
 		case err := <-errChan:
-			return err
+			return res, err
 		case reboot := <-rebootChan:
-			return initiateReboot(reboot)
+			systemdjournal.Print(systemdjournal.PriInfo, "Processing reboot request with mark: %s", reboot)
+			res.Reboot = reboot
+			return res, nil
 		case softReboot := <-softRebootChan:
-			return initiateSoftReboot(softReboot)
+			systemdjournal.Print(systemdjournal.PriInfo, "Processing soft-reboot request with mark: %s", softReboot)
+			res.SoftReboot = softReboot
+			return res, nil
+		case <-resultsTicker.C:
+			if err := results.Poll(); err != nil {
+				systemdjournal.Print(systemdjournal.PriWarning, "Polling subtest results: %v", err)
+			}
 		case m := <-unitevents:
 			for n := range m {
 				if n == unitname {
@@ -421,17 +448,17 @@ func runExtUnit(cmd *cobra.Command, args []string) error {
 					r, err := dispatchRunExtUnit(ctx, unitname, sdconn)
 					systemdjournal.Print(systemdjournal.PriInfo, "Done dispatching %s", n)
 					if err != nil {
-						return err
+						return res, err
 					}
 					if r {
-						return nil
+						return res, nil
 					}
 				} else {
 					systemdjournal.Print(systemdjournal.PriInfo, "Unexpected event %v", n)
 				}
 			}
 		case m := <-uniterrs:
-			return m
+			return res, m
 		}
 	}
 }
@@ -504,6 +531,24 @@ func runHttpd(cmd *cobra.Command, args []string) error {
 	return http.ListenAndServe(fmt.Sprintf("localhost:%s", port), nil)
 }
 
+// runAgent serves the kola agent protocol on a virtio-serial port. Reads
+// return EOF while the host side is disconnected, so we keep reopening the
+// port rather than exit.
+func runAgent(cmd *cobra.Command, args []string) error {
+	for {
+		f, err := os.OpenFile(args[0], os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		err = platform.ServeAgent(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
 func main() {
 	registerTestMap(register.Tests)
 	registerTestMap(register.UpgradeTests)
@@ -518,6 +563,8 @@ func main() {
 	cmdHttpd.Flags().StringP("path", "", "./", "path to filesystem contents to serve")
 	cmdHttpd.Args = cobra.ExactArgs(0)
 	root.AddCommand(cmdHttpd)
+	cmdAgent.Args = cobra.ExactArgs(1)
+	root.AddCommand(cmdAgent)
 
 	cli.Execute(root)
 }
@@ -59,6 +59,44 @@ func FetchAndParseCanonicalReleaseIndex(stream string) (*release.Index, error) {
 	return index, nil
 }
 
+// Updates is the updates metadata of a stream, which Zincati and the update
+// graph use to know e.g. which releases are barriers.
+type Updates struct {
+	Stream   string           `json:"stream"`
+	Releases []UpdatesRelease `json:"releases"`
+}
+
+// UpdatesRelease is a release in the updates metadata.
+type UpdatesRelease struct {
+	Version  string `json:"version"`
+	Metadata struct {
+		Barrier *UpdatesBarrier `json:"barrier,omitempty"`
+	} `json:"metadata"`
+}
+
+// UpdatesBarrier marks a release all older releases must update to before
+// updating further.
+type UpdatesBarrier struct {
+	Reason string `json:"reason"`
+}
+
+// FetchAndParseCanonicalUpdates returns the updates metadata of a stream
+func FetchAndParseCanonicalUpdates(stream string) (*Updates, error) {
+	u := fcosinternals.GetBaseURL()
+	u.Path = fmt.Sprintf("updates/%s.json", stream)
+	body, err := fetchURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates *Updates
+	if err = json.Unmarshal(body, &updates); err != nil {
+		return nil, err
+	}
+
+	return updates, nil
+}
+
 // FetchAndParseCanonicalStreamMetadata returns a stream
 func FetchAndParseCanonicalStreamMetadata(streamName string) (*stream.Stream, error) {
 	url := fedoracoreos.GetStreamURL(streamName)
@@ -0,0 +1,158 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+// Event types emitted by the harness itself.
+const (
+	EventSuiteStart  = "suite_start"
+	EventSuiteFinish = "suite_finish"
+	EventTestQueued  = "test_queued" // test or subtest created
+	EventTestStart   = "test_start"  // test function started
+	EventTestWait    = "test_wait"   // parallel test waiting for a free slot
+	EventTestResume  = "test_resume" // parallel test got a slot
+	EventTestFail    = "test_fail"   // test reported an error
+	EventTestWarn    = "test_warn"   // test reported an error, but only warns on failure
+	EventTestFinish  = "test_finish" // test and its subtests completed
+)
+
+// Event is a single entry in the event stream.
+type Event struct {
+	Time     time.Time             `json:"time"`
+	Type     string                `json:"type"`
+	Test     string                `json:"test,omitempty"`
+	Machine  string                `json:"machine,omitempty"`
+	Result   testresult.TestResult `json:"result,omitempty"`
+	Duration float64               `json:"duration,omitempty"` // seconds
+	Message  string                `json:"message,omitempty"`
+}
+
+// EventSink writes events as JSON lines as the suite progresses. A nil
+// *EventSink discards all events.
+type EventSink struct {
+	mu  sync.Mutex
+	w   io.WriteCloser
+	enc *json.Encoder
+	err error
+}
+
+// NewEventSink creates an EventSink writing to w.
+func NewEventSink(w io.WriteCloser) *EventSink {
+	return &EventSink{
+		w:   w,
+		enc: json.NewEncoder(w),
+	}
+}
+
+// OpenEventSink creates an EventSink for target, which is either
+// "fd:N" for an inherited file descriptor, "unix:PATH" for a listening
+// Unix socket or the path of a file to create.
+func OpenEventSink(target string) (*EventSink, error) {
+	switch {
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.ParseUint(strings.TrimPrefix(target, "fd:"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event file descriptor %q: %w", target, err)
+		}
+		return NewEventSink(os.NewFile(uintptr(fd), target)), nil
+	case strings.HasPrefix(target, "unix:"):
+		conn, err := net.Dial("unix", strings.TrimPrefix(target, "unix:"))
+		if err != nil {
+			return nil, err
+		}
+		return NewEventSink(conn), nil
+	default:
+		f, err := os.Create(target)
+		if err != nil {
+			return nil, err
+		}
+		return NewEventSink(f), nil
+	}
+}
+
+// Emit writes e to the sink, setting its time if unset. After the first
+// write error, further events are dropped; the error is returned by Close.
+func (s *EventSink) Emit(e Event) {
+	if s == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = s.enc.Encode(e)
+}
+
+// Close closes the underlying writer.
+func (s *EventSink) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Close(); err != nil && s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
+
+// Emit sends an event of the given type for this test to the suite's event
+// sink, if there is one. machine may be empty if the event does not concern
+// a specific machine.
+func (c *H) Emit(typ, machine, message string) {
+	c.emit(Event{
+		Type:    typ,
+		Machine: machine,
+		Message: message,
+	})
+}
+
+func (c *H) emit(e Event) {
+	// the root test is just the suite itself
+	if c.parent == nil || c.suite.opts.Events == nil {
+		return
+	}
+	e.Test = c.name
+	c.suite.opts.Events.Emit(e)
+}
+
+// emitError reports an error logged by the test.
+func (c *H) emitError(msg string) {
+	typ := EventTestFail
+	if c.warningOnFailure {
+		typ = EventTestWarn
+	}
+	c.emit(Event{
+		Type:    typ,
+		Message: strings.TrimSpace(msg),
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestEvents(t *testing.T) {
+	var events bytes.Buffer
+	suite := NewSuite(Options{
+		Parallel: 1,
+		Events:   NewEventSink(nopCloser{&events}),
+	}, Tests{
+		"pass": &HarnessTest{
+			run: func(h *H) {
+				h.Parallel()
+				h.Emit("machine_created", "m1", "")
+				h.Run("sub", func(h *H) {})
+			},
+		},
+		"fail": &HarnessTest{
+			run: func(h *H) {
+				h.Parallel()
+				h.Errorf("broken")
+			},
+		},
+	})
+	buf := &bytes.Buffer{}
+	if err := suite.runTests(buf, nil); err != SuiteFailed {
+		t.Log("\n" + buf.String())
+		t.Fatalf("expected suite to fail, got %v", err)
+	}
+
+	byTest := make(map[string][]string)
+	var last Event
+	scanner := bufio.NewScanner(&events)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("event without time: %q", scanner.Text())
+		}
+		byTest[e.Test] = append(byTest[e.Test], e.Type)
+		if e.Type == EventTestFail && e.Message != "broken" {
+			t.Errorf("unexpected failure message %q", e.Message)
+		}
+		last = e
+	}
+
+	expected := map[string][]string{
+		"":         {EventSuiteStart, EventSuiteFinish},
+		"pass":     {EventTestQueued, EventTestStart, EventTestWait, EventTestResume, "machine_created", EventTestFinish},
+		"pass/sub": {EventTestQueued, EventTestStart, EventTestFinish},
+		"fail":     {EventTestQueued, EventTestStart, EventTestWait, EventTestResume, EventTestFail, EventTestFinish},
+	}
+	if !reflect.DeepEqual(byTest, expected) {
+		t.Errorf("unexpected events:\n got: %v\nwant: %v", byTest, expected)
+	}
+	if last.Type != EventSuiteFinish || last.Result != testresult.Fail {
+		t.Errorf("unexpected last event %+v", last)
+	}
+}
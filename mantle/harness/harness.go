@@ -47,7 +47,7 @@ const defaultTimeout = 10 * time.Minute
 // The other reporting methods, such as the variations of Log and Error,
 // may be called simultaneously from multiple goroutines.
 type H struct {
-	mu       sync.RWMutex // guards output, failed, and done.
+	mu       sync.RWMutex // guards output, failed, done, metrics and reportedDuration.
 	output   bytes.Buffer // Output generated by test.
 	w        io.Writer    // For flushToParent.
 	tap      io.Writer    // Optional TAP log of test results.
@@ -69,7 +69,7 @@ type H struct {
 	start    time.Time // Time test started
 	duration time.Duration
 	released bool      // Indicates whether the test has already released its parallel slot
-	barrier  chan bool // To signal parallel subtests they may start.
+	resume   chan bool // To signal a parallel test it may start.
 	signal   chan bool // To signal a test is done.
 	sub      []*H      // Queue of subtests to be run in parallel.
 	subtests []string  // All subtests of this test
@@ -84,6 +84,10 @@ type H struct {
 	timeoutContext context.Context
 
 	reporters reporters.Reporters
+	metrics   interface{} // Measurements of the test for the reporters
+
+	// Duration to report instead of the measured one, see SetDuration
+	reportedDuration time.Duration
 }
 
 // Run f so that it times out if needed, output errMsg in case of timeout
@@ -261,6 +265,23 @@ func (c *H) SetSubtests(subtests []string) {
 	c.subLock.Unlock()
 }
 
+// SetMetrics attaches measurements of the test, such as its resource
+// usage, to its report. The metrics must be marshalable to JSON.
+func (c *H) SetMetrics(metrics interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = metrics
+}
+
+// SetDuration sets the duration reported for the test instead of the time
+// its function took, for tests which only report the result of something
+// which ran elsewhere.
+func (c *H) SetDuration(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reportedDuration = d
+}
+
 // Context returns the context for the current test.
 // The context is cancelled when the test finishes.
 // A goroutine started during a test can wait for the
@@ -365,25 +386,33 @@ func (c *H) Logf(format string, args ...interface{}) { c.log(fmt.Sprintf(format,
 
 // Error is equivalent to Log followed by Fail.
 func (c *H) Error(args ...interface{}) {
-	c.log(fmt.Sprintln(args...))
+	msg := fmt.Sprintln(args...)
+	c.log(msg)
+	c.emitError(msg)
 	c.Fail()
 }
 
 // Errorf is equivalent to Logf followed by Fail.
 func (c *H) Errorf(format string, args ...interface{}) {
-	c.log(fmt.Sprintf(format, args...))
+	msg := fmt.Sprintf(format, args...)
+	c.log(msg)
+	c.emitError(msg)
 	c.Fail()
 }
 
 // Fatal is equivalent to Log followed by FailNow.
 func (c *H) Fatal(args ...interface{}) {
-	c.log(fmt.Sprintln(args...))
+	msg := fmt.Sprintln(args...)
+	c.log(msg)
+	c.emitError(msg)
 	c.FailNow()
 }
 
 // Fatalf is equivalent to Logf followed by FailNow.
 func (c *H) Fatalf(format string, args ...interface{}) {
-	c.log(fmt.Sprintf(format, args...))
+	msg := fmt.Sprintf(format, args...)
+	c.log(msg)
+	c.emitError(msg)
 	c.FailNow()
 }
 
@@ -491,11 +520,13 @@ func (t *H) Parallel() {
 	t.duration += time.Since(t.start)
 
 	// Add to the list of tests to be released by the parent.
+	t.resume = make(chan bool)
 	t.parent.sub = append(t.parent.sub, t)
 
-	t.signal <- true   // Release calling test.
-	<-t.parent.barrier // Wait for the parent test to complete.
-	t.suite.waitParallel()
+	t.signal <- true // Release calling test.
+	t.emit(Event{Type: EventTestWait})
+	<-t.resume // Wait for the parent to give us a slot.
+	t.emit(Event{Type: EventTestResume})
 	t.start = time.Now()
 }
 
@@ -531,8 +562,12 @@ func tRunner(t *H, fn func(t *H)) {
 			// Run parallel subtests.
 			// Decrease the running count for this test.
 			t.Release()
-			// Release the parallel subtests.
-			close(t.barrier)
+			// Release the parallel subtests in the order they were
+			// queued, so that tests queued first also start first.
+			for _, sub := range t.sub {
+				t.suite.waitParallel()
+				sub.resume <- true
+			}
 			// Wait for subtests to complete.
 			for _, sub := range t.sub {
 				<-sub.signal
@@ -560,6 +595,7 @@ func tRunner(t *H, fn func(t *H)) {
 	}()
 
 	t.start = time.Now()
+	t.emit(Event{Type: EventTestStart})
 	fn(t)
 	t.finished = true
 
@@ -580,7 +616,6 @@ func (t *H) RunTimeout(name string, f func(t *H), timeout time.Duration) bool {
 	}
 
 	t = &H{
-		barrier:   make(chan bool),
 		signal:    make(chan bool),
 		name:      testName,
 		suite:     t.suite,
@@ -601,6 +636,7 @@ func (t *H) RunTimeout(name string, f func(t *H), timeout time.Duration) bool {
 		}
 		fmt.Fprintf(root.w, "=== RUN   %s\n", t.name)
 	}
+	t.emit(Event{Type: EventTestQueued})
 	// Instead of reducing the running count of this test before calling the
 	// tRunner and increasing it afterwards, we rely on tRunner keeping the
 	// count correct. This ensures that a sequence of sequential tests runs
@@ -621,6 +657,11 @@ func (t *H) report() {
 	if t.parent == nil {
 		return
 	}
+	t.mu.Lock()
+	if t.reportedDuration > 0 {
+		t.duration = t.reportedDuration
+	}
+	t.mu.Unlock()
 	dstr := fmtDuration(t.duration)
 	format := "--- %s: %s (%s)\n"
 
@@ -644,7 +685,15 @@ func (t *H) report() {
 	t.subLock.Lock()
 	subtests := t.subtests
 	t.subLock.Unlock()
-	t.reporters.ReportTest(t.name, subtests, status, t.duration, t.output.Bytes())
+	t.mu.RLock()
+	metrics := t.metrics
+	t.mu.RUnlock()
+	t.reporters.ReportTest(t.name, subtests, status, t.duration, metrics, t.output.Bytes())
+	t.emit(Event{
+		Type:     EventTestFinish,
+		Result:   status,
+		Duration: t.duration.Seconds(),
+	})
 }
 
 // CleanOutputDir creates/empties an output directory and returns the cleaned path.
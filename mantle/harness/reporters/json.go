@@ -42,6 +42,7 @@ type jsonTest struct {
 	Subtests []string              `json:"subtests"`
 	Result   testresult.TestResult `json:"result"`
 	Duration time.Duration         `json:"duration"`
+	Metrics  interface{}           `json:"metrics,omitempty"`
 	Output   string                `json:"output"`
 }
 
@@ -71,7 +72,7 @@ func NewJSONReporter(filename, platform, version string) *jsonReporter {
 	}
 }
 
-func (r *jsonReporter) ReportTest(name string, subtests []string, result testresult.TestResult, duration time.Duration, b []byte) {
+func (r *jsonReporter) ReportTest(name string, subtests []string, result testresult.TestResult, duration time.Duration, metrics interface{}, b []byte) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -80,6 +81,7 @@ func (r *jsonReporter) ReportTest(name string, subtests []string, result testres
 		Subtests: subtests,
 		Result:   result,
 		Duration: duration,
+		Metrics:  metrics,
 		Output:   string(b),
 	})
 }
@@ -0,0 +1,212 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+// junitReporter writes results in the JUnit XML format understood by most
+// CI systems. Each top-level test becomes a <testsuite> holding a
+// <testcase> for the test itself followed by one for each of its subtests.
+type junitReporter struct {
+	filename string
+	platform string
+	version  string
+
+	tests []jsonTest
+
+	mutex sync.Mutex
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+func NewJUnitReporter(filename, platform, version string) *junitReporter {
+	return &junitReporter{
+		filename: filename,
+		platform: platform,
+		version:  version,
+		mutex:    sync.Mutex{},
+	}
+}
+
+func (r *junitReporter) ReportTest(name string, subtests []string, result testresult.TestResult, duration time.Duration, metrics interface{}, b []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.tests = append(r.tests, jsonTest{
+		Name:     name,
+		Subtests: subtests,
+		Result:   result,
+		Duration: duration,
+		Output:   string(b),
+	})
+}
+
+func (r *junitReporter) Output(path string) error {
+	f, err := os.Create(filepath.Join(path, r.filename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return r.write(f)
+}
+
+// SetResult is a no-op; the overall result is derived from the test cases.
+func (r *junitReporter) SetResult(result testresult.TestResult) {}
+
+func (r *junitReporter) write(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Subtests are reported before their parents finish, so group
+	// everything by top-level test name, keeping the order in which the
+	// top-level tests first appeared.
+	var order []string
+	groups := make(map[string][]jsonTest)
+	for _, test := range r.tests {
+		top := strings.SplitN(test.Name, "/", 2)[0]
+		if _, ok := groups[top]; !ok {
+			order = append(order, top)
+		}
+		groups[top] = append(groups[top], test)
+	}
+
+	suites := junitTestSuites{Name: "kola"}
+	var total time.Duration
+	for _, top := range order {
+		suite := junitTestSuite{Name: top}
+		if r.platform != "" {
+			suite.Properties = append(suite.Properties, junitProperty{Name: "platform", Value: r.platform})
+		}
+		if r.version != "" {
+			suite.Properties = append(suite.Properties, junitProperty{Name: "version", Value: r.version})
+		}
+		for _, test := range groups[top] {
+			tc := newJUnitTestCase(test)
+			if test.Name == top {
+				// The parent test includes the time of its subtests
+				suite.Time = fmtSeconds(test.Duration)
+				total += test.Duration
+				// and is listed first
+				suite.TestCases = append([]junitTestCase{tc}, suite.TestCases...)
+			} else {
+				suite.TestCases = append(suite.TestCases, tc)
+			}
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			} else if tc.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		if suite.Time == "" {
+			suite.Time = fmtSeconds(0)
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = fmtSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newJUnitTestCase(test jsonTest) junitTestCase {
+	classname := test.Name
+	if i := strings.LastIndex(test.Name, "/"); i >= 0 {
+		classname = test.Name[:i]
+	}
+	tc := junitTestCase{
+		Name:      test.Name,
+		ClassName: classname,
+		Time:      fmtSeconds(test.Duration),
+		SystemOut: test.Output,
+	}
+	switch test.Result {
+	case testresult.Fail:
+		tc.Failure = &junitMessage{Message: "test failed"}
+	case testresult.Skip:
+		tc.Skipped = &junitMessage{Message: "test skipped"}
+	case testresult.Warn:
+		// JUnit has no notion of a non-fatal failure; report the test
+		// as passed but keep the state visible to consumers.
+		tc.Properties = append(tc.Properties, junitProperty{Name: "result", Value: string(testresult.Warn)})
+	}
+	return tc
+}
+
+// fmtSeconds formats d the way JUnit expects durations, e.g. "87.123".
+func fmtSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
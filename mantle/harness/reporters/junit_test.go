@@ -0,0 +1,75 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+func TestJUnitReporter(t *testing.T) {
+	r := NewJUnitReporter("junit.xml", "qemu", "1.0")
+	// Subtests are reported before their parent.
+	r.ReportTest("a/one", nil, testresult.Pass, time.Second, nil, []byte("one output"))
+	r.ReportTest("a/two", nil, testresult.Fail, time.Second, nil, []byte("two \x1b[31moutput"))
+	r.ReportTest("a", []string{"one", "two"}, testresult.Fail, 3*time.Second, nil, nil)
+	r.ReportTest("b", nil, testresult.Skip, 0, nil, nil)
+	r.ReportTest("c", nil, testresult.Warn, time.Second, nil, nil)
+
+	var buf bytes.Buffer
+	if err := r.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+	}
+	if suites.Tests != 5 || suites.Failures != 2 || suites.Skipped != 1 {
+		t.Errorf("unexpected totals: tests=%d failures=%d skipped=%d", suites.Tests, suites.Failures, suites.Skipped)
+	}
+	if suites.Time != "4.000" {
+		t.Errorf("unexpected total time %q", suites.Time)
+	}
+	if len(suites.Suites) != 3 {
+		t.Fatalf("expected 3 suites, got %d", len(suites.Suites))
+	}
+
+	a := suites.Suites[0]
+	if a.Name != "a" || len(a.TestCases) != 3 {
+		t.Fatalf("unexpected suite %q with %d testcases", a.Name, len(a.TestCases))
+	}
+	if a.TestCases[0].Name != "a" || a.TestCases[1].Name != "a/one" || a.TestCases[2].ClassName != "a" {
+		t.Errorf("unexpected testcase layout: %+v", a.TestCases)
+	}
+	if a.TestCases[1].SystemOut != "one output" {
+		t.Errorf("unexpected system-out %q", a.TestCases[1].SystemOut)
+	}
+	if a.TestCases[2].Failure == nil {
+		t.Errorf("expected failure for a/two")
+	}
+
+	if suites.Suites[1].TestCases[0].Skipped == nil {
+		t.Errorf("expected b to be skipped")
+	}
+	c := suites.Suites[2].TestCases[0]
+	if c.Failure != nil || len(c.Properties) != 1 || c.Properties[0].Value != "WARN" {
+		t.Errorf("unexpected warn testcase: %+v", c)
+	}
+}
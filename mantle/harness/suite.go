@@ -79,6 +79,9 @@ type Options struct {
 	Sharding string
 
 	Reporters reporters.Reporters
+
+	// Events receives a JSON-lines stream of events as the tests run.
+	Events *EventSink
 }
 
 // FlagSet can be used to setup options via command line flags.
@@ -278,10 +281,10 @@ func (s *Suite) Run() (err error) {
 }
 
 func (s *Suite) runTests(out, tap io.Writer) error {
+	s.opts.Events.Emit(Event{Type: EventSuiteStart})
 	s.running = 1 // Set the count to 1 for the main (sequential) test.
 	t := &H{
 		signal:    make(chan bool),
-		barrier:   make(chan bool),
 		w:         out,
 		tap:       tap,
 		suite:     s,
@@ -291,7 +294,8 @@ func (s *Suite) runTests(out, tap io.Writer) error {
 		timeout: defaultTimeout,
 	}
 	tRunner(t, func(t *H) {
-		for name, htest := range s.tests {
+		for _, name := range s.tests.schedule() {
+			htest := s.tests[name]
 			t.RunTimeout(name, htest.run, htest.timeout)
 		}
 		// Run catching the signal rather than the tRunner as a separate
@@ -308,10 +312,12 @@ func (s *Suite) runTests(out, tap io.Writer) error {
 	}
 	if t.Failed() {
 		s.opts.Reporters.SetResult(testresult.Fail)
+		s.opts.Events.Emit(Event{Type: EventSuiteFinish, Result: testresult.Fail})
 		return SuiteFailed
 	}
 
 	s.opts.Reporters.SetResult(testresult.Pass)
+	s.opts.Events.Emit(Event{Type: EventSuiteFinish, Result: testresult.Pass})
 
 	return nil
 }
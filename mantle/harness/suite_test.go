@@ -16,7 +16,13 @@
 package harness
 
 import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSuiteParallelism(t *testing.T) {
@@ -111,3 +117,52 @@ func TestSuiteParallelism(t *testing.T) {
 		}
 	}
 }
+
+func TestSuiteSchedule(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+	var tests Tests
+	for name, expected := range map[string]time.Duration{
+		"short":   time.Minute,
+		"long":    time.Hour,
+		"medium":  10 * time.Minute,
+		"unknown": 0,
+	} {
+		name := name
+		tests.Add(name, func(h *H) {
+			h.Parallel()
+			mu.Lock()
+			started = append(started, name)
+			mu.Unlock()
+		}, DefaultTimeoutFlag)
+		tests.SetExpectedDuration(name, expected)
+	}
+
+	suite := NewSuite(Options{Parallel: 1}, tests)
+	if err := suite.runTests(io.Discard, nil); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"long", "medium", "short", "unknown"}
+	if !reflect.DeepEqual(started, expect) {
+		t.Errorf("got %v wanted %v", started, expect)
+	}
+}
+
+func TestSuiteSetDuration(t *testing.T) {
+	t.Setenv("TERM", "") // no colors
+	var tests Tests
+	tests.Add("parent", func(h *H) {
+		h.Run("remote", func(h *H) {
+			h.SetDuration(90 * time.Second)
+		})
+	}, DefaultTimeoutFlag)
+
+	var out bytes.Buffer
+	suite := NewSuite(Options{Verbose: true}, tests)
+	if err := suite.runTests(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "--- PASS: parent/remote (90.00s)") {
+		t.Errorf("reported duration not used:\n%s", out.String())
+	}
+}
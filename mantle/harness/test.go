@@ -16,6 +16,7 @@ package harness
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/coreos/coreos-assembler/mantle/lang/maps"
@@ -28,6 +29,8 @@ type HarnessTest struct {
 	run Test
 	// time after which test will timeout in minutes
 	timeout time.Duration
+	// how long the test is expected to run, used for scheduling
+	expected time.Duration
 }
 
 // Tests is a set of test functions and timeouts that can be given to a Suite.
@@ -48,3 +51,22 @@ func (ts *Tests) Add(name string, test Test, timeout time.Duration) {
 func (ts Tests) List() []string {
 	return maps.NaturalKeys(ts)
 }
+
+// SetExpectedDuration records how long the named test is expected to run.
+// Tests expected to run longest are started first, so that a long test
+// started last doesn't dominate the run time of the suite.
+func (ts Tests) SetExpectedDuration(name string, d time.Duration) {
+	if ht, ok := ts[name]; ok {
+		ht.expected = d
+	}
+}
+
+// schedule returns the test names in the order they should be started:
+// longest expected duration first, then sorted by name.
+func (ts Tests) schedule() []string {
+	names := ts.List()
+	sort.SliceStable(names, func(i, j int) bool {
+		return ts[names[i]].expected > ts[names[j]].expected
+	})
+	return names
+}
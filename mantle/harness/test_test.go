@@ -17,6 +17,7 @@ package harness
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestTestsAdd(t *testing.T) {
@@ -51,3 +52,20 @@ func TestTestsList(t *testing.T) {
 		t.Errorf("got %v wanted %v", list, expect)
 	}
 }
+
+func TestTestsSchedule(t *testing.T) {
+	var ts Tests
+	ts.Add("short", nil, 0)
+	ts.Add("long", nil, 0)
+	ts.Add("unknown2", nil, 0)
+	ts.Add("unknown1", nil, 0)
+	ts.Add("medium", nil, 0)
+	ts.SetExpectedDuration("short", time.Minute)
+	ts.SetExpectedDuration("long", time.Hour)
+	ts.SetExpectedDuration("medium", 10*time.Minute)
+	ts.SetExpectedDuration("missing", time.Hour)
+	expect := []string{"long", "medium", "short", "unknown1", "unknown2"}
+	if got := ts.schedule(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v wanted %v", got, expect)
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+// systemdTimespanRe matches the components of a timespan as printed by
+// systemd-analyze, e.g. "1min 2.345s"
+var systemdTimespanRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)(d|h|min|s|ms|us|µs)$`)
+
+var systemdTimespanUnits = map[string]time.Duration{
+	"d":   24 * time.Hour,
+	"h":   time.Hour,
+	"min": time.Minute,
+	"s":   time.Second,
+	"ms":  time.Millisecond,
+	"us":  time.Microsecond,
+	"µs":  time.Microsecond,
+}
+
+// BootTimes are the boot timings of one machine, in seconds.
+type BootTimes struct {
+	// Phases are the phases of systemd-analyze time: firmware, loader,
+	// kernel, initrd and userspace, plus total
+	Phases map[string]float64 `json:"phases"`
+	// Units are the activation times of systemd-analyze blame
+	Units map[string]float64 `json:"units"`
+	// CriticalChain is the output of systemd-analyze critical-chain
+	CriticalChain string `json:"criticalChain,omitempty"`
+}
+
+// BootPerfSamples are the boot timings of several boots of a build, as
+// stored for a baseline.
+type BootPerfSamples struct {
+	Build   string      `json:"build,omitempty"`
+	Samples []BootTimes `json:"samples"`
+}
+
+// BootPerfMetric compares one timing between a baseline and the build
+// under test.
+type BootPerfMetric struct {
+	Name           string  `json:"name"`
+	BaselineMedian float64 `json:"baselineMedian"`
+	CurrentMedian  float64 `json:"currentMedian"`
+	// PValue is the one-sided Mann-Whitney U test p-value of the current
+	// build being slower
+	PValue float64 `json:"pValue"`
+	// AdjustedPValue is PValue with the Holm correction for the number of
+	// phases, or units, compared
+	AdjustedPValue float64 `json:"adjustedPValue"`
+	Regression     bool    `json:"regression"`
+}
+
+// BootPerfReport is the result of a boot performance comparison.
+type BootPerfReport struct {
+	Baseline string `json:"baseline,omitempty"`
+	Current  string `json:"current,omitempty"`
+	// Alpha is the family-wise significance level of the tests of the
+	// phases, and separately of the units
+	Alpha float64 `json:"alpha"`
+	// Threshold is the minimum slowdown reported, relative to the median
+	// of the baseline
+	Threshold float64 `json:"threshold"`
+	// MinDelta is the minimum slowdown reported, in seconds
+	MinDelta float64          `json:"minDelta"`
+	Metrics  []BootPerfMetric `json:"metrics"`
+	// Regressions are the names of the phases which regressed
+	Regressions []string `json:"regressions"`
+	// UnitRegressions are the names of the units which regressed. They
+	// are informational: the boot time regressed only if a phase did.
+	UnitRegressions []string `json:"unitRegressions"`
+	// CriticalChain is the critical chain of the first boot of the build
+	// under test
+	CriticalChain string `json:"criticalChain,omitempty"`
+}
+
+// parseSystemdTimespan parses a timespan printed by systemd-analyze.
+func parseSystemdTimespan(s string) (time.Duration, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty timespan")
+	}
+	var d time.Duration
+	for _, field := range fields {
+		m := systemdTimespanRe.FindStringSubmatch(field)
+		if m == nil {
+			return 0, fmt.Errorf("invalid timespan %q", s)
+		}
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, err
+		}
+		// round, as e.g. 8.370s isn't exact in floating point
+		d += time.Duration(math.Round(v * float64(systemdTimespanUnits[m[2]])))
+	}
+	return d, nil
+}
+
+// parseSystemdAnalyzeTime parses the output of systemd-analyze time, e.g.
+// "Startup finished in 1.063s (kernel) + 2.157s (initrd) + 8.370s
+// (userspace) = 11.591s".
+func parseSystemdAnalyzeTime(out string) (map[string]float64, error) {
+	line, _, _ := strings.Cut(out, "\n")
+	_, startup, ok := strings.Cut(line, "Startup finished in ")
+	if !ok {
+		return nil, fmt.Errorf("unexpected systemd-analyze time output %q", out)
+	}
+	startup, total, ok := strings.Cut(startup, " = ")
+	if !ok {
+		return nil, fmt.Errorf("unexpected systemd-analyze time output %q", out)
+	}
+	phases := make(map[string]float64)
+	d, err := parseSystemdTimespan(total)
+	if err != nil {
+		return nil, err
+	}
+	phases["total"] = d.Seconds()
+	for _, phase := range strings.Split(startup, " + ") {
+		span, name, ok := strings.Cut(phase, " (")
+		if !ok || !strings.HasSuffix(name, ")") {
+			return nil, fmt.Errorf("unexpected boot phase %q", phase)
+		}
+		d, err := parseSystemdTimespan(span)
+		if err != nil {
+			return nil, err
+		}
+		phases[strings.TrimSuffix(name, ")")] = d.Seconds()
+	}
+	return phases, nil
+}
+
+// parseSystemdAnalyzeBlame parses the output of systemd-analyze blame.
+func parseSystemdAnalyzeBlame(out string) (map[string]float64, error) {
+	units := make(map[string]float64)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		d, err := parseSystemdTimespan(strings.Join(fields[:len(fields)-1], " "))
+		if err != nil {
+			return nil, err
+		}
+		units[fields[len(fields)-1]] = d.Seconds()
+	}
+	return units, nil
+}
+
+// CollectBootTimes waits for the machine to finish booting and returns its
+// boot timings. Boots which don't end up running, e.g. with a failed unit,
+// are rejected, as their timings aren't comparable with those of a normal
+// boot.
+func CollectBootTimes(m platform.Machine) (*BootTimes, error) {
+	// Timings are only available once the boot is done
+	state, _, err := m.SSH("systemctl is-system-running --wait")
+	if err != nil {
+		if len(state) == 0 {
+			return nil, errors.Wrapf(err, "waiting for the boot to finish")
+		}
+		failed, _, _ := m.SSH("systemctl --failed --no-legend --plain")
+		return nil, fmt.Errorf("boot of machine %s finished %s; failed units: %s", m.ID(), state, failed)
+	}
+
+	out, stderr, err := m.SSH("systemd-analyze time")
+	if err != nil {
+		return nil, errors.Wrapf(err, "running systemd-analyze time: %s", stderr)
+	}
+	phases, err := parseSystemdAnalyzeTime(string(out))
+	if err != nil {
+		return nil, err
+	}
+	out, stderr, err = m.SSH("systemd-analyze blame --no-pager")
+	if err != nil {
+		return nil, errors.Wrapf(err, "running systemd-analyze blame: %s", stderr)
+	}
+	units, err := parseSystemdAnalyzeBlame(string(out))
+	if err != nil {
+		return nil, err
+	}
+	chain, stderr, err := m.SSH("systemd-analyze critical-chain --no-pager")
+	if err != nil {
+		return nil, errors.Wrapf(err, "running systemd-analyze critical-chain: %s", stderr)
+	}
+	return &BootTimes{Phases: phases, Units: units, CriticalChain: string(chain)}, nil
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// mannWhitneyExactMax is the largest total number of values for which the
+// Mann-Whitney U test uses the exact distribution of U rather than the normal
+// approximation.
+const mannWhitneyExactMax = 50
+
+// mannWhitneyGreater returns the p-value of the one-sided Mann-Whitney U
+// test that the values of b are greater than those of a.
+func mannWhitneyGreater(a, b []float64) float64 {
+	if len(a)+len(b) <= mannWhitneyExactMax {
+		return mannWhitneyExactGreater(a, b)
+	}
+	return mannWhitneyNormalGreater(a, b)
+}
+
+// mannWhitneyExactGreater is mannWhitneyGreater with the exact distribution
+// of the rank sum of b, over all the ways to pick the ranks of b among the
+// values of both. Tied values get the mean of their ranks.
+func mannWhitneyExactGreater(a, b []float64) float64 {
+	values := append(append([]float64(nil), a...), b...)
+	sort.Float64s(values)
+	// twice the mean ranks, which are then integers
+	ranks := make([]int, len(values))
+	rankOf := make(map[float64]int)
+	for i := 0; i < len(values); {
+		j := i
+		for j+1 < len(values) && values[j+1] == values[i] {
+			j++
+		}
+		for k := i; k <= j; k++ {
+			ranks[k] = i + j + 2
+		}
+		rankOf[values[i]] = i + j + 2
+		i = j + 1
+	}
+	observed := 0
+	for _, v := range b {
+		observed += rankOf[v]
+	}
+
+	// ways[k][s] is the number of ways to pick k of the ranks seen so far
+	// with sum s
+	maxSum := 2 * len(values) * len(values)
+	ways := make([][]float64, len(b)+1)
+	for k := range ways {
+		ways[k] = make([]float64, maxSum+1)
+	}
+	ways[0][0] = 1
+	for i, rank := range ranks {
+		for k := min(i+1, len(b)); k >= 1; k-- {
+			for s := maxSum; s >= rank; s-- {
+				ways[k][s] += ways[k-1][s-rank]
+			}
+		}
+	}
+	total, greater := 0.0, 0.0
+	for s, n := range ways[len(b)] {
+		total += n
+		if s >= observed {
+			greater += n
+		}
+	}
+	return greater / total
+}
+
+// mannWhitneyNormalGreater is mannWhitneyGreater with the normal
+// approximation, with corrections for ties and continuity.
+func mannWhitneyNormalGreater(a, b []float64) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	u := 0.0
+	for _, y := range b {
+		for _, x := range a {
+			if y > x {
+				u++
+			} else if y == x {
+				u += 0.5
+			}
+		}
+	}
+
+	counts := make(map[float64]float64)
+	for _, v := range append(append([]float64(nil), a...), b...) {
+		counts[v]++
+	}
+	ties := 0.0
+	for _, t := range counts {
+		ties += t*t*t - t
+	}
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := (u - n1*n2/2 - 0.5) / math.Sqrt(variance)
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
+
+// bootPhases are the phases systemd-analyze time reports at most, whose
+// comparisons decide whether the boot time regressed
+var bootPhases = []string{"firmware", "loader", "kernel", "initrd", "userspace", "total"}
+
+// minBootPerfPValue returns the smallest p-value of the Mann-Whitney U test
+// with n1 and n2 boots, when all the boots of one build are faster than
+// those of the other.
+func minBootPerfPValue(n1, n2 int) float64 {
+	// 1 / binomial(n1+n2, n1)
+	p := 1.0
+	for i := 1; i <= n1; i++ {
+		p *= float64(i) / float64(n2+i)
+	}
+	return p
+}
+
+// CheckBootPerfCounts returns an error if no phase can regress
+// significantly at level alpha with n1 boots of the baseline and n2 of the
+// build under test.
+func CheckBootPerfCounts(n1, n2 int, alpha float64) error {
+	if alpha <= 0 || alpha >= 1 {
+		return fmt.Errorf("alpha must be between 0 and 1, got %v", alpha)
+	}
+	if n1 < 2 || n2 < 2 || float64(len(bootPhases))*minBootPerfPValue(n1, n2) >= alpha {
+		return fmt.Errorf("with %d and %d boots no regression can be significant at level %v; need at least %d boots of each build", n1, n2, alpha, minBootPerfCount(alpha))
+	}
+	return nil
+}
+
+// minBootPerfCount returns the number of boots of each build needed for a
+// regression of a phase to be significant at level alpha.
+func minBootPerfCount(alpha float64) int {
+	n := 2
+	for float64(len(bootPhases))*minBootPerfPValue(n, n) >= alpha {
+		n++
+	}
+	return n
+}
+
+// holmAdjust returns the p-values adjusted with the Holm-Bonferroni method,
+// so that comparing them with alpha bounds the probability of any false
+// positive by alpha.
+func holmAdjust(pvalues []float64) []float64 {
+	order := make([]int, len(pvalues))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return pvalues[order[i]] < pvalues[order[j]] })
+	adjusted := make([]float64, len(pvalues))
+	highest := 0.0
+	for rank, i := range order {
+		p := math.Min(1, float64(len(pvalues)-rank)*pvalues[i])
+		// adjusted p-values keep the order of the p-values
+		highest = math.Max(highest, p)
+		adjusted[i] = highest
+	}
+	return adjusted
+}
+
+// CompareBootTimes compares the boot timings of the build under test with
+// those of a baseline. A phase regressed if it is significantly slower at
+// level alpha, after the Holm correction for the number of phases compared,
+// and its median grew by more than both threshold, relative to the
+// baseline, and minDelta seconds. Units are compared the same way if they
+// were activated in every boot of both builds, but with a separate
+// correction, and only for information: with dozens of units, their
+// correction is too strict for them to decide the result with few boots.
+func CompareBootTimes(baseline, current BootPerfSamples, alpha, threshold, minDelta float64) (*BootPerfReport, error) {
+	if err := CheckBootPerfCounts(len(baseline.Samples), len(current.Samples), alpha); err != nil {
+		return nil, err
+	}
+	report := &BootPerfReport{
+		Baseline:        baseline.Build,
+		Current:         current.Build,
+		Alpha:           alpha,
+		Threshold:       threshold,
+		MinDelta:        minDelta,
+		Regressions:     []string{},
+		UnitRegressions: []string{},
+		CriticalChain:   current.Samples[0].CriticalChain,
+	}
+
+	// collect returns the values of a timing in every boot, or false if
+	// it's missing from one
+	collect := func(samples []BootTimes, get func(BootTimes) map[string]float64, name string) ([]float64, bool) {
+		var values []float64
+		for _, sample := range samples {
+			v, ok := get(sample)[name]
+			if !ok {
+				return nil, false
+			}
+			values = append(values, v)
+		}
+		return values, true
+	}
+	// compare adds the metrics of a family of timings, and returns the
+	// names of those which regressed
+	compare := func(prefix string, get func(BootTimes) map[string]float64) []string {
+		var names []string
+		for name := range get(current.Samples[0]) {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var metrics []BootPerfMetric
+		var pvalues []float64
+		for _, name := range names {
+			a, ok := collect(baseline.Samples, get, name)
+			if !ok {
+				continue
+			}
+			b, ok := collect(current.Samples, get, name)
+			if !ok {
+				continue
+			}
+			p := mannWhitneyGreater(a, b)
+			metrics = append(metrics, BootPerfMetric{
+				Name:           prefix + name,
+				BaselineMedian: median(a),
+				CurrentMedian:  median(b),
+				PValue:         p,
+			})
+			pvalues = append(pvalues, p)
+		}
+		regressions := []string{}
+		for i, adjusted := range holmAdjust(pvalues) {
+			metric := &metrics[i]
+			metric.AdjustedPValue = adjusted
+			delta := metric.CurrentMedian - metric.BaselineMedian
+			metric.Regression = adjusted < alpha && delta > minDelta && delta > threshold*metric.BaselineMedian
+			if metric.Regression {
+				regressions = append(regressions, metric.Name)
+			}
+		}
+		report.Metrics = append(report.Metrics, metrics...)
+		return regressions
+	}
+	report.Regressions = compare("", func(t BootTimes) map[string]float64 { return t.Phases })
+	report.UnitRegressions = compare("unit/", func(t BootTimes) map[string]float64 { return t.Units })
+	return report, nil
+}
@@ -0,0 +1,337 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSystemdTimespan(t *testing.T) {
+	tests := []struct {
+		s  string
+		d  time.Duration
+		ok bool
+	}{
+		{"2.345s", 2345 * time.Millisecond, true},
+		// not exact in floating point
+		{"8.370s", 8370 * time.Millisecond, true},
+		{"500ms", 500 * time.Millisecond, true},
+		{"12us", 12 * time.Microsecond, true},
+		{"1.5µs", 1500 * time.Nanosecond, true},
+		{"1min 2.345s", time.Minute + 2345*time.Millisecond, true},
+		{"1d 2h 3min", 26*time.Hour + 3*time.Minute, true},
+		{"", 0, false},
+		{"1", 0, false},
+		{"1min bogus", 0, false},
+		{"1.2.3s", 0, false},
+	}
+	for _, test := range tests {
+		d, err := parseSystemdTimespan(test.s)
+		if (err == nil) != test.ok {
+			t.Errorf("%q: expected ok %v, got %v", test.s, test.ok, err)
+		} else if d != test.d {
+			t.Errorf("%q: expected %v, got %v", test.s, test.d, d)
+		}
+	}
+}
+
+func TestParseSystemdAnalyzeTime(t *testing.T) {
+	tests := []struct {
+		out    string
+		phases map[string]float64
+	}{
+		{
+			"Startup finished in 1.063s (kernel) + 2.157s (initrd) + 8.370s (userspace) = 11.590s\n" +
+				"multi-user.target reached after 8.352s in userspace.\n",
+			map[string]float64{"kernel": 1.063, "initrd": 2.157, "userspace": 8.370, "total": 11.590},
+		},
+		{
+			"Startup finished in 5.237s (firmware) + 3.010s (loader) + 1.063s (kernel) + 2.157s (initrd) + 1min 8.370s (userspace) = 1min 19.837s\n",
+			map[string]float64{"firmware": 5.237, "loader": 3.010, "kernel": 1.063, "initrd": 2.157, "userspace": 68.370, "total": 79.837},
+		},
+		{"Bootup is not yet finished (org.freedesktop.systemd1.Manager.FinishTimestampMonotonic=0).\n", nil},
+		{"Startup finished in 1.063s (kernel) + 2.157s (initrd)\n", nil},
+		{"Startup finished in 1.063s kernel = 1.063s\n", nil},
+		{"Startup finished in 1.063s (kernel) = soon\n", nil},
+	}
+	for _, test := range tests {
+		phases, err := parseSystemdAnalyzeTime(test.out)
+		if test.phases == nil {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.out, err)
+			continue
+		}
+		if len(phases) != len(test.phases) {
+			t.Errorf("%q: expected %v, got %v", test.out, test.phases, phases)
+		}
+		for name, v := range test.phases {
+			if math.Abs(phases[name]-v) > 1e-9 {
+				t.Errorf("%q: expected %s %v, got %v", test.out, name, v, phases[name])
+			}
+		}
+	}
+}
+
+func TestParseSystemdAnalyzeBlame(t *testing.T) {
+	out := "1min 2.110s rpm-ostreed.service\n" +
+		"     500ms NetworkManager.service\n" +
+		"      12us sysroot.mount\n" +
+		"\n"
+	units, err := parseSystemdAnalyzeBlame(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{"rpm-ostreed.service": 62.11, "NetworkManager.service": 0.5, "sysroot.mount": 0.000012}
+	if len(units) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, units)
+	}
+	for name, v := range expected {
+		if math.Abs(units[name]-v) > 1e-9 {
+			t.Errorf("expected %s %v, got %v", name, v, units[name])
+		}
+	}
+
+	if _, err := parseSystemdAnalyzeBlame("soon foo.service\n"); err == nil {
+		t.Error("expected an error for an invalid timespan")
+	}
+}
+
+func TestMannWhitneyGreater(t *testing.T) {
+	tests := []struct {
+		a, b []float64
+		p    float64
+	}{
+		// b all greater: 1 of the 20 ways to pick 3 ranks of 6
+		{[]float64{1, 2, 3}, []float64{4, 5, 6}, 0.05},
+		{[]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 1.0 / 252},
+		// b all smaller
+		{[]float64{4, 5, 6}, []float64{1, 2, 3}, 1},
+		// interleaved, with different sizes
+		{[]float64{1, 3, 5, 7}, []float64{2, 4, 6, 8, 9}, 26.0 / 126},
+		// the same values
+		{[]float64{1, 2, 3}, []float64{1, 2, 3}, 0.7},
+		// ties between and within the samples
+		{[]float64{1, 2, 2}, []float64{2, 3, 3}, 0.15},
+		// only ties
+		{[]float64{1, 1}, []float64{1, 1}, 1},
+	}
+	for _, test := range tests {
+		if p := mannWhitneyGreater(test.a, test.b); math.Abs(p-test.p) > 1e-9 {
+			t.Errorf("%v < %v: expected p-value %v, got %v", test.a, test.b, test.p, p)
+		}
+	}
+
+	// large samples use the normal approximation
+	var a, b []float64
+	for i := 0; i < mannWhitneyExactMax; i++ {
+		a = append(a, float64(i))
+		b = append(b, float64(i)+0.5)
+	}
+	if p, expected := mannWhitneyGreater(a, b), mannWhitneyNormalGreater(a, b); p != expected {
+		t.Errorf("expected the normal approximation %v, got %v", expected, p)
+	}
+}
+
+func TestMannWhitneyNormalGreater(t *testing.T) {
+	tests := []struct {
+		a, b []float64
+		p    float64
+	}{
+		// b all greater
+		{[]float64{1, 2, 3}, []float64{4, 5, 6}, 0.040428},
+		// b all smaller
+		{[]float64{4, 5, 6}, []float64{1, 2, 3}, 0.985452},
+		// the same values
+		{[]float64{1, 2, 3}, []float64{1, 2, 3}, 0.590262},
+		// ties between and within the samples
+		{[]float64{1, 2, 2}, []float64{2, 3, 3}, 0.078650},
+		// only ties: no variance
+		{[]float64{1, 1}, []float64{1, 1}, 1},
+	}
+	for _, test := range tests {
+		if p := mannWhitneyNormalGreater(test.a, test.b); math.Abs(p-test.p) > 1e-6 {
+			t.Errorf("%v < %v: expected p-value %v, got %v", test.a, test.b, test.p, p)
+		}
+	}
+}
+
+func TestCheckBootPerfCounts(t *testing.T) {
+	// 6 phases * 1/70 > 0.05 > 6 * 1/252
+	if n := minBootPerfCount(0.05); n != 5 {
+		t.Errorf("expected at least 5 boots at alpha 0.05, got %d", n)
+	}
+	tests := []struct {
+		n1, n2 int
+		alpha  float64
+		ok     bool
+	}{
+		{5, 5, 0.05, true},
+		{4, 4, 0.05, false},
+		{4, 6, 0.05, true},
+		{1, 100, 0.05, false},
+		{5, 5, 0.01, false},
+		{5, 5, 0, false},
+	}
+	for _, test := range tests {
+		if err := CheckBootPerfCounts(test.n1, test.n2, test.alpha); (err == nil) != test.ok {
+			t.Errorf("%d and %d boots at %v: expected ok %v, got %v", test.n1, test.n2, test.alpha, test.ok, err)
+		}
+	}
+}
+
+func TestHolmAdjust(t *testing.T) {
+	adjusted := holmAdjust([]float64{0.01, 0.04, 0.03, 0.5})
+	// 0.01*4, then 0.03*3, then 0.04*2 raised to keep the order
+	expected := []float64{0.04, 0.09, 0.09, 0.5}
+	for i := range expected {
+		if math.Abs(adjusted[i]-expected[i]) > 1e-9 {
+			t.Fatalf("expected %v, got %v", expected, adjusted)
+		}
+	}
+	if len(holmAdjust(nil)) != 0 {
+		t.Error("expected no p-values")
+	}
+}
+
+func TestCompareBootTimes(t *testing.T) {
+	samples := func(total []float64, units map[string][]float64) BootPerfSamples {
+		var s BootPerfSamples
+		for i, v := range total {
+			times := BootTimes{Phases: map[string]float64{"total": v}, Units: map[string]float64{}}
+			for name, values := range units {
+				if i < len(values) {
+					times.Units[name] = values[i]
+				}
+			}
+			s.Samples = append(s.Samples, times)
+		}
+		return s
+	}
+	baseline := samples([]float64{10, 11, 12, 13, 14}, map[string][]float64{
+		"slow.service":    {1, 2, 3, 6, 7},
+		"same.service":    {1, 1, 1, 1, 1},
+		"missing.service": {1, 1, 1, 1, 1},
+	})
+	current := samples([]float64{20, 21, 22, 23, 24}, map[string][]float64{
+		"slow.service": {4, 5, 8, 9, 10},
+		"same.service": {1, 1, 1, 1, 1},
+		// not activated in every boot
+		"missing.service": {9, 9},
+	})
+
+	report, err := CompareBootTimes(baseline, current, 0.05, 0.1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, metric := range report.Metrics {
+		names = append(names, metric.Name)
+	}
+	if expected := []string{"total", "unit/same.service", "unit/slow.service"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected metrics %v, got %v", expected, names)
+	}
+	// slow.service is significant alone, but not after the correction
+	slow := report.Metrics[2]
+	if slow.PValue >= 0.05 || slow.AdjustedPValue < 0.05 || slow.Regression {
+		t.Errorf("expected slow.service not to regress after the correction, got %+v", slow)
+	}
+	if !reflect.DeepEqual(report.Regressions, []string{"total"}) {
+		t.Errorf("expected only total to regress, got %v", report.Regressions)
+	}
+	if len(report.UnitRegressions) != 0 {
+		t.Errorf("expected no unit to regress, got %v", report.UnitRegressions)
+	}
+
+	if _, err := CompareBootTimes(baseline, samples([]float64{20}, nil), 0.05, 0.1, 0.5); err == nil {
+		t.Error("expected an error with a single boot")
+	}
+	if _, err := CompareBootTimes(baseline, samples([]float64{20, 21, 22}, nil), 0.05, 0.1, 0.5); err == nil {
+		t.Error("expected an error with too few boots to be significant")
+	}
+}
+
+// bootSamples returns boots of a machine with 80 units, whose timings vary
+// a bit from boot to boot. slow is added to the userspace phase and to
+// unit-7.service.
+func bootSamples(count int, seed int, slow float64) BootPerfSamples {
+	var s BootPerfSamples
+	for i := 0; i < count; i++ {
+		noise := func(j int) float64 {
+			return float64((i*31+j*17+seed*7)%23) / 100
+		}
+		units := make(map[string]float64)
+		for j := 0; j < 80; j++ {
+			units[fmt.Sprintf("unit-%d.service", j)] = 0.5 + noise(j)
+		}
+		units["unit-7.service"] += slow
+		phases := map[string]float64{
+			"kernel":    1 + noise(100),
+			"initrd":    2 + noise(101),
+			"userspace": 8 + noise(102) + slow,
+		}
+		phases["total"] = phases["kernel"] + phases["initrd"] + phases["userspace"]
+		s.Samples = append(s.Samples, BootTimes{Phases: phases, Units: units})
+	}
+	return s
+}
+
+func TestCompareBootTimesManyUnits(t *testing.T) {
+	// the minimum number of boots: the phases regress regardless of the
+	// number of units
+	report, err := CompareBootTimes(bootSamples(5, 1, 0), bootSamples(5, 2, 3), 0.05, 0.1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"total", "userspace"}; !reflect.DeepEqual(report.Regressions, expected) {
+		t.Errorf("expected %v to regress, got %v", expected, report.Regressions)
+	}
+	// 80 * 1/252: no unit can be significant
+	if len(report.UnitRegressions) != 0 {
+		t.Errorf("expected no unit to regress, got %v", report.UnitRegressions)
+	}
+	if len(report.Metrics) != 84 {
+		t.Errorf("expected 84 metrics, got %d", len(report.Metrics))
+	}
+
+	// the default number of boots
+	report, err = CompareBootTimes(bootSamples(10, 1, 0), bootSamples(10, 2, 3), 0.05, 0.1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"total", "userspace"}; !reflect.DeepEqual(report.Regressions, expected) {
+		t.Errorf("expected %v to regress, got %v", expected, report.Regressions)
+	}
+	if expected := []string{"unit/unit-7.service"}; !reflect.DeepEqual(report.UnitRegressions, expected) {
+		t.Errorf("expected %v to regress, got %v", expected, report.UnitRegressions)
+	}
+
+	// no slowdown, no regression
+	report, err = CompareBootTimes(bootSamples(10, 1, 0), bootSamples(10, 2, 0), 0.05, 0.1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Regressions) != 0 || len(report.UnitRegressions) != 0 {
+		t.Errorf("expected no regression, got %v and %v", report.Regressions, report.UnitRegressions)
+	}
+}
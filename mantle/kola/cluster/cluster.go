@@ -99,6 +99,37 @@ func (t *TestCluster) RunNative(funcName string, m platform.Machine) bool {
 	})
 }
 
+// Snapshot saves the state of every machine in the cluster under name, so
+// that it can later be restored with Revert. Only QEMU-backed machines
+// support this.
+func (t *TestCluster) Snapshot(name string) error {
+	for _, m := range t.Machines() {
+		qm, ok := m.(platform.QEMUMachine)
+		if !ok {
+			return fmt.Errorf("machine %s does not support snapshots", m.ID())
+		}
+		if err := qm.Snapshot(name); err != nil {
+			return errors.Wrapf(err, "snapshotting machine %s", m.ID())
+		}
+	}
+	return nil
+}
+
+// Revert restores every machine in the cluster to the state previously
+// saved with Snapshot.
+func (t *TestCluster) Revert(name string) error {
+	for _, m := range t.Machines() {
+		qm, ok := m.(platform.QEMUMachine)
+		if !ok {
+			return fmt.Errorf("machine %s does not support snapshots", m.ID())
+		}
+		if err := qm.Revert(name); err != nil {
+			return errors.Wrapf(err, "reverting machine %s", m.ID())
+		}
+	}
+	return nil
+}
+
 // ListNativeFunctions returns a slice of function names that can be executed
 // directly on machines in the cluster.
 func (t *TestCluster) ListNativeFunctions() []string {
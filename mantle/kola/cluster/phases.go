@@ -0,0 +1,163 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+// RebootMarkEnvFile is where the mark of the last reboot is kept on the
+// machine, as AUTOPKGTEST_REBOOT_MARK=<mark>. It is the EnvironmentFile of
+// external test units, and can be read by native code too.
+const RebootMarkEnvFile = "/run/kola-runext-env"
+
+// rebootTimeout is how long to wait for a machine to go down for a reboot
+const rebootTimeout = 120 * time.Second
+
+// RebootKind is how a machine is rebooted between phases.
+type RebootKind int
+
+const (
+	// NoReboot ends the test
+	NoReboot RebootKind = iota
+	// Reboot reboots the machine
+	Reboot
+	// SoftReboot restarts the userspace of the machine with
+	// systemctl soft-reboot
+	SoftReboot
+)
+
+// PhaseEnd says how a phase ended.
+type PhaseEnd struct {
+	// Reboot is how to reboot the machine before the next phase
+	Reboot RebootKind
+	// Mark is passed to the next phase
+	Mark string
+	// Start, if set, is called instead of rebooting the machine, for
+	// machines which reboot themselves once told to
+	Start func() error
+}
+
+// RunRebootPhases runs the phases of a test on m, rebooting m in between.
+// phase is called with the mark of the previous phase, or "" for the first
+// one, until a phase ends without a reboot. The mark is also written to
+// RebootMarkEnvFile on m before each phase but the first.
+func RunRebootPhases(m platform.Machine, phase func(mark string) (PhaseEnd, error)) error {
+	var mark string
+	for {
+		bootID, err := platform.GetMachineBootId(m)
+		if err != nil {
+			return errors.Wrapf(err, "getting boot id")
+		}
+		softrebootCount, err := platform.GetMachineSoftRebootCount(m)
+		if err != nil {
+			return errors.Wrapf(err, "getting soft reboot count")
+		}
+		if mark != "" {
+			// quote around the value for systemd
+			contents := fmt.Sprintf("AUTOPKGTEST_REBOOT_MARK='%s'", mark)
+			plog.Debugf("Setting %s", contents)
+			if err := platform.InstallFile(strings.NewReader(contents), m, RebootMarkEnvFile); err != nil {
+				return err
+			}
+		}
+
+		end, err := phase(mark)
+		if err != nil {
+			return err
+		}
+		mark = end.Mark
+
+		switch end.Reboot {
+		case NoReboot:
+			return nil
+		case Reboot:
+			plog.Debugf("Reboot request with mark='%s'", mark)
+			start := end.Start
+			if start == nil {
+				start = func() error { return platform.StartReboot(m) }
+			}
+			if err := start(); err != nil {
+				return err
+			}
+			plog.Debug("Waiting for reboot")
+			if err := m.WaitForReboot(rebootTimeout, bootID); err != nil {
+				return errors.Wrapf(err, "Waiting for reboot")
+			}
+			plog.Debug("Reboot complete")
+		case SoftReboot:
+			plog.Debugf("Soft-reboot request with mark='%s'", mark)
+			start := end.Start
+			if start == nil {
+				start = func() error { return platform.StartSoftReboot(m) }
+			}
+			if err := start(); err != nil {
+				return err
+			}
+			plog.Debug("Waiting for soft-reboot")
+			if err := m.WaitForSoftReboot(rebootTimeout, softrebootCount); err != nil {
+				return errors.Wrapf(err, "Waiting for soft-reboot")
+			}
+			plog.Debug("Soft-reboot complete")
+		default:
+			return fmt.Errorf("unknown reboot kind %d", end.Reboot)
+		}
+	}
+}
+
+// Phase is a part of a test run in a single boot of a machine.
+type Phase struct {
+	Name string
+	// Run runs the phase; mark is the name of the previous phase, or ""
+	// for the first one
+	Run func(c TestCluster, mark string)
+	// Reboot is how the machine is rebooted after the phase. With
+	// NoReboot, the next phase runs in the same boot.
+	Reboot RebootKind
+}
+
+// RunPhases runs phases on m in order, each as a subtest named after the
+// phase, and reboots m after each phase but the last as it asks. It fails
+// the test and stops at the first phase which fails.
+func (t *TestCluster) RunPhases(m platform.Machine, phases ...Phase) {
+	i := 0
+	phaseFailed := false
+	err := RunRebootPhases(m, func(mark string) (PhaseEnd, error) {
+		for i < len(phases) {
+			phase := phases[i]
+			if !t.Run(phase.Name, func(c TestCluster) { phase.Run(c, mark) }) {
+				phaseFailed = true
+				return PhaseEnd{}, fmt.Errorf("phase %s failed", phase.Name)
+			}
+			i++
+			if i < len(phases) && phase.Reboot != NoReboot {
+				return PhaseEnd{Reboot: phase.Reboot, Mark: phase.Name}, nil
+			}
+			mark = phase.Name
+		}
+		return PhaseEnd{}, nil
+	})
+	if err != nil && !phaseFailed && i > 0 {
+		t.Fatalf("rebooting after phase %s: %v", phases[i-1].Name, err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/network/mockssh"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+// fakeMachine answers the commands RunRebootPhases sends over SSH, and
+// reboots when asked to.
+type fakeMachine struct {
+	platform.Machine
+
+	mu           sync.Mutex
+	boots        int
+	softReboots  int
+	pending      string
+	files        map[string]string
+	rebootErr    error
+	mkdirs       []string
+	rebootCmds   []string
+	waitedBootID []string
+}
+
+func newFakeMachine() *fakeMachine {
+	return &fakeMachine{files: make(map[string]string)}
+}
+
+func (m *fakeMachine) SSH(cmd string) ([]byte, []byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case cmd == "cat /proc/sys/kernel/random/boot_id":
+		return []byte(fmt.Sprintf("boot-%d\n", m.boots)), nil, nil
+	case cmd == "systemctl show --value --property SoftRebootsCount":
+		return []byte(fmt.Sprintf("%d\n", m.softReboots)), nil, nil
+	case strings.HasPrefix(cmd, "sudo mkdir -p "):
+		m.mkdirs = append(m.mkdirs, strings.TrimPrefix(cmd, "sudo mkdir -p "))
+		return nil, nil, nil
+	case cmd == "sudo reboot", cmd == "sudo systemctl soft-reboot":
+		m.rebootCmds = append(m.rebootCmds, cmd)
+		m.pending = cmd
+		return nil, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unexpected command %q", cmd)
+}
+
+func (m *fakeMachine) SSHClient() (*ssh.Client, error) {
+	return mockssh.NewMockClient(func(s *mockssh.Session) {
+		path, ok := strings.CutPrefix(s.Exec, "sudo install -m 0755 /dev/stdin ")
+		if !ok {
+			s.Exit(1)
+			return
+		}
+		contents, err := io.ReadAll(s.Stdin)
+		if err != nil {
+			s.Exit(1)
+			return
+		}
+		m.mu.Lock()
+		m.files[path] = string(contents)
+		m.mu.Unlock()
+		s.Exit(0)
+	}), nil
+}
+
+func (m *fakeMachine) WaitForReboot(timeout time.Duration, bootID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waitedBootID = append(m.waitedBootID, bootID)
+	if m.rebootErr != nil {
+		return m.rebootErr
+	}
+	if m.pending != "sudo reboot" {
+		return fmt.Errorf("no reboot pending")
+	}
+	if bootID != fmt.Sprintf("boot-%d", m.boots) {
+		return fmt.Errorf("waiting for a reboot from %s in boot %d", bootID, m.boots)
+	}
+	m.pending = ""
+	m.boots++
+	// /run is a tmpfs
+	m.files = make(map[string]string)
+	return nil
+}
+
+func (m *fakeMachine) WaitForSoftReboot(timeout time.Duration, count string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending != "sudo systemctl soft-reboot" {
+		return fmt.Errorf("no soft-reboot pending")
+	}
+	if count != fmt.Sprint(m.softReboots) {
+		return fmt.Errorf("waiting for a soft-reboot from %s after %d", count, m.softReboots)
+	}
+	m.pending = ""
+	m.softReboots++
+	return nil
+}
+
+// markFile returns the mark the machine was given for this boot.
+func (m *fakeMachine) markFile() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files[RebootMarkEnvFile]
+}
+
+func TestRunRebootPhases(t *testing.T) {
+	m := newFakeMachine()
+	type seen struct {
+		mark, markFile string
+		boots, soft    int
+	}
+	var phases []seen
+	var started bool
+	ends := []PhaseEnd{
+		{Reboot: Reboot, Mark: "first"},
+		{Reboot: SoftReboot, Mark: "second"},
+		// the machine reboots itself
+		{Reboot: Reboot, Mark: "third", Start: func() error {
+			started = true
+			m.mu.Lock()
+			m.pending = "sudo reboot"
+			m.mu.Unlock()
+			return nil
+		}},
+		{Mark: "ignored"},
+	}
+	err := RunRebootPhases(m, func(mark string) (PhaseEnd, error) {
+		phases = append(phases, seen{mark, m.markFile(), m.boots, m.softReboots})
+		return ends[len(phases)-1], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []seen{
+		{"", "", 0, 0},
+		{"first", "AUTOPKGTEST_REBOOT_MARK='first'", 1, 0},
+		{"second", "AUTOPKGTEST_REBOOT_MARK='second'", 1, 1},
+		{"third", "AUTOPKGTEST_REBOOT_MARK='third'", 2, 1},
+	}
+	if !reflect.DeepEqual(phases, expected) {
+		t.Errorf("expected phases %+v, got %+v", expected, phases)
+	}
+	if !started {
+		t.Error("expected Start to be called")
+	}
+	if expected := []string{"sudo reboot", "sudo systemctl soft-reboot"}; !reflect.DeepEqual(m.rebootCmds, expected) {
+		t.Errorf("expected reboot commands %v, got %v", expected, m.rebootCmds)
+	}
+	if expected := []string{"boot-0", "boot-1"}; !reflect.DeepEqual(m.waitedBootID, expected) {
+		t.Errorf("expected to wait for reboots from %v, got %v", expected, m.waitedBootID)
+	}
+	if expected := []string{"/run", "/run", "/run"}; !reflect.DeepEqual(m.mkdirs, expected) {
+		t.Errorf("expected directories %v, got %v", expected, m.mkdirs)
+	}
+}
+
+func TestRunRebootPhasesErrors(t *testing.T) {
+	m := newFakeMachine()
+	calls := 0
+	err := RunRebootPhases(m, func(mark string) (PhaseEnd, error) {
+		calls++
+		return PhaseEnd{}, fmt.Errorf("phase failed")
+	})
+	if err == nil || err.Error() != "phase failed" || calls != 1 {
+		t.Errorf("expected the phase error after one call, got %v after %d", err, calls)
+	}
+
+	m = newFakeMachine()
+	m.rebootErr = fmt.Errorf("timed out")
+	calls = 0
+	err = RunRebootPhases(m, func(mark string) (PhaseEnd, error) {
+		calls++
+		return PhaseEnd{Reboot: Reboot, Mark: "first"}, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out") || calls != 1 {
+		t.Errorf("expected the reboot error after one call, got %v after %d", err, calls)
+	}
+
+	m = newFakeMachine()
+	err = RunRebootPhases(m, func(mark string) (PhaseEnd, error) {
+		return PhaseEnd{Reboot: RebootKind(42)}, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown reboot kind") {
+		t.Errorf("expected an unknown reboot kind error, got %v", err)
+	}
+}
+
+// runPhases runs phases on m in a harness suite, and returns whether the
+// suite passed.
+func runPhases(t *testing.T, m platform.Machine, phases ...Phase) bool {
+	var tests harness.Tests
+	tests.Add("phases", func(h *harness.H) {
+		c := TestCluster{H: h}
+		c.RunPhases(m, phases...)
+	}, harness.DefaultTimeoutFlag)
+	suite := harness.NewSuite(harness.Options{OutputDir: filepath.Join(t.TempDir(), "out")}, tests)
+	return suite.Run() == nil
+}
+
+func TestRunPhases(t *testing.T) {
+	m := newFakeMachine()
+	type seen struct {
+		name, mark, markFile string
+		boots, soft          int
+	}
+	var ran []seen
+	phase := func(name string, reboot RebootKind) Phase {
+		return Phase{
+			Name: name,
+			Run: func(c TestCluster, mark string) {
+				ran = append(ran, seen{name, mark, m.markFile(), m.boots, m.softReboots})
+			},
+			Reboot: reboot,
+		}
+	}
+	if !runPhases(t, m,
+		phase("setup", NoReboot),
+		phase("configure", Reboot),
+		phase("check", SoftReboot),
+		// no reboot after the last phase
+		phase("verify", Reboot),
+	) {
+		t.Fatal("expected the phases to pass")
+	}
+	expected := []seen{
+		{"setup", "", "", 0, 0},
+		// same boot, but the mark is the previous phase
+		{"configure", "setup", "", 0, 0},
+		{"check", "configure", "AUTOPKGTEST_REBOOT_MARK='configure'", 1, 0},
+		{"verify", "check", "AUTOPKGTEST_REBOOT_MARK='check'", 1, 1},
+	}
+	if !reflect.DeepEqual(ran, expected) {
+		t.Errorf("expected phases %+v, got %+v", expected, ran)
+	}
+	if len(m.rebootCmds) != 2 {
+		t.Errorf("expected two reboots, got %v", m.rebootCmds)
+	}
+}
+
+func TestRunPhasesFailure(t *testing.T) {
+	m := newFakeMachine()
+	var ran []string
+	phase := func(name string, fail bool) Phase {
+		return Phase{
+			Name: name,
+			Run: func(c TestCluster, mark string) {
+				ran = append(ran, name)
+				if fail {
+					c.Fatal("failing")
+				}
+			},
+			Reboot: Reboot,
+		}
+	}
+	if runPhases(t, m, phase("first", false), phase("second", true), phase("third", false)) {
+		t.Fatal("expected the phases to fail")
+	}
+	if expected := []string{"first", "second"}; !reflect.DeepEqual(ran, expected) {
+		t.Errorf("expected phases %v, got %v", expected, ran)
+	}
+
+	// a failed reboot stops the phases too
+	m = newFakeMachine()
+	m.rebootErr = fmt.Errorf("timed out")
+	ran = nil
+	if runPhases(t, m, phase("first", false), phase("second", false)) {
+		t.Fatal("expected the phases to fail")
+	}
+	if expected := []string{"first"}; !reflect.DeepEqual(ran, expected) {
+		t.Errorf("expected phases %v, got %v", expected, ran)
+	}
+}
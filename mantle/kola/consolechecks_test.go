@@ -0,0 +1,91 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+func writeConsoleChecks(t *testing.T, checks string) {
+	workdir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workdir, "src/config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workdir, "src/config/kola-console-checks.yaml"), []byte(checks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := Options.CosaWorkdir
+	t.Cleanup(func() {
+		Options.CosaWorkdir = saved
+		extraConsoleChecks = nil
+	})
+	Options.CosaWorkdir = workdir
+}
+
+func TestConsoleChecksSkipFlags(t *testing.T) {
+	writeConsoleChecks(t, `
+- desc: widget failure
+  match: 'widget failed: (.*)'
+  skipTag: allow-widget-failures
+  skipFlags:
+    - noEmergencyShellCheck
+    - privateNetwork
+`)
+	if err := ParseConsoleChecksYaml("qemu"); err != nil {
+		t.Fatal(err)
+	}
+
+	output := []byte("widget failed: out of widgets\n")
+	tests := []struct {
+		test    *register.Test
+		checked bool
+	}{
+		{nil, true},
+		{&register.Test{Name: "plain"}, true},
+		{&register.Test{Name: "flagged", Flags: []register.Flag{register.PrivateNetwork}}, false},
+		{&register.Test{Name: "other-flag", Flags: []register.Flag{register.AllowConfigWarnings}}, true},
+		{&register.Test{Name: "tagged", Tags: []string{"allow-widget-failures"}}, false},
+	}
+	for _, test := range tests {
+		warnOnly, badlines := CheckConsole(output, test.test)
+		if checked := len(badlines) > 0; checked != test.checked {
+			t.Errorf("%+v: expected checked %v, got %v", test.test, test.checked, badlines)
+		} else if checked && (warnOnly || badlines[0] != "widget failure (out of widgets)") {
+			t.Errorf("%+v: expected a widget failure error, got %v (warn only %v)", test.test, badlines, warnOnly)
+		}
+	}
+
+	// the built-in checks still honor their flag
+	emergency := []byte("Press Enter for emergency shell\n")
+	if _, badlines := CheckConsole(emergency, &register.Test{Flags: []register.Flag{register.NoEmergencyShellCheck}}); len(badlines) != 0 {
+		t.Errorf("expected the emergency shell check to be skipped, got %v", badlines)
+	}
+}
+
+func TestConsoleChecksUnknownFlag(t *testing.T) {
+	writeConsoleChecks(t, `
+- desc: widget failure
+  match: 'widget failed'
+  skipFlags:
+    - noWidgetCheck
+`)
+	if err := ParseConsoleChecksYaml("qemu"); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+}
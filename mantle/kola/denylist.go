@@ -0,0 +1,191 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+// testPolicy is what --denylist-test, kola-denylist.yaml, the grace period
+// of new tests and flake quarantine decide for a test.
+type testPolicy struct {
+	// Skip is why the test is skipped, or empty if it runs
+	Skip string
+	// Warn are the reasons why failures of the test are only warnings,
+	// e.g. "until its grace period ends on 2026-11-02"
+	Warn []string
+	// WarnSubtests are the patterns of the subtests whose failures are
+	// only warnings
+	WarnSubtests []string
+	// DeniedNative maps the denylisted native tests to their pattern
+	DeniedNative map[string]string
+	// Notes are about patterns which don't affect the test anymore
+	Notes []string
+}
+
+// denylist decides the testPolicy of tests. It is used both by kola run
+// and kola explain, so that they agree.
+type denylist struct {
+	// patterns are given with --denylist-test
+	patterns []string
+	// objs are the applicable entries of kola-denylist.yaml
+	objs []DenyListObj
+	// flakes is nil unless flake quarantine is enabled
+	flakes *FlakeHistory
+	pltfrm string
+	today  time.Time
+}
+
+// newDenylist returns the denylist for the entries objs of
+// kola-denylist.yaml, the patterns of --denylist-test and the flake
+// database.
+func newDenylist(objs []DenyListObj, pltfrm string) (*denylist, error) {
+	d := &denylist{
+		patterns: DenylistedTests,
+		objs:     objs,
+		pltfrm:   pltfrm,
+		today:    time.Now(),
+	}
+	if FlakeQuarantineThreshold > 0 {
+		if path := GetFlakeDBPath(); path != "" {
+			history, err := LoadFlakeHistory(path)
+			if err != nil {
+				return nil, err
+			}
+			d.flakes = history
+		}
+	}
+	return d, nil
+}
+
+// policy returns the testPolicy of t.
+func (d *denylist) policy(t *register.Test) (*testPolicy, error) {
+	p := &testPolicy{DeniedNative: make(map[string]string)}
+	for _, pattern := range d.patterns {
+		match, err := filepath.Match(pattern, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			p.Skip = fmt.Sprintf("denylisted by --denylist-test %s", pattern)
+			return p, nil
+		}
+		p.denyNative(t, pattern)
+	}
+
+	for _, obj := range d.objs {
+		if obj.Pattern == SkipConsoleWarningsTag {
+			continue
+		}
+		match, err := filepath.Match(obj.Pattern, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		tracker := ""
+		if obj.Tracker != "" {
+			tracker = ", see " + obj.Tracker
+		}
+		expired := false
+		if obj.SnoozeDate != "" {
+			snoozeDate, err := time.Parse(dateFormat, obj.SnoozeDate)
+			if err != nil {
+				return nil, err
+			}
+			expired = d.today.After(snoozeDate)
+		}
+		switch {
+		case obj.SnoozeDate != "" && !expired:
+			if match {
+				p.Skip = fmt.Sprintf("snoozed by kola-denylist.yaml pattern %s until %s%s", obj.Pattern, obj.SnoozeDate, tracker)
+				return p, nil
+			}
+			p.denyNative(t, obj.Pattern)
+		case obj.Warn:
+			reason := fmt.Sprintf("by kola-denylist.yaml pattern %s%s", obj.Pattern, tracker)
+			if expired {
+				reason = fmt.Sprintf("since the snooze of kola-denylist.yaml pattern %s expired on %s%s", obj.Pattern, obj.SnoozeDate, tracker)
+			}
+			if match {
+				p.Warn = append(p.Warn, reason)
+			} else if isSubtestPattern(t, obj.Pattern) {
+				p.WarnSubtests = append(p.WarnSubtests, obj.Pattern)
+			}
+		case expired:
+			if match {
+				p.Notes = append(p.Notes, fmt.Sprintf("the snooze of kola-denylist.yaml pattern %s expired on %s%s", obj.Pattern, obj.SnoozeDate, tracker))
+			}
+		default:
+			if match {
+				p.Skip = fmt.Sprintf("denylisted by kola-denylist.yaml pattern %s%s", obj.Pattern, tracker)
+				return p, nil
+			}
+			p.denyNative(t, obj.Pattern)
+		}
+	}
+
+	end, err := gracePeriodEnd(t)
+	if err != nil {
+		return nil, err
+	}
+	if d.today.Before(end) {
+		p.Warn = append(p.Warn, fmt.Sprintf("until its grace period ends on %s", end.Format(dateFormat)))
+	}
+
+	if d.flakes != nil {
+		record := d.flakes.Lookup(t.Name, d.pltfrm, Options.CosaBuildArch)
+		if record != nil && len(record.Runs) >= minFlakeRuns {
+			if score := record.Score(); score >= FlakeQuarantineThreshold {
+				p.Warn = append(p.Warn, fmt.Sprintf("as it is quarantined (flake score %.2f over %d runs)", score, len(record.Runs)))
+			}
+		}
+	}
+	return p, nil
+}
+
+// denyNative records the native tests of t denylisted by a pattern of the
+// form test/native-pattern:
+// - basic/TestNetworkScripts: excludes only TestNetworkScripts
+// - basic/* - excludes all
+// - If no pattern is specified after / , excludes none
+func (p *testPolicy) denyNative(t *register.Test, pattern string) {
+	i := strings.Index(pattern, "/")
+	if i < 0 {
+		return
+	}
+	for native := range t.NativeFuncs {
+		if _, ok := p.DeniedNative[native]; ok {
+			continue
+		}
+		if match, _ := filepath.Match(pattern[i+1:], native); match {
+			p.DeniedNative[native] = pattern
+		}
+	}
+}
+
+// isSubtestPattern returns whether pattern is of the form test/subtest,
+// where test matches t.
+func isSubtestPattern(t *register.Test, pattern string) bool {
+	i := strings.Index(pattern, "/")
+	if i < 0 {
+		return false
+	}
+	match, _ := filepath.Match(pattern[:i], t.Name)
+	return match
+}
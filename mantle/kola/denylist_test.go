@@ -0,0 +1,152 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+func TestDenylistPolicy(t *testing.T) {
+	d := &denylist{
+		patterns: []string{"cli.*"},
+		objs: []DenyListObj{
+			{Pattern: SkipConsoleWarningsTag},
+			{Pattern: "denied", Tracker: "https://example.com/1"},
+			{Pattern: "snoozed", SnoozeDate: "2026-02-01"},
+			{Pattern: "expired", SnoozeDate: "2025-12-01"},
+			{Pattern: "expired.warn", SnoozeDate: "2025-12-01", Warn: true},
+			{Pattern: "warn*", Warn: true},
+			{Pattern: "native/Test*Denied"},
+			{Pattern: "native/TestWarn", Warn: true},
+		},
+		today: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	native := map[string]register.NativeFuncWrap{"TestDenied": {}, "TestWarn": {}, "TestOther": {}}
+	tests := []struct {
+		test   register.Test
+		policy testPolicy
+	}{
+		{register.Test{Name: "cli.test"}, testPolicy{Skip: "denylisted by --denylist-test cli.*"}},
+		{register.Test{Name: "denied"}, testPolicy{Skip: "denylisted by kola-denylist.yaml pattern denied, see https://example.com/1"}},
+		{register.Test{Name: "snoozed"}, testPolicy{Skip: "snoozed by kola-denylist.yaml pattern snoozed until 2026-02-01"}},
+		{register.Test{Name: "expired"}, testPolicy{Notes: []string{"the snooze of kola-denylist.yaml pattern expired expired on 2025-12-01"}}},
+		{register.Test{Name: "expired.warn"}, testPolicy{Warn: []string{"since the snooze of kola-denylist.yaml pattern expired.warn expired on 2025-12-01"}}},
+		{register.Test{Name: "warn.new", CreationDate: "2025-12-20"}, testPolicy{Warn: []string{"by kola-denylist.yaml pattern warn*", "until its grace period ends on 2026-01-19"}}},
+		{register.Test{Name: "old", CreationDate: "2025-01-01"}, testPolicy{}},
+		{register.Test{Name: "native", NativeFuncs: native}, testPolicy{
+			WarnSubtests: []string{"native/TestWarn"},
+			DeniedNative: map[string]string{"TestDenied": "native/Test*Denied"},
+		}},
+	}
+	for _, test := range tests {
+		p, err := d.policy(&test.test)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.policy.DeniedNative == nil {
+			test.policy.DeniedNative = map[string]string{}
+		}
+		if !reflect.DeepEqual(*p, test.policy) {
+			t.Errorf("%s: expected %+v, got %+v", test.test.Name, test.policy, *p)
+		}
+	}
+
+	d.objs = []DenyListObj{{Pattern: "bad", SnoozeDate: "tomorrow"}}
+	if _, err := d.policy(&register.Test{Name: "test"}); err == nil {
+		t.Error("expected an error for an invalid snooze date")
+	}
+}
+
+func TestDenylistQuarantine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kola-flakes.json")
+	savedPath, savedThreshold := FlakeDBPath, FlakeQuarantineThreshold
+	defer func() {
+		FlakeDBPath, FlakeQuarantineThreshold = savedPath, savedThreshold
+	}()
+	FlakeDBPath = path
+
+	var h FlakeHistory
+	records := map[string]string{
+		// score 0.4
+		"flaky": "PASS FAIL/PASS PASS FAIL/PASS PASS",
+		// score 0.2, below the threshold
+		"stable": "PASS FAIL/PASS PASS PASS PASS",
+		// score 0.5, but too few runs
+		"new": "PASS FAIL/PASS PASS FAIL/PASS",
+	}
+	for name, runs := range records {
+		h.Tests = append(h.Tests, &FlakeRecord{Test: name, Platform: "qemu", Arch: Options.CosaBuildArch, Runs: flakeRuns(runs)})
+	}
+	if err := h.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	quarantined := func(pltfrm string) []string {
+		d, err := newDenylist(nil, pltfrm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, name := range []string{"flaky", "stable", "new", "unknown"} {
+			p, err := d.policy(&register.Test{Name: name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Warn) > 0 {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	FlakeQuarantineThreshold = 0
+	if names := quarantined("qemu"); len(names) != 0 {
+		t.Errorf("expected no quarantine with a zero threshold, got %v", names)
+	}
+	FlakeQuarantineThreshold = 0.4
+	if names := quarantined("qemu"); len(names) != 1 || names[0] != "flaky" {
+		t.Errorf("expected only flaky to be quarantined, got %v", names)
+	}
+	if names := quarantined("aws"); len(names) != 0 {
+		t.Errorf("expected no quarantine on another platform, got %v", names)
+	}
+}
+
+func TestIsWarningOnFailureMultiplied(t *testing.T) {
+	savedTests, savedMultiplied := WarnOnErrorTests, multipliedTests
+	defer func() {
+		WarnOnErrorTests, multipliedTests = savedTests, savedMultiplied
+	}()
+	WarnOnErrorTests = []string{"warned", "native/TestWarn"}
+	multipliedTests = map[string]string{"warned0": "warned", "warned1": "warned", "native0": "native", "other0": "other"}
+
+	for name, warn := range map[string]bool{
+		"warned":           true,
+		"warned1":          true,
+		"native0/TestWarn": true,
+		"native0/TestFail": false,
+		"other0":           false,
+		"warned2":          false,
+	} {
+		if IsWarningOnFailure(name) != warn {
+			t.Errorf("%s: expected warning on failure %v", name, warn)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+const (
+	// diagnosticsDir is the directory in the test's output directory
+	// holding the diagnostics of a failed test
+	diagnosticsDir = "diagnostics"
+	// diagnosticsConnectTimeout bounds connecting to a machine, which may
+	// be wedged
+	diagnosticsConnectTimeout = 30 * time.Second
+	// diagnosticsCommandTimeout bounds each diagnostic command
+	diagnosticsCommandTimeout = 2 * time.Minute
+)
+
+// diagnosticCommand is a command run on every machine of a failed test,
+// whose output is saved to File.
+type diagnosticCommand struct {
+	File        string `json:"file"`
+	Description string `json:"description"`
+	Command     string `json:"command"`
+}
+
+var diagnosticCommands = []diagnosticCommand{
+	{"rpm-ostree-status.json", "rpm-ostree deployments", "rpm-ostree status --json"},
+	{"systemctl-failed.txt", "failed systemd units", "systemctl --failed --no-pager"},
+	{"journal.export", "journal of all boots in export format", "sudo journalctl --no-pager -o export"},
+	{"ignition.txt", "Ignition logs of all boots", "sudo journalctl --no-pager -o short-monotonic -t ignition"},
+	{"ignition-result.json", "Ignition result", "sudo cat /etc/.ignition-result.json"},
+	{"os-release.txt", "OS release", "cat /etc/os-release"},
+	{"mounts.txt", "mounted filesystems", "findmnt --list"},
+	{"ip-addr.txt", "network interfaces and addresses", "ip addr"},
+}
+
+// diagnosticsIndex describes the diagnostics gathered for a failed test. It
+// is written to index.json in the diagnostics directory.
+type diagnosticsIndex struct {
+	Test     string                    `json:"test"`
+	Time     time.Time                 `json:"time"`
+	Machines []diagnosticsMachineIndex `json:"machines"`
+}
+
+type diagnosticsMachineIndex struct {
+	ID    string             `json:"id"`
+	Error string             `json:"error,omitempty"` // set if the machine was unreachable
+	Files []diagnosticResult `json:"files,omitempty"`
+}
+
+type diagnosticResult struct {
+	diagnosticCommand
+	// Path is relative to the diagnostics directory
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+}
+
+// collectDiagnostics gathers a standard set of diagnostics over SSH from the
+// given machines of a failed test, so that failures can be triaged without
+// reproducing them. Errors are only logged, since the test already failed.
+func collectDiagnostics(h *harness.H, machines []platform.Machine) {
+	if len(machines) == 0 {
+		return
+	}
+	dir := filepath.Join(h.OutputDir(), diagnosticsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		plog.Warningf("Failed to create diagnostics directory: %v", err)
+		return
+	}
+
+	index := diagnosticsIndex{
+		Test: h.Name(),
+		Time: time.Now().UTC(),
+	}
+	for _, m := range machines {
+		index.Machines = append(index.Machines, collectMachineDiagnostics(m, dir))
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		plog.Warningf("Failed to marshal diagnostics index: %v", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), data, 0644); err != nil {
+		plog.Warningf("Failed to write diagnostics index: %v", err)
+		return
+	}
+	h.Logf("Saved diagnostics to %s", dir)
+}
+
+func collectMachineDiagnostics(m platform.Machine, dir string) diagnosticsMachineIndex {
+	entry := diagnosticsMachineIndex{ID: m.ID()}
+	if err := os.MkdirAll(filepath.Join(dir, m.ID()), 0755); err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+
+	// Don't go through Machine.SSH since the test's context is already
+	// cancelled if the test timed out, and time out the connection
+	// ourselves since the machine may be wedged. The connection is
+	// abandoned in that case and goes away with the machine.
+	type dialResult struct {
+		client *ssh.Client
+		err    error
+	}
+	ch := make(chan dialResult, 1)
+	go func() {
+		client, err := m.SSHClient()
+		ch <- dialResult{client, err}
+	}()
+	var client *ssh.Client
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			entry.Error = fmt.Sprintf("connecting: %v", res.err)
+			return entry
+		}
+		client = res.client
+	case <-time.After(diagnosticsConnectTimeout):
+		entry.Error = "timed out connecting"
+		return entry
+	}
+	defer client.Close()
+
+	for _, cmd := range diagnosticCommands {
+		result := diagnosticResult{
+			diagnosticCommand: cmd,
+			Path:              filepath.Join(m.ID(), cmd.File),
+		}
+		if err := runDiagnosticCommand(client, cmd.Command, filepath.Join(dir, result.Path)); err != nil {
+			result.Error = err.Error()
+		}
+		entry.Files = append(entry.Files, result)
+	}
+	return entry
+}
+
+// runDiagnosticCommand runs cmd on the machine and writes its output to
+// path. The output is written even if the command fails.
+func runDiagnosticCommand(client *ssh.Client, cmd, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stdout = f
+	session.Stderr = &stderr
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(cmd) }()
+	select {
+	case err = <-done:
+	case <-time.After(diagnosticsCommandTimeout):
+		session.Close()
+		<-done
+		return fmt.Errorf("timed out after %v", diagnosticsCommandTimeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/harness/reporters"
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+const (
+	// maxWorkerAttempts is the number of workers a test is tried on before
+	// giving up when workers die while running it
+	maxWorkerAttempts = 3
+	// workerBucketOverhead is the time allowed for a worker to start the
+	// machine of a non-exclusive test bucket, on top of its tests
+	workerBucketOverhead = 10 * time.Minute
+	// workerDefaultSubtestTimeout is the timeout of non-exclusive tests
+	// without one, as in makeNonExclusiveTest
+	workerDefaultSubtestTimeout = time.Minute
+)
+
+var (
+	// Workers are the hosts tests are dispatched to over SSH. If set,
+	// kola run only coordinates and does not run any tests itself.
+	Workers []string
+	// WorkerParallelism is the number of tests run at once on each worker
+	WorkerParallelism = 1
+	// WorkerWorkdir is the cosa workdir holding the synced build on the
+	// workers. If empty, the login directory is used.
+	WorkerWorkdir string
+	// WorkerKola is the shell command used to run kola on the workers
+	WorkerKola = "kola"
+	// WorkerArgs are additional arguments for kola run on the workers
+	WorkerArgs []string
+)
+
+// workerResult is the result of a test reported by a worker
+type workerResult struct {
+	result   testresult.TestResult
+	duration time.Duration
+	output   string
+}
+
+// workerPool hands out slots on the worker hosts, WorkerParallelism per
+// host. Hosts which die are removed from the pool.
+type workerPool struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	free []string
+	live map[string]bool
+}
+
+func newWorkerPool(hosts []string, parallelism int) *workerPool {
+	p := &workerPool{live: make(map[string]bool)}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < parallelism; i++ {
+		for _, host := range hosts {
+			p.free = append(p.free, host)
+			p.live[host] = true
+		}
+	}
+	return p
+}
+
+// get blocks until a slot is free and returns its host, or returns false
+// if there are no live workers left.
+func (p *workerPool) get() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.free) == 0 {
+		if len(p.live) == 0 {
+			return "", false
+		}
+		p.cond.Wait()
+	}
+	host := p.free[0]
+	p.free = p.free[1:]
+	return host, true
+}
+
+// put returns a slot to the pool. If the host is dead, all of its slots are
+// dropped instead.
+func (p *workerPool) put(host string, alive bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.cond.Broadcast()
+	if !p.live[host] {
+		return
+	}
+	if alive {
+		p.free = append(p.free, host)
+		return
+	}
+	delete(p.live, host)
+	var free []string
+	for _, h := range p.free {
+		if h != host {
+			free = append(free, h)
+		}
+	}
+	p.free = free
+}
+
+// runDistributedTests runs tests on the worker hosts, collecting the results
+// into a single harness report in outputDir. Non-exclusive tests are
+// dispatched together so the worker can still share machines between them.
+func runDistributedTests(testsBank map[string]*register.Test, tests map[string]*register.Test, durations testDurations, rerun bool, pltfrm, outputDir string) error {
+	tests, err := shardTests(tests, Sharding, durations)
+	if err != nil {
+		plog.Fatalf("%v", err)
+	}
+
+	var nonExclusiveTests []*register.Test
+	for name, test := range tests {
+		if test.NonExclusive {
+			nonExclusiveTests = append(nonExclusiveTests, test)
+			delete(tests, name)
+		}
+	}
+	var buckets [][]*register.Test
+	if len(nonExclusiveTests) == 1 {
+		// If there is only one test then it can just be run by itself
+		tests[nonExclusiveTests[0].Name] = nonExclusiveTests[0]
+	} else if len(nonExclusiveTests) > 0 {
+		buckets = createTestBuckets(nonExclusiveTests, durations)
+	}
+
+	if WorkerParallelism < 1 {
+		plog.Fatalf("Invalid worker parallelism %d", WorkerParallelism)
+	}
+	pool := newWorkerPool(Workers, WorkerParallelism)
+
+	var htests harness.Tests
+	for _, test := range tests {
+		test := test // for the closure
+		run := func(h *harness.H) {
+			h.Parallel()
+			defer testResults.add(h)
+			results := runOnWorkers(h, []string{test.Name}, pltfrm, pool)
+			applyWorkerResult(h, test.Name, results)
+		}
+		htests.Add(test.Name, run, (test.Timeout*time.Duration(100+(Options.ExtendTimeoutPercent)))/100)
+		htests.SetExpectedDuration(test.Name, durations[test.Name])
+	}
+
+	for i, bucket := range buckets {
+		bucket := bucket // for the closure
+		var names []string
+		for _, test := range bucket {
+			names = append(names, test.Name)
+		}
+		sort.Strings(names)
+		run := func(h *harness.H) {
+			h.Parallel()
+			defer testResults.add(h)
+			h.SetSubtests(names)
+			results := runOnWorkers(h, names, pltfrm, pool)
+			for _, name := range names {
+				name := name
+				h.Run(name, func(h *harness.H) {
+					testResults.add(h)
+					applyWorkerResult(h, name, results)
+				})
+			}
+			h.NonExclusiveTestStarted()
+		}
+		name := fmt.Sprintf("non-exclusive-test-bucket-%v", i)
+		// the tests of the bucket run one after the other on the worker
+		timeout := workerBucketOverhead
+		var expected time.Duration
+		for _, test := range bucket {
+			if test.Timeout == harness.DefaultTimeoutFlag {
+				timeout += workerDefaultSubtestTimeout
+			} else {
+				timeout += test.Timeout
+			}
+			expected += durations[test.Name]
+		}
+		htests.Add(name, run, (timeout*time.Duration(100+(Options.ExtendTimeoutPercent)))/100)
+		htests.SetExpectedDuration(name, expected)
+	}
+
+	return runSuite(testsBank, htests, len(Workers)*WorkerParallelism, 0, rerun, pltfrm, outputDir)
+}
+
+// runOnWorkers runs the named tests on a worker and returns the entries of
+// the report it produced, keyed by test name with any non-exclusive bucket
+// prefix removed. If the worker dies, the tests are retried on another
+// one.
+//
+// The harness runs as many tests at once as there were worker slots at the
+// start, so once workers die, tests wait here for a slot. The timeout of
+// the tests only runs while they run on a worker.
+func runOnWorkers(h *harness.H, names []string, pltfrm string, pool *workerPool) map[string]workerResult {
+	for attempt := 1; ; attempt++ {
+		host, ok := pool.get()
+		if !ok {
+			h.Fatalf("No workers left to run %s", strings.Join(names, " "))
+		}
+		dir := filepath.Join(h.OutputDir(), "worker")
+		if err := os.RemoveAll(dir); err != nil {
+			pool.put(host, true)
+			h.Fatal(err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			pool.put(host, true)
+			h.Fatal(err)
+		}
+
+		h.Logf("Running on worker %s", host)
+		h.StartExecTimer()
+		err := runOnWorker(h, host, names, pltfrm, dir)
+		timedOut := h.TimeoutContext().Err() != nil
+		h.StopExecTimer()
+		if timedOut {
+			pool.put(host, true)
+			h.Fatalf("TIMEOUT: running %s on worker %s", strings.Join(names, " "), host)
+		}
+		if isSSHError(err) && h.Context().Err() == nil {
+			alive := checkWorker(host) == nil
+			pool.put(host, alive)
+			if !alive {
+				plog.Warningf("Worker %s died while running %s: %v", host, strings.Join(names, " "), err)
+			}
+			if attempt < maxWorkerAttempts {
+				h.Logf("Lost connection to worker %s, rescheduling: %v", host, err)
+				continue
+			}
+			h.Fatalf("Lost connection to workers %d times: %v", attempt, err)
+		}
+		pool.put(host, true)
+
+		report, rerr := reporters.DeserialiseReport(filepath.Join(dir, "reports", "report.json"))
+		if rerr != nil {
+			if err != nil {
+				h.Fatalf("kola run on worker %s failed: %v; see %s", host, err, filepath.Join(dir, "worker.log"))
+			}
+			h.Fatalf("Reading report from worker %s: %v", host, rerr)
+		}
+		results := make(map[string]workerResult)
+		for _, test := range report.Tests {
+			name := GetBaseTestName(test.Name)
+			if name == "" {
+				continue // skip non-exclusive test wrapper
+			}
+			results[name] = workerResult{
+				result:   test.Result,
+				duration: test.Duration,
+				output:   test.Output,
+			}
+		}
+		return results
+	}
+}
+
+// runOnWorker runs kola on host and unpacks its output directory into dir.
+func runOnWorker(h *harness.H, host string, names []string, pltfrm, dir string) error {
+	args := []string{"--platform", pltfrm, "--arch", Options.CosaBuildArch}
+	if CosaBuild != nil {
+		args = append(args, "--build", CosaBuild.Meta.BuildID)
+	}
+	args = append(args, WorkerArgs...)
+	args = append(args, names...)
+
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	if WorkerWorkdir != "" {
+		fmt.Fprintf(&script, "cd %s\n", shellquote.Join(WorkerWorkdir))
+	}
+	script.WriteString("dir=$(mktemp -d -p \"${TMPDIR:-/var/tmp}\" kola-worker.XXXXXX)\n")
+	script.WriteString("trap 'rm -rf \"$dir\"' EXIT\n")
+	script.WriteString("rc=0\n")
+	fmt.Fprintf(&script, "%s run --output-dir \"$dir\" %s >&2 || rc=$?\n", WorkerKola, shellquote.Join(args...))
+	script.WriteString("tar -C \"$dir\" -c .\n")
+	script.WriteString("exit $rc\n")
+
+	log, err := os.Create(filepath.Join(dir, "worker.log"))
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	ctx, cancel := context.WithCancel(h.Context())
+	defer cancel()
+	stop := context.AfterFunc(h.TimeoutContext(), cancel)
+	defer stop()
+
+	ssh := exec.CommandContext(ctx, "ssh", "-o", "BatchMode=yes", host, script.String())
+	ssh.Stderr = log
+	untar := exec.Command("tar", "-x", "-C", dir)
+	untar.Stdin, err = ssh.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	untar.Stderr = log
+	if err := ssh.Start(); err != nil {
+		return err
+	}
+	untarErr := untar.Run()
+	if err := ssh.Wait(); err != nil {
+		return err
+	}
+	return untarErr
+}
+
+// isSSHError returns true if err means that ssh itself failed rather than
+// the command it ran.
+func isSSHError(err error) bool {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode() == 255
+	}
+	return false
+}
+
+func checkWorker(host string) error {
+	return exec.Command("ssh", "-o", "BatchMode=yes", "-o", "ConnectTimeout=30", host, "true").Run()
+}
+
+// applyWorkerResult sets the result of h to the result of the named test on
+// the worker, logging the result of its subtests.
+func applyWorkerResult(h *harness.H, name string, results map[string]workerResult) {
+	if IsWarningOnFailure(name) {
+		h.WarningOnFailure()
+	}
+	var subtests []string
+	for subname := range results {
+		if strings.HasPrefix(subname, name+"/") {
+			subtests = append(subtests, subname)
+		}
+	}
+	sort.Strings(subtests)
+	for _, subname := range subtests {
+		h.Logf("--- %s: %s (%s)", results[subname].result, subname, results[subname].duration.Round(time.Millisecond))
+	}
+
+	result, ok := results[name]
+	if !ok {
+		h.Fatalf("No result for %s from worker", name)
+	}
+	// not the time spent waiting for a worker
+	h.SetDuration(result.duration)
+	switch result.result {
+	case testresult.Fail, testresult.Warn:
+		h.Errorf("Failed on worker:\n%s", strings.TrimSpace(result.output))
+	case testresult.Skip:
+		h.Skip(strings.TrimSpace(result.output))
+	}
+}
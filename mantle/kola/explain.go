@@ -0,0 +1,168 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+// TestExplanation says whether kola run would run a test and why.
+type TestExplanation struct {
+	Name string `json:"name"`
+	Run  bool   `json:"run"`
+	// Reason is why the test is skipped
+	Reason string `json:"reason,omitempty"`
+	// Notes are about how the test is run, e.g. if failures are warnings
+	Notes []string `json:"notes,omitempty"`
+}
+
+// ExplainTests returns, for every registered test, whether kola run with
+// the given patterns on pltfrm would run it, by going through the same
+// selection as runProvidedTests: the patterns, tags, platform, architecture,
+// distribution and firmware of the test, the denylist, and sharding.
+func ExplainTests(patterns []string, pltfrm string) ([]TestExplanation, error) {
+	for _, pattern := range patterns {
+		match, err := patternMatchesTests(pattern, register.Tests)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			return nil, fmt.Errorf("the pattern didn't match any tests: %s", pattern)
+		}
+	}
+	objs, err := loadDenyList(pltfrm)
+	if err != nil {
+		return nil, err
+	}
+	dl, err := newDenylist(objs, pltfrm)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range register.Tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filter := newTestFilter(patterns, pltfrm)
+	var explanations []TestExplanation
+	selected := make(map[string]*register.Test)
+	for _, name := range names {
+		t := register.Tests[name]
+		e := TestExplanation{Name: name}
+		reason, _, err := filter.skipReason(t)
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" {
+			p, err := dl.policy(t)
+			if err != nil {
+				return nil, err
+			}
+			reason = p.Skip
+			e.Notes = explainPolicy(p)
+		}
+		if reason == "" {
+			selected[name] = t
+		}
+		e.Run = reason == ""
+		e.Reason = reason
+		explanations = append(explanations, e)
+	}
+
+	if Sharding != "" {
+		if err := explainSharding(explanations, selected, pltfrm); err != nil {
+			return nil, err
+		}
+	}
+	return explanations, nil
+}
+
+// explainPolicy returns notes about how the testPolicy of a test which
+// isn't skipped affects it.
+func explainPolicy(p *testPolicy) []string {
+	var notes []string
+	for _, reason := range p.Warn {
+		notes = append(notes, "failures are warnings "+reason)
+	}
+	for _, pattern := range p.WarnSubtests {
+		notes = append(notes, fmt.Sprintf("failures of subtests are warnings by kola-denylist.yaml pattern %s", pattern))
+	}
+	var native []string
+	for name, pattern := range p.DeniedNative {
+		native = append(native, fmt.Sprintf("native test %s is denylisted by pattern %s", name, pattern))
+	}
+	sort.Strings(native)
+	notes = append(notes, native...)
+	return append(notes, p.Notes...)
+}
+
+// explainSharding skips the selected tests which aren't in the shard given
+// by Sharding. Like kola run, non-exclusive tests are sharded with the
+// wrapper of their bucket. kola explain has no flight, so unlike kola run,
+// it doesn't split buckets whose config is too large for the platform.
+func explainSharding(explanations []TestExplanation, selected map[string]*register.Test, pltfrm string) error {
+	strategy, m, n, err := parseSharding(Sharding)
+	if err != nil {
+		return err
+	}
+	durations, err := loadExpectedDurations(selected, pltfrm)
+	if err != nil {
+		return err
+	}
+
+	sharded := make(map[string]*register.Test)
+	for name, t := range selected {
+		sharded[name] = t
+	}
+	wrappers, err := bucketNonExclusiveTests(sharded, durations, nil)
+	if err != nil {
+		return err
+	}
+	wrapperOf := make(map[string]string)
+	for wrapper, tests := range wrappers {
+		for _, t := range tests {
+			wrapperOf[t.Name] = wrapper
+		}
+	}
+
+	shards := assignShards(sharded, strategy, n, durations)
+	for i := range explanations {
+		e := &explanations[i]
+		if !e.Run {
+			continue
+		}
+		name, with := e.Name, ""
+		if wrapper, ok := wrapperOf[e.Name]; ok {
+			name, with = wrapper, " with the rest of "+wrapper
+		}
+		shard, ok := shards[name]
+		if !ok {
+			return fmt.Errorf("test %s has no shard", e.Name)
+		}
+		where := fmt.Sprintf("in shard %d of %d%s", shard, n, with)
+		if shard != m {
+			e.Run = false
+			e.Reason = fmt.Sprintf("%s, not %d", where, m)
+		} else {
+			e.Notes = append(e.Notes, where)
+		}
+	}
+	return nil
+}
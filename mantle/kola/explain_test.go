@@ -0,0 +1,95 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+)
+
+func TestExplainSharding(t *testing.T) {
+	savedSharding, savedFile, savedPath := Sharding, DurationsFile, FlakeDBPath
+	defer func() {
+		Sharding, DurationsFile, FlakeDBPath = savedSharding, savedFile, savedPath
+	}()
+	DurationsFile = ""
+	FlakeDBPath = filepath.Join(t.TempDir(), "kola-flakes.json")
+
+	const n = 3
+	newSelected := func() map[string]*register.Test {
+		selected := make(map[string]*register.Test)
+		for i := 0; i < 6; i++ {
+			name := fmt.Sprintf("exclusive%d", i)
+			selected[name] = &register.Test{Name: name}
+		}
+		for i := 0; i < 4; i++ {
+			name := fmt.Sprintf("ext.nonexclusive%d", i)
+			selected[name] = &register.Test{Name: name, NonExclusive: true, ExternalTest: "/usr/bin/true", UserData: conf.EmptyIgnition()}
+		}
+		return selected
+	}
+
+	// every test runs in exactly one shard, and the tests of a bucket
+	// run in the same one
+	runs := make(map[string]int)
+	wrappers := make(map[string]string)
+	for m := 1; m <= n; m++ {
+		Sharding = fmt.Sprintf("hash:%d/%d", m, n)
+		selected := newSelected()
+		var explanations []TestExplanation
+		for name := range selected {
+			explanations = append(explanations, TestExplanation{Name: name, Run: true})
+		}
+		if err := explainSharding(explanations, selected, "qemu"); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range explanations {
+			where := e.Reason
+			if e.Run {
+				runs[e.Name]++
+				where = strings.Join(e.Notes, "; ")
+			}
+			if !strings.HasPrefix(where, "in shard ") {
+				t.Errorf("%s: expected its shard, got %q", e.Name, where)
+			}
+			if !strings.HasPrefix(e.Name, "ext.") {
+				continue
+			}
+			_, wrapper, ok := strings.Cut(where, "with the rest of ")
+			if !ok {
+				t.Errorf("%s: expected its bucket, got %q", e.Name, where)
+				continue
+			}
+			wrapper, _, _ = strings.Cut(wrapper, ",")
+			if wrappers[e.Name] != "" && wrappers[e.Name] != wrapper {
+				t.Errorf("%s: expected the same bucket in every shard, got %s and %s", e.Name, wrappers[e.Name], wrapper)
+			}
+			wrappers[e.Name] = wrapper
+		}
+	}
+	if len(runs) != 10 {
+		t.Errorf("expected all tests to run in a shard, got %v", runs)
+	}
+	for name, count := range runs {
+		if count != 1 {
+			t.Errorf("%s: expected to run in one shard, got %d", name, count)
+		}
+	}
+}
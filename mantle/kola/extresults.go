@@ -0,0 +1,248 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+// External tests can report the results of their own checks as subtests by
+// writing them to the file named by $KOLA_RESULTS, one per line, either in
+// TAP:
+//
+//	ok 1 - check something
+//	not ok 2 - check something else
+//	# diagnostics of the failure
+//	ok 3 - check a third thing # SKIP not on this platform
+//
+// or as JSON objects:
+//
+//	{"name": "check something", "result": "fail", "duration": 1.5, "output": "details"}
+//
+// kolet collects the file while the test runs and passes the results to the
+// harness with the KoletResult.
+
+// KoletExtResultsDir is where the results files of external tests are
+const KoletExtResultsDir = "/run/kola-runext-results"
+
+// kolaExtResultsEnv is an environment variable pointing to the results file
+const kolaExtResultsEnv = "KOLA_RESULTS"
+
+var (
+	tapResultRegexp  = regexp.MustCompile(`^(not )?ok\b\s*(\d*)\s*(?:-\s*)?([^#]*?)\s*(?:#\s*(.*))?$`)
+	tapBailOutRegexp = regexp.MustCompile(`^Bail out!\s*(.*)$`)
+)
+
+// ExtSubtestResult is the result of a subtest reported by an external test.
+type ExtSubtestResult struct {
+	Name     string
+	Result   testresult.TestResult
+	Duration time.Duration
+	Output   string `json:",omitempty"`
+}
+
+// extResultLine is a result in the JSON lines format
+type extResultLine struct {
+	Name     string  `json:"name"`
+	Result   string  `json:"result"`
+	Duration float64 `json:"duration"`
+	Output   string  `json:"output"`
+}
+
+// ExtTestResultsPath returns the results file of the test run by unit.
+func ExtTestResultsPath(unit string) string {
+	return filepath.Join(KoletExtResultsDir, strings.TrimSuffix(unit, ".service"))
+}
+
+// ExtResultsReader collects the subtest results an external test writes to
+// its results file. A subtest is timed from the previous result, or from
+// the creation of the reader, to when Poll first sees it, unless it reports
+// its own duration.
+type ExtResultsReader struct {
+	path    string
+	offset  int64
+	partial string
+	last    time.Time
+	results []ExtSubtestResult
+}
+
+// NewExtResultsReader returns a reader for the results file at path.
+func NewExtResultsReader(path string) *ExtResultsReader {
+	return &ExtResultsReader{path: path, last: time.Now()}
+}
+
+// Poll parses the lines added to the results file since the last call.
+func (r *ExtResultsReader) Poll() error {
+	f, err := os.Open(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(r.offset, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "seeking %s", r.path)
+	}
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return errors.Wrapf(err, "reading %s", r.path)
+	}
+	r.offset += int64(len(buf))
+
+	lines := strings.Split(r.partial+string(buf), "\n")
+	r.partial = lines[len(lines)-1]
+	now := time.Now()
+	for _, line := range lines[:len(lines)-1] {
+		r.parseLine(line, now)
+	}
+	return nil
+}
+
+// Collect polls the results file a last time, including an unterminated
+// last line, and removes it so that results written after a reboot are
+// collected separately. It returns all the results.
+func (r *ExtResultsReader) Collect() ([]ExtSubtestResult, error) {
+	if err := r.Poll(); err != nil {
+		return nil, err
+	}
+	if r.partial != "" {
+		r.parseLine(r.partial, time.Now())
+		r.partial = ""
+	}
+	if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	r.offset = 0
+	return r.results, nil
+}
+
+func (r *ExtResultsReader) parseLine(line string, now time.Time) {
+	result, ok := parseExtResultLine(line)
+	if !ok {
+		// diagnostics belong to the previous result
+		if diag := strings.TrimSpace(line); diag != "" && len(r.results) > 0 && !tapPlan(diag) {
+			prev := &r.results[len(r.results)-1]
+			if prev.Output != "" && !strings.HasSuffix(prev.Output, "\n") {
+				prev.Output += "\n"
+			}
+			prev.Output += strings.TrimSpace(strings.TrimPrefix(diag, "#")) + "\n"
+		}
+		return
+	}
+	if result.Duration == 0 {
+		result.Duration = now.Sub(r.last)
+	}
+	r.last = now
+	if result.Name == "" {
+		result.Name = fmt.Sprintf("%d", len(r.results)+1)
+	}
+	r.results = append(r.results, result)
+}
+
+// tapPlan returns true for TAP lines which don't carry results, like the
+// plan "1..N" and the version
+func tapPlan(line string) bool {
+	return strings.HasPrefix(line, "1..") || strings.HasPrefix(line, "TAP version")
+}
+
+// parseExtResultLine parses a result in either format. It returns false
+// for lines which aren't results.
+func parseExtResultLine(line string) (ExtSubtestResult, bool) {
+	line = strings.TrimRight(line, "\r")
+	if strings.HasPrefix(line, "{") {
+		var l extResultLine
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			return ExtSubtestResult{}, false
+		}
+		result := ExtSubtestResult{
+			Name:     l.Name,
+			Duration: time.Duration(l.Duration * float64(time.Second)),
+			Output:   l.Output,
+		}
+		switch strings.ToUpper(l.Result) {
+		case "PASS", "OK":
+			result.Result = testresult.Pass
+		case "SKIP":
+			result.Result = testresult.Skip
+		default:
+			result.Result = testresult.Fail
+			if strings.ToUpper(l.Result) != "FAIL" {
+				result.Output += fmt.Sprintf("unknown result %q\n", l.Result)
+			}
+		}
+		return result, true
+	}
+
+	if m := tapBailOutRegexp.FindStringSubmatch(line); m != nil {
+		return ExtSubtestResult{Name: "bail out", Result: testresult.Fail, Output: m[1]}, true
+	}
+	m := tapResultRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return ExtSubtestResult{}, false
+	}
+	result := ExtSubtestResult{Name: m[3], Result: testresult.Pass}
+	if result.Name == "" {
+		result.Name = m[2]
+	}
+	directive := strings.ToUpper(m[4])
+	switch {
+	case strings.HasPrefix(directive, "SKIP"):
+		result.Result = testresult.Skip
+		result.Output = m[4]
+	case strings.HasPrefix(directive, "TODO"):
+		// failures of TODO tests are expected, so they pass; the note
+		// keeps them apart from the checks which really passed
+		if m[1] != "" {
+			result.Output = "expected failure: " + m[4]
+		} else {
+			result.Output = "unexpectedly passed: " + m[4]
+		}
+	case m[1] != "":
+		result.Result = testresult.Fail
+	}
+	return result, true
+}
+
+// reportExtSubtests turns the subtest results of an external test into
+// subtests of h.
+func reportExtSubtests(h *harness.H, results []ExtSubtestResult) {
+	for _, result := range results {
+		result := result
+		h.Run(result.Name, func(h *harness.H) {
+			h.SetDuration(result.Duration)
+			if output := strings.TrimRight(result.Output, "\n"); output != "" {
+				h.Log(output)
+			}
+			switch result.Result {
+			case testresult.Fail:
+				h.Fail()
+			case testresult.Skip:
+				h.SkipNow()
+			}
+		})
+	}
+}
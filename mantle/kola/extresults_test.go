@@ -0,0 +1,128 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+func TestParseExtResultLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		result ExtSubtestResult
+	}{
+		{"ok 1 - check", ExtSubtestResult{Name: "check", Result: testresult.Pass}},
+		{"not ok 2 - check", ExtSubtestResult{Name: "check", Result: testresult.Fail}},
+		{"ok 3 - check # SKIP not here", ExtSubtestResult{Name: "check", Result: testresult.Skip, Output: "SKIP not here"}},
+		{"not ok 4 - check # TODO bz123", ExtSubtestResult{Name: "check", Result: testresult.Pass, Output: "expected failure: TODO bz123"}},
+		{"ok 5 - check # TODO bz123", ExtSubtestResult{Name: "check", Result: testresult.Pass, Output: "unexpectedly passed: TODO bz123"}},
+		{"not ok 6", ExtSubtestResult{Name: "6", Result: testresult.Fail}},
+		{"Bail out! broken", ExtSubtestResult{Name: "bail out", Result: testresult.Fail, Output: "broken"}},
+		{`{"name": "check", "result": "skip"}`, ExtSubtestResult{Name: "check", Result: testresult.Skip}},
+		{`{"name": "check", "result": "bogus"}`, ExtSubtestResult{Name: "check", Result: testresult.Fail, Output: "unknown result \"bogus\"\n"}},
+	}
+	for _, test := range tests {
+		result, ok := parseExtResultLine(test.line)
+		if !ok {
+			t.Errorf("%q: expected a result", test.line)
+			continue
+		}
+		if result != test.result {
+			t.Errorf("%q: expected %+v, got %+v", test.line, test.result, result)
+		}
+	}
+	if _, ok := parseExtResultLine("# diagnostics"); ok {
+		t.Error("expected diagnostics not to be a result")
+	}
+}
+
+func TestExtResultsReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results")
+	r := NewExtResultsReader(path)
+	appendResults := func(s string) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	poll := func(expected int) {
+		if err := r.Poll(); err != nil {
+			t.Fatal(err)
+		}
+		if len(r.results) != expected {
+			t.Fatalf("expected %d results, got %+v", expected, r.results)
+		}
+	}
+
+	// no results file yet
+	poll(0)
+
+	// a line is only parsed once it's complete
+	appendResults("TAP version 13\n1..4\nok 1 - fir")
+	poll(0)
+	appendResults("st\nnot ok 2 - second\n# expected 1\n")
+	poll(2)
+	appendResults("#   got 2\n")
+	poll(2)
+
+	// JSON lines mixed in, and an unterminated last line
+	appendResults(`{"name": "third", "result": "pass", "duration": 1.5}` + "\nok 4 - fourth # SKIP no")
+	poll(3)
+	results, err := r.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ExtSubtestResult{
+		{Name: "first", Result: testresult.Pass},
+		{Name: "second", Result: testresult.Fail, Output: "expected 1\ngot 2\n"},
+		{Name: "third", Result: testresult.Pass, Duration: 1500 * time.Millisecond},
+		{Name: "fourth", Result: testresult.Skip, Output: "SKIP no"},
+	}
+	check := func(results, expected []ExtSubtestResult) {
+		if len(results) != len(expected) {
+			t.Fatalf("expected %+v, got %+v", expected, results)
+		}
+		for i, result := range results {
+			// results without their own duration are timed by the reader
+			if expected[i].Duration == 0 {
+				result.Duration = 0
+			}
+			if result != expected[i] {
+				t.Errorf("expected %+v, got %+v", expected[i], result)
+			}
+		}
+	}
+	check(results, expected)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the results file to be removed, got %v", err)
+	}
+
+	// results written after a reboot are added to the earlier ones
+	appendResults("not ok\n")
+	results, err = r.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(results, append(expected, ExtSubtestResult{Name: "5", Result: testresult.Fail}))
+}
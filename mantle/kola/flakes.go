@@ -0,0 +1,307 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/reporters"
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+const (
+	// maxFlakeHistory is the number of runs kept per test, platform and arch
+	maxFlakeHistory = 30
+	// minFlakeRuns is the number of runs needed before a test can be quarantined
+	minFlakeRuns = 5
+)
+
+var (
+	// FlakeDBPath is where the flake history is kept. If empty, it
+	// defaults to cache/kola-flakes.json in the cosa workdir.
+	FlakeDBPath string
+	// FlakeQuarantineThreshold is the flake score above which failures of
+	// a test are only warnings. Zero disables quarantine.
+	FlakeQuarantineThreshold float64
+)
+
+// FlakeHistory is the on-disk flake database.
+type FlakeHistory struct {
+	Tests []*FlakeRecord `json:"tests"`
+}
+
+// FlakeRecord is the run history of a test on a given platform and arch.
+type FlakeRecord struct {
+	Test     string     `json:"test"`
+	Platform string     `json:"platform"`
+	Arch     string     `json:"arch"`
+	Runs     []FlakeRun `json:"runs"`
+}
+
+// FlakeRun is the outcome of a test in a single kola run. RerunResult is
+// only set if the test was rerun after failing.
+type FlakeRun struct {
+	Date        time.Time             `json:"date"`
+	Build       string                `json:"build,omitempty"`
+	Result      testresult.TestResult `json:"result"`
+	RerunResult testresult.TestResult `json:"rerun_result,omitempty"`
+	Duration    time.Duration         `json:"duration,omitempty"`
+}
+
+func (r FlakeRun) failed() bool {
+	return r.Result == testresult.Fail || r.Result == testresult.Warn
+}
+
+// Failures returns the number of runs in which the test failed, whether or
+// not it passed on rerun.
+func (f *FlakeRecord) Failures() int {
+	n := 0
+	for _, run := range f.Runs {
+		if run.failed() {
+			n++
+		}
+	}
+	return n
+}
+
+// Flakes returns the number of runs which look like flakes: either the test
+// failed and then passed on rerun, or it failed once in between two passing
+// runs.
+func (f *FlakeRecord) Flakes() int {
+	n := 0
+	for i, run := range f.Runs {
+		if !run.failed() {
+			continue
+		}
+		if run.RerunResult == testresult.Pass {
+			n++
+		} else if run.RerunResult == "" && i > 0 && i < len(f.Runs)-1 &&
+			f.Runs[i-1].Result == testresult.Pass && f.Runs[i+1].Result == testresult.Pass {
+			n++
+		}
+	}
+	return n
+}
+
+// Score returns the fraction of recorded runs that were flakes.
+func (f *FlakeRecord) Score() float64 {
+	if len(f.Runs) == 0 {
+		return 0
+	}
+	return float64(f.Flakes()) / float64(len(f.Runs))
+}
+
+// LastRun returns the date of the most recent run.
+func (f *FlakeRecord) LastRun() time.Time {
+	if len(f.Runs) == 0 {
+		return time.Time{}
+	}
+	return f.Runs[len(f.Runs)-1].Date
+}
+
+// ExpectedDuration returns the median duration of the recorded runs which
+// passed, or of all recorded runs if none passed. It returns zero if no
+// durations were recorded.
+func (f *FlakeRecord) ExpectedDuration() time.Duration {
+	var passed, all []time.Duration
+	for _, run := range f.Runs {
+		if run.Duration <= 0 {
+			continue
+		}
+		all = append(all, run.Duration)
+		if run.Result == testresult.Pass {
+			passed = append(passed, run.Duration)
+		}
+	}
+	if len(passed) > 0 {
+		return medianDuration(passed)
+	}
+	return medianDuration(all)
+}
+
+func medianDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// GetFlakeDBPath returns the path of the flake database, or the empty
+// string if there is nowhere to keep one.
+func GetFlakeDBPath() string {
+	if FlakeDBPath != "" {
+		return FlakeDBPath
+	}
+	if Options.CosaWorkdir != "" && Options.CosaWorkdir != "none" {
+		return filepath.Join(Options.CosaWorkdir, "cache/kola-flakes.json")
+	}
+	return ""
+}
+
+// LoadFlakeHistory reads the flake database at path. A missing file is
+// treated as an empty history.
+func LoadFlakeHistory(path string) (*FlakeHistory, error) {
+	var h FlakeHistory
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &h, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &h); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", path)
+	}
+	return &h, nil
+}
+
+// Save atomically writes the flake database to path.
+func (h *FlakeHistory) Save(path string) error {
+	sort.Slice(h.Tests, func(i, j int) bool {
+		a, b := h.Tests[i], h.Tests[j]
+		if a.Test != b.Test {
+			return a.Test < b.Test
+		}
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		}
+		return a.Arch < b.Arch
+	})
+	buf, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Lookup returns the record for the given test, platform and arch, or nil.
+func (h *FlakeHistory) Lookup(test, platform, arch string) *FlakeRecord {
+	for _, r := range h.Tests {
+		if r.Test == test && r.Platform == platform && r.Arch == arch {
+			return r
+		}
+	}
+	return nil
+}
+
+// add records a run of a test. Runs are keyed by build: a new run of a
+// build which was already recorded replaces the earlier one, so that
+// testing a build repeatedly doesn't skew its flake score.
+func (h *FlakeHistory) add(test, platform, arch string, run FlakeRun) {
+	r := h.Lookup(test, platform, arch)
+	if r == nil {
+		r = &FlakeRecord{Test: test, Platform: platform, Arch: arch}
+		h.Tests = append(h.Tests, r)
+	}
+	for i, prev := range r.Runs {
+		if prev.Build == run.Build {
+			r.Runs = append(r.Runs[:i], r.Runs[i+1:]...)
+			break
+		}
+	}
+	r.Runs = append(r.Runs, run)
+	if len(r.Runs) > maxFlakeHistory {
+		r.Runs = r.Runs[len(r.Runs)-maxFlakeHistory:]
+	}
+}
+
+type flakeResult struct {
+	result   testresult.TestResult
+	duration time.Duration
+}
+
+// readFlakeResults returns the results of the top-level tests of the kola
+// run in outputDir, keyed by test name.
+func readFlakeResults(outputDir string) (map[string]flakeResult, error) {
+	return readReportResults(filepath.Join(outputDir, "reports", "report.json"))
+}
+
+// readReportResults returns the results of the top-level tests in a
+// report.json, keyed by test name.
+func readReportResults(path string) (map[string]flakeResult, error) {
+	report, err := reporters.DeserialiseReport(path)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]flakeResult)
+	for _, test := range report.Tests {
+		name := GetBaseTestName(test.Name)
+		if name == "" || strings.Contains(name, "/") {
+			// non-exclusive wrapper or subtest
+			continue
+		}
+		results[name] = flakeResult{result: test.Result, duration: test.Duration}
+	}
+	return results, nil
+}
+
+// RecordFlakeHistory adds the results and durations of the kola run in
+// outputDir, and the results of its rerun if there was one, to the flake
+// database. Runs are recorded per build, so nothing is recorded for runs
+// which aren't on a cosa build.
+func RecordFlakeHistory(outputDir, pltfrm string) error {
+	path := GetFlakeDBPath()
+	if path == "" || CosaBuild == nil || CosaBuild.Meta == nil {
+		return nil
+	}
+
+	results, err := readFlakeResults(outputDir)
+	if err != nil {
+		return err
+	}
+	rerunResults, err := readFlakeResults(filepath.Join(outputDir, "rerun"))
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return err
+	}
+
+	history, err := LoadFlakeHistory(path)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for name, result := range results {
+		if result.result == testresult.Skip {
+			continue
+		}
+		run := FlakeRun{
+			Date:     now,
+			Build:    CosaBuild.Meta.BuildID,
+			Result:   result.result,
+			Duration: result.duration,
+		}
+		if rerun, ok := rerunResults[name]; ok && run.failed() {
+			run.RerunResult = rerun.result
+		}
+		history.add(name, pltfrm, Options.CosaBuildArch, run)
+	}
+
+	return history.Save(path)
+}
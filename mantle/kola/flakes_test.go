@@ -0,0 +1,149 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+	"github.com/coreos/coreos-assembler/mantle/util"
+	cosa "github.com/coreos/coreos-assembler/pkg/builds"
+)
+
+func flakeRuns(results string) []FlakeRun {
+	var runs []FlakeRun
+	for _, r := range strings.Split(results, " ") {
+		result, rerun, _ := strings.Cut(r, "/")
+		runs = append(runs, FlakeRun{Result: testresult.TestResult(result), RerunResult: testresult.TestResult(rerun)})
+	}
+	return runs
+}
+
+func TestFlakeScore(t *testing.T) {
+	tests := []struct {
+		runs     string
+		failures int
+		flakes   int
+		score    float64
+	}{
+		{"PASS PASS PASS PASS", 0, 0, 0},
+		// failed, then passed on rerun
+		{"PASS FAIL/PASS PASS PASS", 1, 1, 0.25},
+		// failed on rerun too
+		{"PASS FAIL/FAIL PASS PASS", 1, 0, 0},
+		// failed once in between two passes
+		{"PASS FAIL PASS WARN", 2, 1, 0.25},
+		// consistent failures aren't flakes
+		{"PASS FAIL FAIL PASS", 2, 0, 0},
+		// the last run can't be judged without a rerun
+		{"PASS PASS PASS FAIL", 1, 0, 0},
+		{"FAIL/PASS WARN/PASS", 2, 2, 1},
+	}
+	for _, test := range tests {
+		record := &FlakeRecord{Runs: flakeRuns(test.runs)}
+		if n := record.Failures(); n != test.failures {
+			t.Errorf("%s: expected %d failures, got %d", test.runs, test.failures, n)
+		}
+		if n := record.Flakes(); n != test.flakes {
+			t.Errorf("%s: expected %d flakes, got %d", test.runs, test.flakes, n)
+		}
+		if score := record.Score(); score != test.score {
+			t.Errorf("%s: expected score %.2f, got %.2f", test.runs, test.score, score)
+		}
+	}
+	if score := (&FlakeRecord{}).Score(); score != 0 {
+		t.Errorf("expected score 0 without runs, got %.2f", score)
+	}
+}
+
+func TestFlakeHistoryAdd(t *testing.T) {
+	var h FlakeHistory
+	h.add("test", "qemu", "x86_64", FlakeRun{Build: "1", Result: testresult.Fail})
+	h.add("test", "qemu", "x86_64", FlakeRun{Build: "2", Result: testresult.Pass})
+	// a build tested again replaces its earlier run
+	h.add("test", "qemu", "x86_64", FlakeRun{Build: "1", Result: testresult.Pass})
+	h.add("test", "qemu", "aarch64", FlakeRun{Build: "1", Result: testresult.Pass})
+
+	record := h.Lookup("test", "qemu", "x86_64")
+	if record == nil || len(record.Runs) != 2 {
+		t.Fatalf("expected 2 runs, got %+v", record)
+	}
+	if record.Runs[0].Build != "2" || record.Runs[1].Build != "1" || record.Failures() != 0 {
+		t.Errorf("expected the rerun of build 1 to replace its failure, got %+v", record.Runs)
+	}
+	if len(h.Tests) != 2 {
+		t.Errorf("expected a record per arch, got %d records", len(h.Tests))
+	}
+
+	for i := 0; i < maxFlakeHistory+5; i++ {
+		h.add("long", "qemu", "x86_64", FlakeRun{Build: strings.Repeat("x", i+1), Result: testresult.Pass})
+	}
+	if n := len(h.Lookup("long", "qemu", "x86_64").Runs); n != maxFlakeHistory {
+		t.Errorf("expected %d runs to be kept, got %d", maxFlakeHistory, n)
+	}
+}
+
+func TestRecordFlakeHistory(t *testing.T) {
+	outputDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "kola-flakes.json")
+	savedPath, savedBuild := FlakeDBPath, CosaBuild
+	defer func() {
+		FlakeDBPath, CosaBuild = savedPath, savedBuild
+	}()
+	FlakeDBPath = path
+
+	if err := os.MkdirAll(filepath.Join(outputDir, "reports"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	report := `{"tests": [
+		{"name": "basic", "result": "PASS", "duration": 1000},
+		{"name": "skipped", "result": "SKIP"},
+		{"name": "non-exclusive-test-bucket-0", "result": "FAIL"},
+		{"name": "non-exclusive-test-bucket-0/ext.foo", "result": "FAIL"}
+	]}`
+	if err := os.WriteFile(filepath.Join(outputDir, "reports", "report.json"), []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// runs which aren't on a build aren't recorded
+	CosaBuild = nil
+	if err := RecordFlakeHistory(outputDir, "qemu"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no flake database, got %v", err)
+	}
+
+	CosaBuild = &util.LocalBuild{Meta: &cosa.Build{BuildID: "42"}}
+	for i := 0; i < 2; i++ {
+		if err := RecordFlakeHistory(outputDir, "qemu"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := LoadFlakeHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Tests) != 2 {
+		t.Fatalf("expected records for basic and ext.foo, got %+v", h.Tests)
+	}
+	record := h.Lookup("basic", "qemu", Options.CosaBuildArch)
+	if record == nil || len(record.Runs) != 1 || record.Runs[0].Build != "42" || record.Runs[0].Duration != 1000 {
+		t.Errorf("expected a single run of build 42, got %+v", record)
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"hash/fnv"
 	"io"
 	"math/rand"
 	"os"
@@ -122,8 +121,13 @@ var (
 
 	TestParallelism int    // glue var to set test parallelism from main
 	TAPFile         string // if not "", write TAP results here
+	JUnitFile       string // if not "", write JUnit XML results here
+	EventStream     string // if not "", write JSON-lines events here (see harness.OpenEventSink)
 	NoNet           bool   // Disable tests requiring Internet
 
+	// eventSink is opened from EventStream on the first run
+	eventSink *harness.EventSink
+
 	// reservedMemoryCountMiB tracks memory claimed by tests that have been
 	// scheduled but whose QEMU VMs may not have fully allocated yet.
 	// This prevents the thundering-herd problem at startup where all
@@ -138,6 +142,17 @@ var (
 	reservedHostPorts      = make(map[int]string) // port -> test name
 	reservedHostPortsMutex sync.Mutex
 
+	// reservedCPUs tracks the vCPUs of the VMs of running tests, so that
+	// tests with several or NUMA machines don't overcommit the host.
+	reservedCPUs      int
+	reservedCPUsMutex sync.Mutex
+
+	// reservedDiskMiB tracks scratch disk space claimed by running tests
+	// for their additional and resized disks. Like memory, the space is
+	// only consumed as the guests write to their disks.
+	reservedDiskMiB      int
+	reservedDiskMiBMutex sync.Mutex
+
 	// ForceRunPlatformIndependent will cause tests that claim platform-independence to run
 	ForceRunPlatformIndependent bool
 
@@ -148,7 +163,8 @@ var (
 	WarnOnErrorTests    []string // denylisted tests we are going to run and warn in case of error
 	Tags                []string // tags to be ran
 
-	// Sharding is a string of the form: hash:m/n where m and n are integers to run only tests which hash to m.
+	// Sharding is a string of the form: hash:m/n where m and n are integers to run only tests which hash to m,
+	// or duration:m/n to run the m-th of n shards balanced by expected test duration.
 	Sharding string
 
 	extTestNum  = 1 // Assigns a unique number to each non-exclusive external test
@@ -157,22 +173,17 @@ var (
 	nonexclusivePrefixMatch  = regexp.MustCompile(`^non-exclusive-test-bucket-[0-9]/`)
 	nonexclusiveWrapperMatch = regexp.MustCompile(`^non-exclusive-test-bucket-[0-9]$`)
 
-	consoleChecks = []struct {
-		desc     string
-		match    *regexp.Regexp
-		warnOnly bool
-		skipFlag *register.Flag
-	}{
+	consoleChecks = []consoleCheck{
 		{
-			desc:     "emergency shell",
-			match:    regexp.MustCompile("Press Enter for emergency shell|Starting Emergency Shell|You are in emergency mode"),
-			warnOnly: false,
-			skipFlag: &[]register.Flag{register.NoEmergencyShellCheck}[0],
+			desc:      "emergency shell",
+			match:     regexp.MustCompile("Press Enter for emergency shell|Starting Emergency Shell|You are in emergency mode"),
+			warnOnly:  false,
+			skipFlags: []register.Flag{register.NoEmergencyShellCheck},
 		},
 		{
-			desc:     "dracut fatal",
-			match:    regexp.MustCompile("dracut: Refusing to continue"),
-			skipFlag: &[]register.Flag{register.NoDracutFatalCheck}[0],
+			desc:      "dracut fatal",
+			match:     regexp.MustCompile("dracut: Refusing to continue"),
+			skipFlags: []register.Flag{register.NoDracutFatalCheck},
 		},
 		{
 			desc:  "kernel panic",
@@ -242,9 +253,24 @@ var (
 		},
 	}
 
+	// extraConsoleChecks are loaded from kola-console-checks.yaml
+	extraConsoleChecks []consoleCheck
+
+	// multipliedTests maps the copies of tests made by --multiply to the
+	// name of the original test
+	multipliedTests = make(map[string]string)
+
 	ErrWarnOnTestFail = errors.New("A test marked as warn:true failed.")
 )
 
+type consoleCheck struct {
+	desc      string
+	match     *regexp.Regexp
+	warnOnly  bool
+	skipFlags []register.Flag
+	skipTag   string
+}
+
 const (
 	// kolaExtBinDataDir is where data will be stored on the target (but use the environment variable)
 	kolaExtBinDataDir = "/var/opt/kola/extdata"
@@ -263,6 +289,9 @@ const (
 type KoletResult struct {
 	Reboot     string
 	SoftReboot string
+	// Subtests are the results reported by the test since it was started
+	// or since its last reboot
+	Subtests []ExtSubtestResult `json:",omitempty"`
 }
 
 const (
@@ -364,36 +393,42 @@ type DenyListObj struct {
 	Warn       bool     `yaml:"warn"`
 }
 
-func ParseDenyListYaml(pltfrm string) error {
+// getDenylistStream returns the stream used to scope entries in the config
+// repo YAML files, or the empty string if it could not be determined.
+func getDenylistStream() string {
+	// Get the stream variable from meta.json if DenylistStream is not specified
+	if len(DenylistStream) > 0 {
+		return DenylistStream
+	} else if !QEMUOptions.DiskImageIsUserProvided &&
+		CosaBuild != nil && CosaBuild.Meta != nil && CosaBuild.Meta.OciLabels != nil {
+		return string(CosaBuild.Meta.OciLabels["com.coreos.stream"])
+	}
+	return ""
+}
+
+// loadDenyList returns the entries of kola-denylist.yaml which apply to the
+// current stream, arch and pltfrm.
+func loadDenyList(pltfrm string) ([]DenyListObj, error) {
 	var objs []DenyListObj
 
 	// Parse kola-denylist into structs
 	pathToDenyList := filepath.Join(Options.CosaWorkdir, "src/config/kola-denylist.yaml")
 	denyListFile, err := os.ReadFile(pathToDenyList)
 	if os.IsNotExist(err) {
-		return nil
+		return nil, nil
 	} else if err != nil {
-		return err
+		return nil, err
 	}
 
 	plog.Debug("Found kola-denylist.yaml. Processing listed denials.")
 	err = yaml.Unmarshal(denyListFile, &objs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	plog.Debug("Parsed kola-denylist.yaml")
 
-	var stream string
-
-	// Get the stream variable from meta.json if DenylistStream is not specified
-	if len(DenylistStream) > 0 {
-		stream = DenylistStream
-	} else if !QEMUOptions.DiskImageIsUserProvided &&
-		CosaBuild != nil && CosaBuild.Meta != nil && CosaBuild.Meta.OciLabels != nil {
-		s := CosaBuild.Meta.OciLabels["com.coreos.stream"]
-		stream = string(s)
-	}
+	stream := getDenylistStream()
 	if stream == "" {
 		commonLog := "Stream-scoped denylist entries will not be applied (tests will not be skipped)."
 		if QEMUOptions.DiskImageIsUserProvided {
@@ -403,14 +438,12 @@ func ParseDenyListYaml(pltfrm string) error {
 		}
 	}
 
-	// Get the current arch & current time
+	// Get the current arch
 	arch := Options.CosaBuildArch
-	today := time.Now()
 
 	plog.Debugf("Denylist: Skipping tests for stream: '%s', arch: '%s'\n", stream, arch)
 
-	// Accumulate patterns filtering by set policies
-	plog.Debug("Processing denial patterns from yaml...")
+	var applicable []DenyListObj
 	for _, obj := range objs {
 		if len(obj.Arches) > 0 && !HasString(arch, obj.Arches) {
 			continue
@@ -424,6 +457,23 @@ func ParseDenyListYaml(pltfrm string) error {
 			continue
 		}
 
+		applicable = append(applicable, obj)
+	}
+	return applicable, nil
+}
+
+// ParseDenyListYaml announces the entries of kola-denylist.yaml which apply
+// to pltfrm and returns them. Which tests they skip or make failures
+// warnings for is decided by the denylist, see newDenylist.
+func ParseDenyListYaml(pltfrm string) ([]DenyListObj, error) {
+	objs, err := loadDenyList(pltfrm)
+	if err != nil {
+		return nil, err
+	}
+	today := time.Now()
+
+	plog.Debug("Processing denial patterns from yaml...")
+	for _, obj := range objs {
 		// Process "special" patterns which aren't test names, but influence overall behavior
 		if obj.Pattern == SkipConsoleWarningsTag {
 			SkipConsoleWarnings = true
@@ -433,25 +483,21 @@ func ParseDenyListYaml(pltfrm string) error {
 		if obj.SnoozeDate != "" {
 			snoozeDate, err := time.Parse(dateFormat, obj.SnoozeDate)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			if today.After(snoozeDate) {
 				fmt.Printf("⏰ Snooze for kola test pattern \"%s\" expired on %s\n", obj.Pattern, snoozeDate.Format("Jan 02 2006"))
 				if obj.Warn {
 					fmt.Printf("⚠️  Will warn on failure for kola test pattern \"%s\":\n", obj.Pattern)
-					WarnOnErrorTests = append(WarnOnErrorTests, obj.Pattern)
 				}
 			} else {
 				fmt.Printf("🕒  Snoozing kola test pattern \"%s\" until %s\n", obj.Pattern, snoozeDate.Format("Jan 02 2006"))
-				DenylistedTests = append(DenylistedTests, obj.Pattern)
 			}
 		} else {
 			if obj.Warn {
 				fmt.Printf("⚠️  Will warn on failure for kola test pattern \"%s\":\n", obj.Pattern)
-				WarnOnErrorTests = append(WarnOnErrorTests, obj.Pattern)
 			} else {
 				fmt.Printf("⏭️  Skipping kola test pattern \"%s\":\n", obj.Pattern)
-				DenylistedTests = append(DenylistedTests, obj.Pattern)
 			}
 		}
 		if obj.Tracker != "" {
@@ -459,135 +505,237 @@ func ParseDenyListYaml(pltfrm string) error {
 		}
 	}
 
-	return nil
+	return objs, nil
 }
 
-func filterTests(tests map[string]*register.Test, patterns []string, pltfrm string) (map[string]*register.Test, error) {
-	r := make(map[string]*register.Test)
+// ConsoleCheckObj is an additional console check from kola-console-checks.yaml
+type ConsoleCheckObj struct {
+	Desc      string   `yaml:"desc"`
+	Match     string   `yaml:"match"`
+	WarnOnly  bool     `yaml:"warnOnly"`
+	SkipTag   string   `yaml:"skipTag"`
+	SkipFlags []string `yaml:"skipFlags"`
+	Streams   []string `yaml:"streams"`
+	Arches    []string `yaml:"arches"`
+	Platforms []string `yaml:"platforms"`
+}
 
-	checkPlatforms := []string{pltfrm}
+// ParseConsoleChecksYaml loads the console checks from
+// src/config/kola-console-checks.yaml which apply to the current stream,
+// arch and pltfrm. They are used by CheckConsole in addition to the
+// built-in checks.
+func ParseConsoleChecksYaml(pltfrm string) error {
+	var objs []ConsoleCheckObj
 
-	// sort tags into include/exclude
-	positiveTags := []string{}
-	negativeTags := []string{}
-	for _, tag := range Tags {
-		if strings.HasPrefix(tag, "!") {
-			negativeTags = append(negativeTags, tag[1:])
-		} else {
-			positiveTags = append(positiveTags, tag)
-		}
+	extraConsoleChecks = nil
+
+	pathToChecks := filepath.Join(Options.CosaWorkdir, "src/config/kola-console-checks.yaml")
+	checksFile, err := os.ReadFile(pathToChecks)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	// Higher-level functions default to '*' if the user didn't pass anything.
-	// Notice this. (This totally ignores the corner case where the user
-	// actually typed '*').
-	userTypedPattern := !HasString("*", patterns)
-	for name, t := range tests {
-		if NoNet && testRequiresInternet(t) {
-			plog.Debugf("Skipping test that requires network: %s", t.Name)
+	plog.Debug("Found kola-console-checks.yaml. Processing console checks.")
+	err = yaml.Unmarshal(checksFile, &objs)
+	if err != nil {
+		return err
+	}
+
+	stream := getDenylistStream()
+	arch := Options.CosaBuildArch
+	for _, obj := range objs {
+		if obj.Desc == "" || obj.Match == "" {
+			return fmt.Errorf("%s: console checks require desc and match", pathToChecks)
+		}
+		if len(obj.Arches) > 0 && !HasString(arch, obj.Arches) {
 			continue
 		}
-
-		nameMatch, err := MatchesPatterns(t.Name, patterns)
+		if len(obj.Platforms) > 0 && !HasString(pltfrm, obj.Platforms) {
+			continue
+		}
+		if len(obj.Streams) > 0 && !HasString(stream, obj.Streams) {
+			continue
+		}
+		match, err := regexp.Compile(obj.Match)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("%s: compiling match for %q: %w", pathToChecks, obj.Desc, err)
 		}
-
-		tagMatch := false
-		for _, tag := range positiveTags {
-			tagMatch = HasString(tag, t.Tags) || tag == t.RequiredTag
-			if tagMatch {
-				break
+		var skipFlags []register.Flag
+		for _, name := range obj.SkipFlags {
+			flag, err := register.ParseFlag(name)
+			if err != nil {
+				return fmt.Errorf("%s: skipFlags of %q: %w", pathToChecks, obj.Desc, err)
 			}
+			skipFlags = append(skipFlags, flag)
 		}
+		plog.Debugf("Adding console check %q", obj.Desc)
+		extraConsoleChecks = append(extraConsoleChecks, consoleCheck{
+			desc:      obj.Desc,
+			match:     match,
+			warnOnly:  obj.WarnOnly,
+			skipFlags: skipFlags,
+			skipTag:   obj.SkipTag,
+		})
+	}
 
-		negativeTagMatch := false
-		for _, tag := range negativeTags {
-			negativeTagMatch = HasString(tag, t.Tags)
-			if negativeTagMatch {
-				break
-			}
-		}
-		if negativeTagMatch {
-			continue
-		}
+	return nil
+}
 
-		if t.RequiredTag != "" && // if the test has a required tag...
-			!HasString(t.RequiredTag, positiveTags) { // and that tag was not provided by the user
-			if userTypedPattern && nameMatch {
-				fmt.Printf("⏭️  Skipping kola test \"%s\" with required tag \"%s\"\n", t.Name, t.RequiredTag)
-			}
-			continue // then skip it
+// testFilter selects tests by name patterns, tags, platform, architecture,
+// distribution and firmware.
+type testFilter struct {
+	patterns     []string
+	pltfrm       string
+	positiveTags []string
+	negativeTags []string
+	// Higher-level functions default to '*' if the user didn't pass anything.
+	// Notice this. (This totally ignores the corner case where the user
+	// actually typed '*').
+	userTypedPattern bool
+}
+
+func newTestFilter(patterns []string, pltfrm string) *testFilter {
+	f := &testFilter{
+		patterns:         patterns,
+		pltfrm:           pltfrm,
+		positiveTags:     []string{},
+		negativeTags:     []string{},
+		userTypedPattern: !HasString("*", patterns),
+	}
+	// sort tags into include/exclude
+	for _, tag := range Tags {
+		if strings.HasPrefix(tag, "!") {
+			f.negativeTags = append(f.negativeTags, tag[1:])
+		} else {
+			f.positiveTags = append(f.positiveTags, tag)
 		}
+	}
+	return f
+}
 
-		if userTypedPattern {
-			// If the user explicitly typed a pattern, then the test *must*
-			// match by name or by tag. Otherwise, we skip it.
-			if !nameMatch && !tagMatch {
-				continue
-			}
+func isAllowed(item string, include, exclude []string) (bool, bool) {
+	allowed, excluded := true, false
+	for _, i := range include {
+		if i == item {
+			allowed = true
+			break
 		} else {
-			// If the user didn't explicitly type a pattern, then normally we
-			// accept all tests, but if they *did* specify tags, then we only
-			// accept tests which match those tags.
-			if len(positiveTags) > 0 && !tagMatch {
-				continue
-			}
+			allowed = false
 		}
+	}
+	for _, i := range exclude {
+		if i == item {
+			allowed = false
+			excluded = true
+		}
+	}
+	return allowed, excluded
+}
 
-		isAllowed := func(item string, include, exclude []string) (bool, bool) {
-			allowed, excluded := true, false
-			for _, i := range include {
-				if i == item {
-					allowed = true
-					break
-				} else {
-					allowed = false
-				}
-			}
-			for _, i := range exclude {
-				if i == item {
-					allowed = false
-					excluded = true
-				}
-			}
-			return allowed, excluded
+// skipReason returns why the test is not selected by the filter, or the
+// empty string if it is. requested is set if the user asked for the test
+// by name but it is skipped anyway.
+func (f *testFilter) skipReason(t *register.Test) (reason string, requested bool, err error) {
+	if NoNet && testRequiresInternet(t) {
+		return "requires network access, but --no-net was given", false, nil
+	}
+
+	nameMatch, err := MatchesPatterns(t.Name, f.patterns)
+	if err != nil {
+		return "", false, err
+	}
+
+	tagMatch := false
+	for _, tag := range f.positiveTags {
+		tagMatch = HasString(tag, t.Tags) || tag == t.RequiredTag
+		if tagMatch {
+			break
 		}
+	}
 
-		// For now, we hardcode platform independent tests to run only on one platform.
-		// But in the future, we should optimize this so that an overall
-		// test planner/scheduler knows to run the test at most once or twice.
-		// Platform independent tests could also run on AWS sometimes for example.
-		if !ForceRunPlatformIndependent {
-			for _, tag := range t.Tags {
-				if tag == PlatformIndependentTag {
-					t.Platforms = []string{defaultPlatformIndependentPlatform}
-				}
-			}
+	for _, tag := range f.negativeTags {
+		if HasString(tag, t.Tags) {
+			return fmt.Sprintf("has excluded tag %q", tag), false, nil
 		}
+	}
 
-		isExcluded := false
-		allowed := false
-		for _, platform := range checkPlatforms {
-			allowedPlatform, excluded := isAllowed(platform, t.Platforms, t.ExcludePlatforms)
-			if excluded {
-				isExcluded = true
-				break
+	if t.RequiredTag != "" && // if the test has a required tag...
+		!HasString(t.RequiredTag, f.positiveTags) { // and that tag was not provided by the user
+		return fmt.Sprintf("requires tag %q", t.RequiredTag), f.userTypedPattern && nameMatch, nil
+	}
+
+	if f.userTypedPattern {
+		// If the user explicitly typed a pattern, then the test *must*
+		// match by name or by tag. Otherwise, we skip it.
+		if !nameMatch && !tagMatch {
+			if len(f.positiveTags) > 0 {
+				return "matches neither the patterns nor the tags", false, nil
 			}
-			allowedArchitecture, _ := isAllowed(Options.CosaBuildArch, t.Architectures, t.ExcludeArchitectures)
-			allowed = allowed || (allowedPlatform && allowedArchitecture)
+			return "doesn't match the patterns", false, nil
 		}
-		if isExcluded || !allowed {
-			continue
+	} else {
+		// If the user didn't explicitly type a pattern, then normally we
+		// accept all tests, but if they *did* specify tags, then we only
+		// accept tests which match those tags.
+		if len(f.positiveTags) > 0 && !tagMatch {
+			return "doesn't match the tags", false, nil
 		}
+	}
 
-		if allowed, excluded := isAllowed(Options.Distribution, t.Distros, t.ExcludeDistros); !allowed || excluded {
-			continue
+	// For now, we hardcode platform independent tests to run only on one platform.
+	// But in the future, we should optimize this so that an overall
+	// test planner/scheduler knows to run the test at most once or twice.
+	// Platform independent tests could also run on AWS sometimes for example.
+	platforms := t.Platforms
+	if !ForceRunPlatformIndependent && HasString(PlatformIndependentTag, t.Tags) {
+		platforms = []string{defaultPlatformIndependentPlatform}
+	}
+
+	allowedPlatform, excluded := isAllowed(f.pltfrm, platforms, t.ExcludePlatforms)
+	if excluded {
+		return fmt.Sprintf("platform %s is excluded", f.pltfrm), false, nil
+	} else if !allowedPlatform {
+		return fmt.Sprintf("only runs on platforms %s", strings.Join(platforms, ", ")), false, nil
+	}
+	if allowed, excluded := isAllowed(Options.CosaBuildArch, t.Architectures, t.ExcludeArchitectures); excluded {
+		return fmt.Sprintf("architecture %s is excluded", Options.CosaBuildArch), false, nil
+	} else if !allowed {
+		return fmt.Sprintf("only runs on architectures %s", strings.Join(t.Architectures, ", ")), false, nil
+	}
+
+	if allowed, excluded := isAllowed(Options.Distribution, t.Distros, t.ExcludeDistros); excluded {
+		return fmt.Sprintf("distribution %s is excluded", Options.Distribution), false, nil
+	} else if !allowed {
+		return fmt.Sprintf("only runs on distributions %s", strings.Join(t.Distros, ", ")), false, nil
+	}
+	if f.pltfrm == "qemu" {
+		if allowed, excluded := isAllowed(QEMUOptions.Firmware, t.Firmwares, t.ExcludeFirmwares); excluded {
+			return fmt.Sprintf("firmware %s is excluded", QEMUOptions.Firmware), false, nil
+		} else if !allowed {
+			return fmt.Sprintf("only runs with firmwares %s", strings.Join(t.Firmwares, ", ")), false, nil
 		}
-		if pltfrm == "qemu" {
-			if allowed, excluded := isAllowed(QEMUOptions.Firmware, t.Firmwares, t.ExcludeFirmwares); !allowed || excluded {
-				continue
+	}
+	return "", false, nil
+}
+
+func filterTests(tests map[string]*register.Test, patterns []string, pltfrm string) (map[string]*register.Test, error) {
+	r := make(map[string]*register.Test)
+
+	filter := newTestFilter(patterns, pltfrm)
+	for name, t := range tests {
+		reason, requested, err := filter.skipReason(t)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			if requested {
+				fmt.Printf("⏭️  Skipping kola test \"%s\" with required tag \"%s\"\n", t.Name, t.RequiredTag)
+			} else if NoNet && testRequiresInternet(t) {
+				plog.Debugf("Skipping test that requires network: %s", t.Name)
 			}
+			continue
 		}
 
 		// Check native tests for arch-specific and distro-specfic exclusion
@@ -609,68 +757,51 @@ func filterTests(tests map[string]*register.Test, patterns []string, pltfrm stri
 	return r, nil
 }
 
-func filterDenylistedTests(tests map[string]*register.Test) (map[string]*register.Test, error) {
+// applyDenylist drops the tests and native tests skipped by the denylist
+// objs and --denylist-test, and makes failures warnings for the tests for
+// which the denylist, their grace period or flake quarantine says so.
+func applyDenylist(tests map[string]*register.Test, objs []DenyListObj, pltfrm string) (map[string]*register.Test, error) {
+	d, err := newDenylist(objs, pltfrm)
+	if err != nil {
+		return nil, err
+	}
 	r := make(map[string]*register.Test)
 	for name, t := range tests {
-		denylisted := false
-		// Detect anything which is denylisted directly or by pattern
-		for _, bl := range DenylistedTests {
-			nameMatch, err := filepath.Match(bl, t.Name)
-			if err != nil {
-				return nil, err
-			}
-			// If it matched the pattern this test is denylisted
-			if nameMatch {
-				denylisted = true
-				break
-			}
-
-			// Check if any native tests are denylisted. To exclude native tests, specify the high level
-			// test and a "/" and then the glob pattern.
-			// - basic/TestNetworkScripts: excludes only TestNetworkScripts
-			// - basic/* - excludes all
-			// - If no pattern is specified after / , excludes none
-			nativedenylistindex := strings.Index(bl, "/")
-			if nativedenylistindex > -1 {
-				// Check native tests for arch specific exclusion
-				for nativetestname := range t.NativeFuncs {
-					nameMatch, err := filepath.Match(bl[nativedenylistindex+1:], nativetestname)
-					if err != nil {
-						return nil, err
-					}
-					if nameMatch {
-						delete(t.NativeFuncs, nativetestname)
-					}
-				}
-			}
+		p, err := d.policy(t)
+		if err != nil {
+			return nil, err
 		}
-		// If the test is denylisted, skip it and continue to the next test
-		if denylisted {
-			plog.Debugf("Skipping denylisted test %s", t.Name)
+		if p.Skip != "" {
+			plog.Debugf("Skipping test %s: %s", name, p.Skip)
 			continue
 		}
+		for native := range p.DeniedNative {
+			delete(t.NativeFuncs, native)
+		}
+		for _, reason := range p.Warn {
+			fmt.Printf("⚠️  Failures of kola test \"%s\" will be warnings %s.\n", name, reason)
+		}
+		if len(p.Warn) > 0 {
+			WarnOnErrorTests = append(WarnOnErrorTests, name)
+		}
+		WarnOnErrorTests = append(WarnOnErrorTests, p.WarnSubtests...)
 		r[name] = t
 	}
 	return r, nil
 }
 
-func applyGracePeriodWarnings(tests map[string]*register.Test) error {
-	today := time.Now()
-	for name, test := range tests {
-		if test.CreationDate == "" {
-			continue
-		}
-		testCreationDate, err := time.Parse(dateFormat, test.CreationDate)
-		if err != nil {
-			return fmt.Errorf("Error parsing CreationDate for %s: %w", name, err)
-		}
-		gracePeriodEnds := testCreationDate.Add(gracePeriod)
-		if today.Before(gracePeriodEnds) {
-			fmt.Printf("⏳ Kola test \"%s\" is in grace period (created: %s, expires: %s), failures will be warnings.\n", name, test.CreationDate, gracePeriodEnds.Format(dateFormat))
-			WarnOnErrorTests = append(WarnOnErrorTests, name)
-		}
+// gracePeriodEnd returns when the grace period of a new test ends, during
+// which its failures are only warnings, or the zero time if the test has no
+// creation date.
+func gracePeriodEnd(test *register.Test) (time.Time, error) {
+	if test.CreationDate == "" {
+		return time.Time{}, nil
 	}
-	return nil
+	testCreationDate, err := time.Parse(dateFormat, test.CreationDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error parsing CreationDate for %s: %w", test.Name, err)
+	}
+	return testCreationDate.Add(gracePeriod), nil
 }
 
 // runProvidedTests is a harness for running multiple tests in parallel.
@@ -680,16 +811,13 @@ func applyGracePeriodWarnings(tests map[string]*register.Test) error {
 // logs and data will be written for analysis after the test run. If it already
 // exists it will be erased!
 func runProvidedTests(testsBank map[string]*register.Test, patterns []string, multiply int, rerun bool, pltfrm, outputDir string) error {
-	var versionStr string
-
-	// Avoid incurring cost of starting machine in getClusterSemver when
-	// either:
-	// 1) none of the selected tests care about the version
-	// 2) glob is an exact match which means minVersion will be ignored
-	//    either way
+	denylistObjs, err := ParseDenyListYaml(pltfrm)
+	if err != nil {
+		plog.Fatal(err)
+	}
 
-	// Add denylisted tests in kola-denylist.yaml to DenylistedTests
-	err := ParseDenyListYaml(pltfrm)
+	// Add console checks from kola-console-checks.yaml
+	err = ParseConsoleChecksYaml(pltfrm)
 	if err != nil {
 		plog.Fatal(err)
 	}
@@ -714,7 +842,7 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 		plog.Fatalf("There are no matching tests to run on this architecture/platform: %s %s", Options.CosaBuildArch, pltfrm)
 	}
 
-	tests, err = filterDenylistedTests(tests)
+	tests, err = applyDenylist(tests, denylistObjs, pltfrm)
 	if err != nil {
 		plog.Fatal(err)
 	}
@@ -724,48 +852,106 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 		return nil
 	}
 
-	err = applyGracePeriodWarnings(tests)
+	durations, err := loadExpectedDurations(tests, pltfrm)
 	if err != nil {
 		plog.Fatal(err)
 	}
 
+	if len(Workers) > 0 {
+		if multiply > 1 {
+			plog.Fatal("--multiply is not supported when dispatching tests to workers")
+		}
+		return runDistributedTests(testsBank, tests, durations, rerun, pltfrm, outputDir)
+	}
+
 	flight, err := NewFlight(pltfrm)
 	if err != nil {
 		plog.Fatalf("Flight failed: %v", err)
 	}
 	defer flight.Destroy()
 	// Generate non-exclusive test wrapper (run multiple tests in one VM)
+	if _, err := bucketNonExclusiveTests(tests, durations, flight); err != nil {
+		plog.Fatal(err)
+	}
+
+	if multiply > 1 {
+		newTests := make(map[string]*register.Test)
+		for name, t := range tests {
+			delete(register.Tests, name)
+			for i := 0; i < multiply; i++ {
+				newName := fmt.Sprintf("%s%d", name, i)
+				newT := *t
+				newT.Name = newName
+				newTests[newName] = &newT
+				multipliedTests[newName] = name
+				durations[newName] = durations[name]
+				register.RegisterTest(&newT)
+			}
+		}
+		tests = newTests
+	}
+
+	tests, err = shardTests(tests, Sharding, durations)
+	if err != nil {
+		plog.Fatalf("%v", err)
+	}
+
+	var htests harness.Tests
+	for _, test := range tests {
+		test := test // for the closure
+		run := func(h *harness.H) {
+			defer func() {
+				// Keep track of failed tests for a rerun
+				testResults.add(h)
+			}()
+			// We launch a seperate cluster for each kola test
+			// At the end of the test, its cluster is destroyed
+			runTest(h, test, pltfrm, flight)
+		}
+		htests.Add(test.Name, run, (test.Timeout*time.Duration(100+(Options.ExtendTimeoutPercent)))/100)
+		htests.SetExpectedDuration(test.Name, durations[test.Name])
+	}
+
+	return runSuite(testsBank, htests, TestParallelism, multiply, rerun, pltfrm, outputDir)
+}
+
+// bucketNonExclusiveTests replaces the non-exclusive tests in tests with
+// wrappers which run them together in buckets, and adds the expected
+// durations of the wrappers to durations. Buckets whose config is too large
+// for flight are split; with a nil flight, they never are. It returns the
+// tests of each wrapper.
+func bucketNonExclusiveTests(tests map[string]*register.Test, durations testDurations, flight platform.Flight) (map[string][]*register.Test, error) {
 	var nonExclusiveTests []*register.Test
 	for _, test := range tests {
 		if test.NonExclusive {
 			if test.ExternalTest == "" {
-				plog.Fatalf("Tests compiled in kola must be exclusive: %v", test.Name)
+				return nil, fmt.Errorf("Tests compiled in kola must be exclusive: %v", test.Name)
 			}
 			nonExclusiveTests = append(nonExclusiveTests, test)
 			delete(tests, test.Name)
 		}
 	}
 
+	wrappers := make(map[string][]*register.Test)
 	if len(nonExclusiveTests) == 1 {
 		// If there is only one test then it can just be run by itself
 		// so add it back to the tests map.
 		tests[nonExclusiveTests[0].Name] = nonExclusiveTests[0]
 	} else if len(nonExclusiveTests) > 0 {
-		buckets := createTestBuckets(nonExclusiveTests)
+		buckets := createTestBuckets(nonExclusiveTests, durations)
 		numBuckets := len(buckets)
 		for i := 0; i < numBuckets; {
 			// This test does not need to be registered since it is temporarily
 			// created to be used as a wrapper
 			nonExclusiveWrapper := makeNonExclusiveTest(i, buckets[i], flight)
-			if flight.ConfigTooLarge(*nonExclusiveWrapper.UserData) {
+			if flight != nil && flight.ConfigTooLarge(*nonExclusiveWrapper.UserData) {
 				// Since the merged config size is too large, we will split the bucket into
 				// two buckets
 				numTests := len(buckets[i])
 				if numTests == 1 {
 					// This test bucket cannot be split further so the single test config
 					// must be too large
-					err = fmt.Errorf("test %v has a config that is too large", buckets[i][0].Name)
-					plog.Fatal(err)
+					return nil, fmt.Errorf("test %v has a config that is too large", buckets[i][0].Name)
 				}
 				newBucket1 := buckets[i][:numTests/2]
 				newBucket2 := buckets[i][numTests/2:]
@@ -778,54 +964,42 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 				numBuckets++
 			} else {
 				tests[nonExclusiveWrapper.Name] = &nonExclusiveWrapper
+				wrappers[nonExclusiveWrapper.Name] = buckets[i]
+				for _, test := range buckets[i] {
+					durations[nonExclusiveWrapper.Name] += durations[test.Name]
+				}
 				i++ // Move to the next bucket to evaluate
 			}
 		}
 	}
+	return wrappers, nil
+}
 
-	if multiply > 1 {
-		newTests := make(map[string]*register.Test)
-		for name, t := range tests {
-			delete(register.Tests, name)
-			for i := 0; i < multiply; i++ {
-				newName := fmt.Sprintf("%s%d", name, i)
-				newT := *t
-				newT.Name = newName
-				newTests[newName] = &newT
-				register.RegisterTest(&newT)
-			}
-		}
-		tests = newTests
-	}
-
-	tests, err = shardTests(tests, Sharding)
-	if err != nil {
-		plog.Fatalf("%v", err)
-	}
-
+// runSuite runs htests in a harness suite writing to outputDir and reruns
+// the tests from testsBank which failed if rerun is set.
+func runSuite(testsBank map[string]*register.Test, htests harness.Tests, parallel, multiply int, rerun bool, pltfrm, outputDir string) error {
 	opts := harness.Options{
 		OutputDir: outputDir,
-		Parallel:  TestParallelism,
+		Parallel:  parallel,
 		Sharding:  Sharding,
 		Verbose:   true,
 		Reporters: reporters.Reporters{
-			reporters.NewJSONReporter("report.json", pltfrm, versionStr),
+			reporters.NewJSONReporter("report.json", pltfrm, ""),
 		},
 	}
-
-	var htests harness.Tests
-	for _, test := range tests {
-		test := test // for the closure
-		run := func(h *harness.H) {
-			defer func() {
-				// Keep track of failed tests for a rerun
-				testResults.add(h)
-			}()
-			// We launch a seperate cluster for each kola test
-			// At the end of the test, its cluster is destroyed
-			runTest(h, test, pltfrm, flight)
+	if JUnitFile != "" {
+		opts.Reporters = append(opts.Reporters, reporters.NewJUnitReporter("junit.xml", pltfrm, ""))
+	}
+	if EventStream != "" {
+		// shared with the rerun, if any
+		if eventSink == nil {
+			var err error
+			eventSink, err = harness.OpenEventSink(EventStream)
+			if err != nil {
+				return errors.Wrapf(err, "opening event stream")
+			}
 		}
-		htests.Add(test.Name, run, (test.Timeout*time.Duration(100+(Options.ExtendTimeoutPercent)))/100)
+		opts.Events = eventSink
 	}
 
 	handleSuiteErrors := func(outputDir string, suiteErr error) error {
@@ -839,6 +1013,14 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 			}
 		}
 
+		if JUnitFile != "" {
+			src := filepath.Join(outputDir, "reports", "junit.xml")
+			err := system.CopyRegularFile(src, JUnitFile)
+			if suiteErr == nil && err != nil {
+				return err
+			}
+		}
+
 		if caughtTestError {
 			fmt.Printf("FAIL, output in %v\n", outputDir)
 		} else {
@@ -925,7 +1107,14 @@ func GetRerunnableTestName(testName string) (string, bool) {
 	return name, true
 }
 
+// IsWarningOnFailure returns whether failures of the test or subtest
+// testName are only warnings. Copies of tests made by --multiply are
+// matched by the name of the original test.
 func IsWarningOnFailure(testName string) bool {
+	base, _, _ := strings.Cut(testName, "/")
+	if orig, ok := multipliedTests[base]; ok {
+		testName = orig + strings.TrimPrefix(testName, base)
+	}
 	for _, pattern := range WarnOnErrorTests {
 		found, err := filepath.Match(pattern, testName)
 		if err != nil {
@@ -970,11 +1159,29 @@ func getRerunnable(testsBank map[string]*register.Test, testResults []*harness.H
 }
 
 func RunTests(patterns []string, multiply int, rerun bool, pltfrm, outputDir string) error {
-	return runProvidedTests(register.Tests, patterns, multiply, rerun, pltfrm, outputDir)
+	runErr := runProvidedTests(register.Tests, patterns, multiply, rerun, pltfrm, outputDir)
+	return closeEventSink(runErr)
 }
 
 func RunUpgradeTests(patterns []string, rerun bool, pltfrm, outputDir string) error {
-	return runProvidedTests(register.UpgradeTests, patterns, 0, rerun, pltfrm, outputDir)
+	runErr := runProvidedTests(register.UpgradeTests, patterns, 0, rerun, pltfrm, outputDir)
+	return closeEventSink(runErr)
+}
+
+// closeEventSink closes the event stream at the end of a run, including
+// its rerun. Errors writing the events are returned unless the run failed.
+func closeEventSink(runErr error) error {
+	err := eventSink.Close()
+	eventSink = nil
+	if err == nil {
+		return runErr
+	}
+	err = errors.Wrapf(err, "writing event stream")
+	if runErr != nil {
+		plog.Errorf("%v", err)
+		return runErr
+	}
+	return err
 }
 
 // externalTestMeta is parsed from kola.json in external tests
@@ -1067,26 +1274,8 @@ func metadataFromTestBinary(executable string) (*externalTestMeta, error) {
 // See README-kola-ext.md as well as the comments in kolet.go for reboot
 // handling.
 func runExternalTest(c cluster.TestCluster, mach platform.Machine, testNum int) error {
-	var previousRebootState string
-	for {
-		bootID, err := platform.GetMachineBootId(mach)
-		if err != nil {
-			return errors.Wrapf(err, "getting boot id")
-		}
-		softrebootCount, err := platform.GetMachineSoftRebootCount(mach)
-		if err != nil {
-			return errors.Wrapf(err, "getting soft reboot count")
-		}
+	return cluster.RunRebootPhases(mach, func(mark string) (cluster.PhaseEnd, error) {
 		plog.Debug("Starting kolet run-test-unit")
-		if previousRebootState != "" {
-			// quote around the value for systemd
-			contents := fmt.Sprintf("AUTOPKGTEST_REBOOT_MARK='%s'", previousRebootState)
-			plog.Debugf("Setting %s", contents)
-			if err := platform.InstallFile(strings.NewReader(contents), mach, "/run/kola-runext-env"); err != nil {
-				return err
-			}
-		}
-
 		var cmd string
 		if testNum != 0 {
 			// This is a non-exclusive test
@@ -1099,58 +1288,50 @@ func runExternalTest(c cluster.TestCluster, mach platform.Machine, testNum int)
 		}
 		stdout, stderr, err := mach.SSH(cmd)
 		if err != nil {
-			return errors.Wrapf(err, "kolet run-test-unit failed: %s %s", string(stdout), string(stderr))
+			// kolet still passes on the subtest results if the test failed
+			var failedRes KoletResult
+			if json.Unmarshal(stdout, &failedRes) == nil {
+				reportExtSubtests(c.H, failedRes.Subtests)
+			}
+			return cluster.PhaseEnd{}, errors.Wrapf(err, "kolet run-test-unit failed: %s %s", string(stdout), string(stderr))
 		}
 
 		koletRes := KoletResult{}
 		if len(stdout) > 0 {
 			err = json.Unmarshal(stdout, &koletRes)
 			if err != nil {
-				return errors.Wrapf(err, "parsing kolet json %s", string(stdout))
+				return cluster.PhaseEnd{}, errors.Wrapf(err, "parsing kolet json %s", string(stdout))
 			}
 		}
-		// If no reboot or soft-reboot is requested, we're done
-		if koletRes.Reboot == "" && koletRes.SoftReboot == "" {
-			return nil
-		}
+		reportExtSubtests(c.H, koletRes.Subtests)
 
-		// Handle regular reboot
+		// The subject reboots itself once we acknowledge the request; this
+		// signals to it that we have saved the mark.
 		if koletRes.Reboot != "" {
-			previousRebootState = koletRes.Reboot
-			plog.Debugf("Reboot request with mark='%s'", previousRebootState)
-			// This signals to the subject that we have saved the mark, and the subject
-			// can proceed with rebooting.  We stop sshd to ensure that the wait below
-			// doesn't log in while ssh is shutting down.
-			_, _, err = mach.SSH(fmt.Sprintf("sudo /bin/sh -c 'systemctl stop sshd && echo > %s'", KoletRebootAckFifo))
-			if err != nil {
-				return errors.Wrapf(err, "failed to acknowledge reboot")
-			}
-			plog.Debug("Waiting for reboot")
-			err = mach.WaitForReboot(120*time.Second, bootID)
-			if err != nil {
-				return errors.Wrapf(err, "Waiting for reboot")
-			}
-			plog.Debug("Reboot complete")
+			return cluster.PhaseEnd{
+				Reboot: cluster.Reboot,
+				Mark:   koletRes.Reboot,
+				Start: func() error {
+					// We stop sshd to ensure that the wait for the reboot
+					// doesn't log in while ssh is shutting down.
+					_, _, err := mach.SSH(fmt.Sprintf("sudo /bin/sh -c 'systemctl stop sshd && echo > %s'", KoletRebootAckFifo))
+					return errors.Wrapf(err, "failed to acknowledge reboot")
+				},
+			}, nil
 		}
-
-		// Handle soft-reboot
 		if koletRes.SoftReboot != "" {
-			previousRebootState = koletRes.SoftReboot
-			plog.Debugf("Soft-reboot request with mark='%s'", previousRebootState)
-			// Use the soft reboot count we collected at the beginning of this loop iteration
-			// Acknowledge the soft-reboot request
-			_, _, err = mach.SSH(fmt.Sprintf("sudo /bin/sh -c 'echo > %s'", KoletRebootAckFifo))
-			if err != nil {
-				return errors.Wrapf(err, "failed to acknowledge soft-reboot")
-			}
-			plog.Debug("Waiting for soft-reboot")
-			err = mach.WaitForSoftReboot(120*time.Second, softrebootCount)
-			if err != nil {
-				return errors.Wrapf(err, "Waiting for soft-reboot")
-			}
-			plog.Debug("Soft-reboot complete")
+			return cluster.PhaseEnd{
+				Reboot: cluster.SoftReboot,
+				Mark:   koletRes.SoftReboot,
+				Start: func() error {
+					_, _, err := mach.SSH(fmt.Sprintf("sudo /bin/sh -c 'echo > %s'", KoletRebootAckFifo))
+					return errors.Wrapf(err, "failed to acknowledge soft-reboot")
+				},
+			}, nil
 		}
-	}
+		// If no reboot or soft-reboot is requested, we're done
+		return cluster.PhaseEnd{}, nil
+	})
 }
 
 func registerExternalTest(testname, executable, dependencydir string, userdata *conf.UserData, baseMeta externalTestMeta) error {
@@ -1193,13 +1374,14 @@ func registerExternalTest(testname, executable, dependencydir string, userdata *
 	unit := fmt.Sprintf(`[Unit]
 [Service]
 RemainAfterExit=yes
-EnvironmentFile=-/run/kola-runext-env
+EnvironmentFile=-%s
 Environment=KOLA_UNIT=%s
 Environment=KOLA_TEST=%s
 Environment=KOLA_TEST_EXE=%s
 Environment=%s=%s
+Environment=%s=%s
 ExecStart=%s
-`, unitName, testname, base, kolaExtBinDataEnv, destDataDir, remotepath)
+`, cluster.RebootMarkEnvFile, unitName, testname, base, kolaExtBinDataEnv, destDataDir, kolaExtResultsEnv, ExtTestResultsPath(unitName), remotepath)
 	if targetMeta.InjectContainer {
 		if CosaBuild == nil {
 			return fmt.Errorf("test %v uses injectContainer, but no cosa build found", testname)
@@ -1329,8 +1511,33 @@ func patternMatchesTests(pattern string, tests map[string]*register.Test) (bool,
 	return false, nil
 }
 
-// registerTestDir parses one test directory and registers it as a test
-func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
+// extTest is an external test found by extTestWalker
+type extTest struct {
+	name          string
+	executable    string
+	dependencydir string
+	userdata      *conf.UserData
+	// meta is parsed from kola.json
+	meta externalTestMeta
+}
+
+// extTestWalker finds the external tests in a test directory and its
+// subdirectories.
+type extTestWalker struct {
+	// visit is called for each test found
+	visit func(t extTest) error
+	// fail is called for problems with a file of a test directory. The
+	// walk continues without the file if it returns nil.
+	fail func(path string, err error) error
+	// warn is called for problems which don't prevent running tests
+	warn func(path, msg string)
+	// lint is called for leftovers which are ignored when running tests,
+	// with a warning, but which kola lint-ext reports as errors
+	lint func(path, msg string)
+}
+
+// walk parses one test directory and visits its tests
+func (w *extTestWalker) walk(dir, testprefix string, children []os.DirEntry) error {
 	var dependencydir string
 	var meta externalTestMeta
 	userdata := conf.EmptyIgnition()
@@ -1338,14 +1545,20 @@ func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
 	for _, e := range children {
 		c, err := e.Info()
 		if err != nil {
-			return fmt.Errorf("getting info for %q: %w", e.Name(), err)
+			if err := w.fail(filepath.Join(dir, e.Name()), fmt.Errorf("getting info for %q: %w", e.Name(), err)); err != nil {
+				return err
+			}
+			continue
 		}
 		fpath := filepath.Join(dir, c.Name())
 		// follow symlinks; oddly, there's no IsSymlink()
 		if c.Mode()&os.ModeSymlink != 0 {
 			c, err = os.Stat(fpath)
 			if err != nil {
-				return errors.Wrapf(err, "stat %s", fpath)
+				if err := w.fail(fpath, errors.Wrapf(err, "stat %s", fpath)); err != nil {
+					return err
+				}
+				continue
 			}
 		}
 		isreg := c.Mode().IsRegular()
@@ -1358,7 +1571,7 @@ func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
 			}
 			userdata = conf.Ignition(string(v))
 		} else if isreg && c.Name() == "config.fcc" {
-			return errors.Wrapf(err, "%s is not supported anymore; rename it to config.bu", c.Name())
+			w.lint(fpath, "config.fcc is not supported anymore and is ignored; rename it to config.bu")
 		} else if isreg && (c.Name() == "config.bu") {
 			v, err := os.ReadFile(filepath.Join(dir, c.Name()))
 			if err != nil {
@@ -1366,22 +1579,20 @@ func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
 			}
 			userdata = conf.Butane(string(v))
 		} else if isreg && c.Name() == "kola.json" {
-			f, err := os.Open(fpath)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			dec := json.NewDecoder(f)
-			dec.DisallowUnknownFields()
-			if err := dec.Decode(&meta); err != nil {
-				return errors.Wrapf(err, "parsing %s", fpath)
+			if err := parseExternalTestMeta(fpath, &meta); err != nil {
+				if err := w.fail(fpath, err); err != nil {
+					return err
+				}
 			}
 		} else if c.IsDir() && c.Name() == kolaExtBinDataName {
 			dependencydir = filepath.Join(dir, c.Name())
 		} else if c.Mode()&os.ModeSymlink != 0 && c.Name() == kolaExtBinDataName {
 			target, err := filepath.EvalSymlinks(filepath.Join(dir, c.Name()))
 			if err != nil {
-				return err
+				if err := w.fail(fpath, err); err != nil {
+					return err
+				}
+				continue
 			}
 			dependencydir = target
 		} else if c.IsDir() {
@@ -1391,7 +1602,7 @@ func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
 				return err
 			}
 			subprefix := fmt.Sprintf("%s.%s", testprefix, c.Name())
-			if err := registerTestDir(subdir, subprefix, subchildren); err != nil {
+			if err := w.walk(subdir, subprefix, subchildren); err != nil {
 				return err
 			}
 		} else if isreg && (c.Mode().Perm()&0001) == 0 {
@@ -1402,8 +1613,9 @@ func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
 			scanner := bufio.NewScanner(file)
 			scanner.Scan()
 			if strings.HasPrefix(scanner.Text(), "#!") {
-				plog.Warningf("Found non-executable file with shebang: %s\n", c.Name())
+				w.warn(fpath, "non-executable file with shebang")
 			}
+			file.Close()
 		}
 	}
 
@@ -1412,17 +1624,13 @@ func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
 		if len(executables) > 1 || filepath.Base(executable) != InstalledTestDefaultTest {
 			testname = fmt.Sprintf("%s.%s", testname, filepath.Base(executable))
 		}
-
-		// don't even register the test if it's denied; this allows us to avoid
-		// erroring on Ignition config versions which we can't parse
-		if denied, err := testIsDenyListed(testname); err != nil {
-			return err
-		} else if denied {
-			plog.Debugf("Skipping denylisted external test %s", testname)
-			continue
-		}
-
-		err := registerExternalTest(testname, executable, dependencydir, userdata, meta)
+		err := w.visit(extTest{
+			name:          testname,
+			executable:    executable,
+			dependencydir: dependencydir,
+			userdata:      userdata,
+			meta:          meta,
+		})
 		if err != nil {
 			return err
 		}
@@ -1431,6 +1639,48 @@ func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
 	return nil
 }
 
+// parseExternalTestMeta parses the kola.json at path into meta
+func parseExternalTestMeta(path string, meta *externalTestMeta) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(meta); err != nil {
+		return errors.Wrapf(err, "parsing %s", path)
+	}
+	return nil
+}
+
+// registerTestDir parses one test directory and registers its tests
+func registerTestDir(dir, testprefix string, children []os.DirEntry) error {
+	w := extTestWalker{
+		visit: func(t extTest) error {
+			// don't even register the test if it's denied; this allows us to avoid
+			// erroring on Ignition config versions which we can't parse
+			if denied, err := testIsDenyListed(t.name); err != nil {
+				return err
+			} else if denied {
+				plog.Debugf("Skipping denylisted external test %s", t.name)
+				return nil
+			}
+			return registerExternalTest(t.name, t.executable, t.dependencydir, t.userdata, t.meta)
+		},
+		fail: func(path string, err error) error {
+			return err
+		},
+		warn: func(path, msg string) {
+			plog.Warningf("Found %s: %s\n", msg, filepath.Base(path))
+		},
+		lint: func(path, msg string) {
+			plog.Warningf("%s: %s", path, msg)
+		},
+	}
+	return w.walk(dir, testprefix, children)
+}
+
 func RegisterExternalTestsWithPrefix(dir, prefix string) error {
 	testsdir := filepath.Join(dir, "tests/kola")
 	children, err := os.ReadDir(testsdir)
@@ -1492,7 +1742,9 @@ func collectLogsExternalTest(h *harness.H, t *register.Test, tcluster cluster.Te
 	}
 }
 
-func createTestBuckets(tests []*register.Test) [][]*register.Test {
+// createTestBuckets distributes non-exclusive tests into as few buckets
+// as their conflicts allow, balancing the expected duration of the buckets.
+func createTestBuckets(tests []*register.Test, durations testDurations) [][]*register.Test {
 	// Make an array of maps. Each entry in the array represents a
 	// test bucket. Each corresponding map is the test.Name -> *register.Test
 	// mapping for tests to be executed
@@ -1518,12 +1770,13 @@ func createTestBuckets(tests []*register.Test) [][]*register.Test {
 		}
 	}
 
-	// Distribute into buckets. Start by creating a bucket with the
-	// first test in it and then going from there.
-	bucketInfo = append(bucketInfo, map[string]*register.Test{tests[0].Name: tests[0]})
-mainloop:
-	for _, test := range tests[1:] {
-		for _, bucket := range bucketInfo {
+	// Distribute into buckets, longest test first. Each test goes to the
+	// bucket with the least expected duration not used by a conflicting
+	// test.
+	var bucketDurations []time.Duration
+	for _, test := range durations.longestFirst(tests) {
+		best := -1
+		for i, bucket := range bucketInfo {
 			// Check if this bucket is being used by a conflicting test
 			foundConflict := false
 			for _, conflict := range test.Conflicts {
@@ -1531,14 +1784,18 @@ mainloop:
 					foundConflict = true
 				}
 			}
-			if !foundConflict {
-				// No Conflict here. Assign the test and continue.
-				bucket[test.Name] = test
-				continue mainloop
+			if !foundConflict && (best == -1 || bucketDurations[i] < bucketDurations[best]) {
+				best = i
 			}
 		}
-		// No eligible buckets found for test. Create a new bucket.
-		bucketInfo = append(bucketInfo, map[string]*register.Test{test.Name: test})
+		if best == -1 {
+			// No eligible buckets found for test. Create a new bucket.
+			bucketInfo = append(bucketInfo, make(map[string]*register.Test))
+			bucketDurations = append(bucketDurations, 0)
+			best = len(bucketInfo) - 1
+		}
+		bucketInfo[best][test.Name] = test
+		bucketDurations[best] += durations[test.Name]
 	}
 
 	// Convert the bucketInfo array of maps into an two dimensional
@@ -1550,51 +1807,12 @@ mainloop:
 		for _, test := range bucket {
 			bucketTests = append(bucketTests, test)
 		}
-		buckets = append(buckets, bucketTests)
+		buckets = append(buckets, durations.longestFirst(bucketTests))
 	}
 
 	return buckets
 }
 
-// shardTests filters tests to a particular shard - i.e. a group of tests
-// whose name hashes to the same value.
-func shardTests(tests map[string]*register.Test, sharding string) (map[string]*register.Test, error) {
-	if sharding == "" {
-		return tests, nil
-	}
-	if !strings.HasPrefix(sharding, "hash:") {
-		return nil, fmt.Errorf("invalid sharding syntax: %s", sharding)
-	}
-	parts := strings.SplitN(sharding[len("hash:"):], "/", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid sharding syntax: %s", sharding)
-	}
-	mv, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return nil, fmt.Errorf("invalid sharding syntax '%s': %w", sharding, err)
-	}
-	nv, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return nil, fmt.Errorf("invalid sharding syntax '%s': %w", sharding, err)
-	}
-	if mv > nv || nv < 1 || mv < 1 {
-		return nil, fmt.Errorf("invalid sharding in '%s'", sharding)
-	}
-	m := uint(mv)
-	n := uint(nv)
-
-	ret := make(map[string]*register.Test)
-	for name, test := range tests {
-		h := fnv.New64()
-		h.Write([]byte(name))
-		d := uint(h.Sum64()%uint64(n)) + 1
-		if d == m {
-			ret[name] = test
-		}
-	}
-	return ret, nil
-}
-
 // Create a parent test that runs non-exclusive tests as subtests
 func makeNonExclusiveTest(bucket int, tests []*register.Test, flight platform.Flight) register.Test {
 	// Parse test flags and gather configs
@@ -1659,6 +1877,15 @@ func makeNonExclusiveTest(bucket int, tests []*register.Test, flight platform.Fl
 						H:       h,
 						Cluster: tcluster.Cluster,
 					}
+					// The machine is shared by the tests of the bucket, so
+					// collect the diagnostics of a failed test right away,
+					// into its own output directory. Deferred first so that
+					// it runs after the logs are collected.
+					defer func() {
+						if h.Failed() {
+							collectDiagnostics(h, newTC.Machines())
+						}
+					}()
 					// Install external test executable
 					if t.ExternalTest != "" {
 						setupExternalTest(h, t, newTC)
@@ -1831,6 +2058,163 @@ func releaseHostPorts(t *register.Test) {
 	plog.Debugf("Released host ports %v for %s", ports, t.Name)
 }
 
+// getNeededCPUs returns the number of vCPUs used by the QEMU VMs for this
+// test. It mirrors the defaults in platform/qemu.go:QemuBuilder.Exec.
+func getNeededCPUs(t *register.Test) int {
+	cpus := 1
+	if t.MachineOptions.NumaNodes {
+		cpus = 2
+	}
+	if t.ClusterSize > 1 {
+		cpus *= t.ClusterSize
+	}
+	return cpus
+}
+
+// getNeededDiskMiB returns the scratch disk space in MiB that the QEMU VMs
+// for this test may use for their additional and resized disks. Like
+// platform/machine/qemu/cluster.go, MinDiskSize overrides the size of
+// PrimaryDisk.
+func getNeededDiskMiB(t *register.Test) int {
+	sizeGiB := int64(t.MachineOptions.MinDiskSize)
+	specs := t.MachineOptions.AdditionalDisks
+	if t.MachineOptions.PrimaryDisk != "" && sizeGiB == 0 {
+		specs = append([]string{t.MachineOptions.PrimaryDisk}, specs...)
+	}
+	for _, spec := range specs {
+		size, _, err := util.ParseDiskSpec(spec, true)
+		if err != nil {
+			// will be caught when the machine is created
+			continue
+		}
+		sizeGiB += size
+	}
+	if t.ClusterSize > 1 {
+		sizeGiB *= int64(t.ClusterSize)
+	}
+	return int(sizeGiB * 1024)
+}
+
+func reserveCPUsForTest(t *register.Test, needed, total int) bool {
+	reservedCPUsMutex.Lock()
+	defer reservedCPUsMutex.Unlock()
+	// Always let a test run if nothing else is, even if it needs more
+	// CPUs than the host has.
+	if reservedCPUs == 0 || reservedCPUs+needed <= total {
+		reservedCPUs += needed
+		t.ReservedCPUs = needed
+		plog.Debugf("Reserved %d CPUs for %s (total: %d, reserved total: %d)",
+			needed, t.Name, total, reservedCPUs)
+		return true
+	}
+	plog.Debugf("Waiting on CPUs to run %s: need %d, reserved %d of %d",
+		t.Name, needed, reservedCPUs, total)
+	return false
+}
+
+// waitForCPUs waits until enough of the host's CPUs are free to run this
+// test's VMs and reserves them until releaseCPUs() is called.
+func waitForCPUs(h *harness.H, flight platform.Flight, t *register.Test) {
+	if flight.Platform() != "qemu" {
+		return
+	}
+	total, err := system.GetProcessors()
+	if err != nil {
+		plog.Warningf("Failed to check available CPUs, proceeding: %v", err)
+		return
+	}
+	needed := getNeededCPUs(t)
+	for !reserveCPUsForTest(t, needed, int(total)) {
+		// sleep between 0 and 20 seconds and try again
+		time.Sleep(time.Duration(rand.Intn(20)) * time.Second)
+	}
+}
+
+// releaseCPUs returns a test's reserved CPUs back to the pool. This should
+// be called when the test completes and its QEMU VMs have been destroyed.
+func releaseCPUs(t *register.Test) {
+	reservedCPUsMutex.Lock()
+	defer reservedCPUsMutex.Unlock()
+	reservedCPUs -= t.ReservedCPUs
+	t.ReservedCPUs = 0
+}
+
+func reserveDiskForTest(t *register.Test, needed int, dirs []string, warnOnWait bool) bool {
+	reservedDiskMiBMutex.Lock()
+	defer reservedDiskMiBMutex.Unlock()
+	var avail uint
+	for i, dir := range dirs {
+		a, err := system.GetDiskAvailableMiB(dir)
+		if err != nil {
+			plog.Warningf("Failed to check available disk space, proceeding: %v", err)
+			return true
+		}
+		if i == 0 || a < avail {
+			avail = a
+		}
+	}
+	// Effective available = what is free on disk minus what we've
+	// handed out but the guests may not have written yet.
+	effective := int(avail) - reservedDiskMiB
+	if effective >= needed || reservedDiskMiB == 0 {
+		if effective < needed {
+			plog.Warningf("Running %s with only %d MiB of the %d MiB of disk space it may use available",
+				t.Name, effective, needed)
+		}
+		reservedDiskMiB += needed
+		t.ReservedDiskMiB = needed
+		plog.Debugf("Reserved %d MiB of disk space for %s (available: %d MiB, reserved total: %d MiB)",
+			needed, t.Name, avail, reservedDiskMiB)
+		return true
+	}
+	logger := plog.Debugf
+	if warnOnWait {
+		logger = plog.Warningf
+	}
+	logger("Waiting on disk space to run %s: need %d MiB, effective available %d MiB (filesystem: %d MiB, reserved: %d MiB)",
+		t.Name, needed, effective, avail, reservedDiskMiB)
+	return false
+}
+
+// waitForDisk waits until there is enough free space for the additional
+// and resized disks of this test's VMs and reserves it until releaseDisk()
+// is called. Space is checked both in the test's output directory and in
+// /var/tmp, where QemuBuilder creates the disk images.
+func waitForDisk(h *harness.H, flight platform.Flight, t *register.Test) {
+	if flight.Platform() != "qemu" {
+		return
+	}
+	needed := getNeededDiskMiB(t)
+	if needed == 0 {
+		return
+	}
+	dirs := []string{h.OutputDir(), "/var/tmp"}
+	start := time.Now()
+	warnOnWait := true // warn on first wait
+	for !reserveDiskForTest(t, needed, dirs, warnOnWait) {
+		// After a period of time switch to log a warning so we get some
+		// info even if debug isn't turned on.
+		if time.Since(start) > 5*time.Minute {
+			warnOnWait = true
+			start = time.Now() // reset counter
+		} else {
+			warnOnWait = false
+		}
+		// sleep between 0 and 20 seconds and try again
+		time.Sleep(time.Duration(rand.Intn(20)) * time.Second)
+	}
+}
+
+// releaseDisk returns a test's reserved disk space back to the pool. This
+// should be called when the test completes and its QEMU VMs (and their
+// disks) have been destroyed.
+func releaseDisk(t *register.Test) {
+	reservedDiskMiBMutex.Lock()
+	defer reservedDiskMiBMutex.Unlock()
+	reservedDiskMiB -= t.ReservedDiskMiB
+	t.ReservedDiskMiB = 0
+}
+
 // getNeededMemoryMiB returns the memory in MiB that a QEMU VM for
 // this test will use. It mirrors the resolution logic in
 // platform/machine/qemu/cluster.go:NewMachineWithOptions.
@@ -1857,6 +2241,12 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 	// Wait for any required host ports to become free first, so we
 	// don't hold a memory reservation while blocked on a port.
 	waitForHostPorts(h, flight, t)
+	// Then reserve the CPUs and scratch disk space for the test's VMs.
+	// These are released after the cluster is destroyed below.
+	waitForCPUs(h, flight, t)
+	defer releaseCPUs(t)
+	waitForDisk(h, flight, t)
+	defer releaseDisk(t)
 	// On QEMU we'll consider the amount of memory available in the
 	// system/cgroup before continuing. We do this after taking a
 	// parallel slot above via Parallel() so we are essentiallly
@@ -1867,6 +2257,7 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 	rconf := &platform.RuntimeConfig{
 		AllowFailedUnits:   testSkipBaseChecks(t),
 		InternetAccess:     testRequiresInternet(t),
+		PrivateNetwork:     t.HasFlag(register.PrivateNetwork),
 		NoInstanceCreds:    t.HasFlag(register.NoInstanceCreds),
 		NoSSHKeyInMetadata: t.HasFlag(register.NoSSHKeyInMetadata),
 		NoSSHKeyInUserData: t.HasFlag(register.NoSSHKeyInUserData),
@@ -1875,6 +2266,9 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 		WarningsAction:     conf.FailWarnings,
 		EarlyRelease:       h.Release,
 		TestExecTimeout:    h.TimeoutContext(),
+		MachineEvent: func(event, id string) {
+			h.Emit(event, id, "")
+		},
 	}
 	if t.HasFlag(register.AllowConfigWarnings) {
 		rconf.WarningsAction = conf.IgnoreWarnings
@@ -1887,9 +2281,17 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 	}
 	defer func() {
 		h.StopExecTimer()
+		if h.Failed() {
+			saveFailureDumps(h, c)
+		}
 		// give some time for the remote journal to be flushed before we Destroy()
 		time.Sleep(2 * time.Second)
 		c.Destroy()
+		if qc, ok := c.(*qemu.Cluster); ok {
+			if usage := qc.ResourceUsage(); len(usage) > 0 {
+				h.SetMetrics(testMetrics{Machines: usage})
+			}
+		}
 		// Release the memory reservation (if there was one) now that the VM is gone.
 		releaseMemoryCount(flight, t)
 		// Release any reserved host ports now that the VMs are gone.
@@ -1974,6 +2376,16 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 		tcluster.H.WarningOnFailure()
 	}
 
+	// The tests in a non-exclusive wrapper collect their own diagnostics
+	// when they fail.
+	if !nonexclusiveWrapperMatch.MatchString(t.Name) {
+		defer func() {
+			if h.Failed() {
+				collectDiagnostics(h, tcluster.Machines())
+			}
+		}()
+	}
+
 	// Poll asynchronously until all expected machines have been
 	// created and then release the temporary memory reservation.
 	// At the point machines show up in tcluster.Machines() they've
@@ -2069,53 +2481,88 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 // rename since systemd.path units may be watching and we don't want
 // them to start while the file is still transferring.
 func ScpKolet(machines []platform.Machine) error {
-	mArch := Options.CosaBuildArch
 	remotepath := "/usr/local/bin/kolet"
 	remotepathpartial := remotepath + ".partial"
-	exePath, err := os.Executable()
+	kolet, err := platform.FindKolet(Options.CosaBuildArch)
 	if err != nil {
-		return errors.Wrapf(err, "finding path of executable")
-	}
-	for _, d := range []string{
-		".",
-		filepath.Dir(exePath),
-		filepath.Join(filepath.Dir(exePath), mArch),
-		filepath.Join("/usr/lib/kola", mArch),
-	} {
-		kolet := filepath.Join(d, "kolet")
-		if _, err := os.Stat(kolet); err == nil {
-			in, err := os.Open(kolet)
-			if err != nil {
-				return err
+		return err
+	}
+	in, err := os.Open(kolet)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	for _, m := range machines {
+		if _, err := in.Seek(0, 0); err != nil {
+			return errors.Wrapf(err, "seeking kolet binary")
+		}
+		if err := platform.InstallFile(in, m, remotepathpartial); err != nil {
+			return errors.Wrapf(err, "dropping kolet binary")
+		}
+		if out, stderr, err := m.SSH(fmt.Sprintf("sudo mv %s %s", remotepathpartial, remotepath)); err != nil {
+			return errors.Wrapf(err, "running sudo mv %s %s: %s: %s", remotepathpartial, remotepath, out, stderr)
+		}
+	}
+	return nil
+}
+
+// saveFailureDumps saves a screendump and, if requested, a memory dump of
+// each machine of a failed test into the machine's output directory, so
+// that failures which don't show up on the serial console or hangs can be
+// analyzed.
+func saveFailureDumps(h *harness.H, c platform.Cluster) {
+	for _, m := range c.Machines() {
+		qm, ok := m.(platform.QEMUMachine)
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(h.OutputDir(), m.ID())
+		if QEMUOptions.ScreendumpOnFailure {
+			if err := qm.Screendump(filepath.Join(dir, "screendump.png")); err != nil {
+				plog.Warningf("Failed to save screendump of machine %s: %v", m.ID(), err)
 			}
-			defer in.Close()
-			for _, m := range machines {
-				if _, err := in.Seek(0, 0); err != nil {
-					return errors.Wrapf(err, "seeking kolet binary")
-				}
-				if err := platform.InstallFile(in, m, remotepathpartial); err != nil {
-					return errors.Wrapf(err, "dropping kolet binary")
-				}
-				if out, stderr, err := m.SSH(fmt.Sprintf("sudo mv %s %s", remotepathpartial, remotepath)); err != nil {
-					return errors.Wrapf(err, "running sudo mv %s %s: %s: %s", remotepathpartial, remotepath, out, stderr)
-				}
+		}
+		if QEMUOptions.DumpMemoryOnFailure {
+			plog.Infof("Dumping memory of machine %s", m.ID())
+			if err := qm.DumpMemory(filepath.Join(dir, "memory.elf")); err != nil {
+				plog.Warningf("Failed to dump memory of machine %s: %v", m.ID(), err)
 			}
-			return nil
 		}
 	}
-	return fmt.Errorf("Unable to locate kolet binary for %s", mArch)
+}
+
+// testMetrics is the resource usage of a test's machines, which is added
+// to its entry in report.json to help sizing MinMemory and the memory
+// reserved for tests.
+type testMetrics struct {
+	Machines []qemu.MachineResourceUsage `json:"machines"`
+}
+
+// hasAnyFlag returns whether t has any of flags.
+func hasAnyFlag(t *register.Test, flags []register.Flag) bool {
+	for _, flag := range flags {
+		if t.HasFlag(flag) {
+			return true
+		}
+	}
+	return false
 }
 
 // CheckConsole checks some console output for badness and returns short
 // descriptions of any bad lines it finds along with a boolean
 // indicating if the configuration has the bad lines marked as
 // warnOnly or not (for things we don't want to error for). If t is
-// specified, its flags are respected.
+// specified, its flags and tags are respected.
 func CheckConsole(output []byte, t *register.Test) (bool, []string) {
 	var badlines []string
 	warnOnly := true
-	for _, check := range consoleChecks {
-		if check.skipFlag != nil && t != nil && t.HasFlag(*check.skipFlag) {
+	checks := append([]consoleCheck{}, consoleChecks...)
+	checks = append(checks, extraConsoleChecks...)
+	for _, check := range checks {
+		if t != nil && hasAnyFlag(t, check.skipFlags) {
+			continue
+		}
+		if check.skipTag != "" && t != nil && HasString(check.skipTag, t.Tags) {
 			continue
 		}
 		match := check.match.FindSubmatch(output)
@@ -0,0 +1,28 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"net/http"
+)
+
+// NewHTTPServer returns the static webserver of `kola http-server`, which
+// serves the files of dir. Symlinks in dir are followed, so that files can
+// be served from elsewhere without copying them.
+func NewHTTPServer(dir string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(dir)))
+	return &http.Server{Handler: mux}
+}
@@ -0,0 +1,207 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+)
+
+// lintArchitectures returns the architectures external tests may list,
+// which are those QEMU machines can be created for.
+func lintArchitectures() []string {
+	var arches []string
+	for arch := range platform.ConsoleKernelArgument {
+		arches = append(arches, arch)
+	}
+	sort.Strings(arches)
+	return arches
+}
+
+// LintProblem is a problem found in an external test by LintExternalTests.
+type LintProblem struct {
+	Path    string `json:"path"`
+	Test    string `json:"test,omitempty"`
+	Message string `json:"message"`
+	// Warning is set for problems which don't prevent running the test
+	Warning bool `json:"warning,omitempty"`
+}
+
+func (p LintProblem) String() string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	if p.Test != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", p.Path, level, p.Test, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Path, level, p.Message)
+}
+
+// lintedTest is an external test with the metadata it would be registered
+// with.
+type lintedTest struct {
+	extTest
+	meta *externalTestMeta
+}
+
+// LintExternalTests checks the external tests in dir/tests/kola the way
+// RegisterExternalTests would find them, without registering or running
+// them. platforms and distros are the names the tests may refer to.
+func LintExternalTests(dir string, platforms, distros []string) ([]LintProblem, error) {
+	testsdir := filepath.Join(dir, "tests/kola")
+	children, err := os.ReadDir(testsdir)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []LintProblem
+	var tests []lintedTest
+	w := extTestWalker{
+		visit: func(t extTest) error {
+			// same as registerExternalTest
+			meta, err := metadataFromTestBinary(t.executable)
+			if err != nil {
+				problems = append(problems, LintProblem{Path: t.executable, Test: t.name, Message: fmt.Sprintf("parsing metadata: %v", err)})
+				return nil
+			}
+			if meta == nil {
+				meta = &t.meta
+			}
+			tests = append(tests, lintedTest{extTest: t, meta: meta})
+			return nil
+		},
+		fail: func(path string, err error) error {
+			msg := errors.Cause(err).Error()
+			if errors.Is(err, fs.ErrNotExist) {
+				msg = "dangling symlink"
+			}
+			problems = append(problems, LintProblem{Path: path, Message: msg})
+			return nil
+		},
+		warn: func(path, msg string) {
+			problems = append(problems, LintProblem{Path: path, Message: msg + "; missing executable bit?", Warning: true})
+		},
+		lint: func(path, msg string) {
+			problems = append(problems, LintProblem{Path: path, Message: msg})
+		},
+	}
+	basename := fmt.Sprintf("ext.%s", filepath.Base(dir))
+	if err := w.walk(testsdir, basename, children); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	for _, t := range tests {
+		if prev, ok := names[t.name]; ok {
+			problems = append(problems, LintProblem{Path: t.executable, Test: t.name, Message: fmt.Sprintf("duplicate test name, also used by %s", prev)})
+			continue
+		}
+		names[t.name] = t.executable
+	}
+	for _, t := range tests {
+		for _, msg := range lintExternalTest(t, names, platforms, distros) {
+			problems = append(problems, LintProblem{Path: t.executable, Test: t.name, Message: msg})
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems, nil
+}
+
+// lintExternalTest returns the problems of a single test. names are all
+// the tests found.
+func lintExternalTest(t lintedTest, names map[string]string, platforms, distros []string) []string {
+	var msgs []string
+	meta := t.meta
+
+	checkNames := func(what, value string, known []string) {
+		for _, name := range strings.Fields(strings.TrimPrefix(value, "!")) {
+			if !HasString(name, known) {
+				msgs = append(msgs, fmt.Sprintf("unknown %s %q; expected one of %s", what, name, strings.Join(known, ", ")))
+			}
+		}
+	}
+	checkNames("architecture", meta.Architectures, lintArchitectures())
+	checkNames("platform", meta.Platforms, platforms)
+	checkNames("distro", meta.Distros, distros)
+
+	if meta.CreationDate != "" {
+		if _, err := time.Parse(dateFormat, meta.CreationDate); err != nil {
+			msgs = append(msgs, fmt.Sprintf("malformed creationDate %q; expected YYYY-MM-DD", meta.CreationDate))
+		}
+	}
+	if meta.TimeoutMin < 0 {
+		msgs = append(msgs, fmt.Sprintf("negative timeoutMin %d", meta.TimeoutMin))
+	}
+
+	if meta.Exclusive {
+		if len(meta.Conflicts) > 0 {
+			msgs = append(msgs, "conflicts only apply to non-exclusive tests, but the test is exclusive")
+		}
+	} else {
+		for _, conflict := range meta.Conflicts {
+			if conflict == t.name {
+				msgs = append(msgs, "test conflicts with itself")
+			} else if _, ok := names[conflict]; !ok {
+				msgs = append(msgs, fmt.Sprintf("conflicting test %s not found", conflict))
+			}
+		}
+		// see makeNonExclusiveTest
+		for option, set := range map[string]bool{
+			"additionalDisks":           len(meta.AdditionalDisks) > 0,
+			"primaryDisk":               meta.PrimaryDisk != "",
+			"minMemory":                 meta.MinMemory != 0,
+			"numaNodes":                 meta.NumaNodes,
+			"minDisk":                   meta.MinDiskSize != 0,
+			"additionalNics":            meta.AdditionalNics != 0,
+			"appendKernelArgs":          meta.AppendKernelArgs != "",
+			"appendFirstbootKernelArgs": meta.AppendFirstbootKernelArgs != "",
+			"instanceType":              meta.InstanceType != "",
+			"bindMountHostRO":           len(meta.BindMountHostRO) > 0,
+			"bootFrom":                  meta.BootFrom != "",
+			"requiredHostPorts":         len(meta.RequiredHostPorts) > 0,
+			"noInstanceCreds":           meta.NoInstanceCreds,
+			"allowConfigWarnings":       meta.AllowConfigWarnings,
+		} {
+			if set {
+				msgs = append(msgs, fmt.Sprintf("non-exclusive tests can't set %s; add \"exclusive: true\"", option))
+			}
+		}
+	}
+
+	warningsAction := conf.FailWarnings
+	if meta.AllowConfigWarnings {
+		warningsAction = conf.IgnoreWarnings
+	}
+	if _, err := t.userdata.Render(warningsAction); err != nil {
+		msgs = append(msgs, fmt.Sprintf("invalid config: %v", err))
+	}
+
+	sort.Strings(msgs)
+	return msgs
+}
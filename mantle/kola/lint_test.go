@@ -0,0 +1,63 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+func TestLeftoverFcc(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "repo")
+	testdir := filepath.Join(dir, "tests/kola/fcc")
+	if err := os.MkdirAll(testdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testdir, "test.sh"), []byte("#!/bin/bash\ntrue\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testdir, "config.fcc"), []byte("variant: fcos\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// kola run ignores it
+	if err := RegisterExternalTests(dir); err != nil {
+		t.Fatalf("expected config.fcc to be ignored, got %v", err)
+	}
+	defer delete(register.Tests, "ext.repo.fcc")
+	if _, ok := register.Tests["ext.repo.fcc"]; !ok {
+		t.Error("expected ext.repo.fcc to be registered")
+	}
+
+	// kola lint-ext reports it
+	problems, err := LintExternalTests(dir, []string{"qemu"}, []string{"fcos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Warning || !strings.Contains(problems[0].Message, "config.bu") {
+		t.Errorf("expected an error about config.fcc, got %v", problems)
+	}
+}
+
+func TestLintArchitectures(t *testing.T) {
+	arches := lintArchitectures()
+	if len(arches) != 4 || arches[0] != "aarch64" || arches[3] != "x86_64" {
+		t.Errorf("expected the four supported architectures, got %v", arches)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"fmt"
+)
+
+// Matrix expands a test into one test per combination of a variant of each
+// of its axes. For example, a test "basic" with a firmware axis whose
+// default is "bios" and a disk axis whose default is "virtio" is registered
+// as "basic", "basic.uefi", "basic.nvme" and "basic.uefi.nvme".
+//
+// Every test of a non-default variant is tagged with "<axis>:<variant>",
+// so that e.g. `kola run --tag firmware:uefi` runs all the UEFI variants.
+type Matrix struct {
+	Axes []Axis
+	// Exclude returns true for combinations which shouldn't be
+	// registered. The combination maps the names of the axes to the names
+	// of their variants.
+	Exclude func(combination map[string]string) bool
+}
+
+// Axis is a dimension of a test matrix, like the firmware or the disk type.
+type Axis struct {
+	Name     string
+	Variants []Variant
+	// Default is the name of the variant which doesn't add to the name or
+	// the tags of the test
+	Default string
+}
+
+// Variant is one of the values of an axis.
+type Variant struct {
+	Name string
+	// Apply changes the test for the variant, e.g. its MachineOptions,
+	// platforms or description. It gets a copy of the test which it may
+	// modify freely.
+	Apply func(t *Test)
+}
+
+// expandMatrix returns the tests of the matrix of t.
+func (t *Test) expandMatrix() []*Test {
+	axes := t.Matrix.Axes
+	for _, axis := range axes {
+		if len(axis.Variants) == 0 {
+			panic(fmt.Sprintf("test %v: axis %v has no variants", t.Name, axis.Name))
+		}
+		found := false
+		for _, v := range axis.Variants {
+			found = found || v.Name == axis.Default
+		}
+		if !found {
+			panic(fmt.Sprintf("test %v: default %q of axis %v is not a variant", t.Name, axis.Default, axis.Name))
+		}
+	}
+
+	// Combinations are the index of the variant of each axis; the first
+	// axis changes slowest.
+	combinations := [][]int{{}}
+	for _, axis := range axes {
+		var next [][]int
+		for _, c := range combinations {
+			for i := range axis.Variants {
+				next = append(next, append(append([]int(nil), c...), i))
+			}
+		}
+		combinations = next
+	}
+
+	var tests []*Test
+	for _, c := range combinations {
+		names := make(map[string]string)
+		for i, axis := range axes {
+			names[axis.Name] = axis.Variants[c[i]].Name
+		}
+		if t.Matrix.Exclude != nil && t.Matrix.Exclude(names) {
+			continue
+		}
+
+		variant := t.clone()
+		variant.Matrix = nil
+		for i, axis := range axes {
+			v := axis.Variants[c[i]]
+			if v.Name != axis.Default {
+				variant.Name += "." + v.Name
+				variant.Tags = append(variant.Tags, axis.Name+":"+v.Name)
+			}
+			if v.Apply != nil {
+				v.Apply(variant)
+			}
+		}
+		tests = append(tests, variant)
+	}
+	return tests
+}
+
+// clone returns a copy of t which shares none of the slices and maps
+// variants or the harness are likely to modify.
+func (t *Test) clone() *Test {
+	c := *t
+	copyStrings := func(s []string) []string {
+		if s == nil {
+			return nil
+		}
+		return append([]string{}, s...)
+	}
+	c.Subtests = copyStrings(t.Subtests)
+	c.Platforms = copyStrings(t.Platforms)
+	c.Firmwares = copyStrings(t.Firmwares)
+	c.ExcludePlatforms = copyStrings(t.ExcludePlatforms)
+	c.ExcludeFirmwares = copyStrings(t.ExcludeFirmwares)
+	c.Distros = copyStrings(t.Distros)
+	c.ExcludeDistros = copyStrings(t.ExcludeDistros)
+	c.Architectures = copyStrings(t.Architectures)
+	c.ExcludeArchitectures = copyStrings(t.ExcludeArchitectures)
+	c.Tags = copyStrings(t.Tags)
+	c.Conflicts = copyStrings(t.Conflicts)
+	c.Flags = append([]Flag(nil), t.Flags...)
+	if t.NativeFuncs != nil {
+		// the denylist removes native tests from the map
+		c.NativeFuncs = make(map[string]NativeFuncWrap, len(t.NativeFuncs))
+		for name, f := range t.NativeFuncs {
+			c.NativeFuncs[name] = f
+		}
+	}
+	c.MachineOptions.AdditionalDisks = copyStrings(t.MachineOptions.AdditionalDisks)
+	c.MachineOptions.BindMountHostRO = copyStrings(t.MachineOptions.BindMountHostRO)
+	c.MachineOptions.HostForwardPorts = append(c.MachineOptions.HostForwardPorts[:0:0], t.MachineOptions.HostForwardPorts...)
+	c.MachineOptions.RequiredHostPorts = append([]int(nil), t.MachineOptions.RequiredHostPorts...)
+	return &c
+}
@@ -0,0 +1,135 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	test := &Test{
+		Name:        "basic",
+		ClusterSize: 1,
+		Tags:        []string{"base"},
+		Platforms:   []string{"qemu", "aws"},
+		NativeFuncs: map[string]NativeFuncWrap{"Native": {}},
+		MachineOptions: platform.MachineOptions{
+			AdditionalDisks: []string{"1G"},
+		},
+		Matrix: &Matrix{
+			Axes: []Axis{
+				{
+					Name:    "firmware",
+					Default: "bios",
+					Variants: []Variant{
+						{Name: "bios"},
+						{
+							Name: "uefi",
+							Apply: func(t *Test) {
+								t.Platforms[0] = "changed"
+								t.Platforms = t.Platforms[:1]
+								t.Tags[0] = "changed"
+								t.MachineOptions.AdditionalDisks[0] = "changed"
+								delete(t.NativeFuncs, "Native")
+								t.MachineOptions.Firmware = "uefi"
+							},
+						},
+						{Name: "uefi-secure"},
+					},
+				},
+				{
+					Name:    "disk",
+					Default: "virtio",
+					Variants: []Variant{
+						{Name: "virtio"},
+						{
+							Name: "nvme",
+							Apply: func(t *Test) {
+								t.MachineOptions.Nvme = true
+							},
+						},
+					},
+				},
+			},
+			Exclude: func(combination map[string]string) bool {
+				return combination["firmware"] == "uefi-secure" && combination["disk"] == "nvme"
+			},
+		},
+	}
+
+	variants := test.expandMatrix()
+	var names []string
+	for _, v := range variants {
+		names = append(names, v.Name)
+		if v.Matrix != nil {
+			t.Errorf("%s: expected no matrix", v.Name)
+		}
+	}
+	// the first axis changes slowest
+	expected := []string{"basic", "basic.nvme", "basic.uefi", "basic.uefi.nvme", "basic.uefi-secure"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+
+	tags := map[string][]string{
+		"basic":      {"base"},
+		"basic.nvme": {"base", "disk:nvme"},
+		"basic.uefi": {"changed", "firmware:uefi"},
+	}
+	for i, v := range variants[:3] {
+		if !reflect.DeepEqual(v.Tags, tags[v.Name]) {
+			t.Errorf("%s: expected tags %v, got %v", names[i], tags[v.Name], v.Tags)
+		}
+	}
+
+	nvme := variants[1]
+	if !nvme.MachineOptions.Nvme || nvme.MachineOptions.Firmware != "" {
+		t.Errorf("expected only the disk variant to be applied, got %+v", nvme.MachineOptions)
+	}
+	uefiNvme := variants[3]
+	if !uefiNvme.MachineOptions.Nvme || uefiNvme.MachineOptions.Firmware != "uefi" {
+		t.Errorf("expected both variants to be applied, got %+v", uefiNvme.MachineOptions)
+	}
+
+	// changes of a variant don't leak into the others or the test
+	for _, other := range []*Test{test, variants[0], variants[1], variants[4]} {
+		if strings.Join(other.Platforms, " ") != "qemu aws" || other.Tags[0] != "base" ||
+			other.MachineOptions.AdditionalDisks[0] != "1G" || len(other.NativeFuncs) != 1 {
+			t.Errorf("%s: variant changes leaked: %+v", other.Name, other)
+		}
+	}
+}
+
+func TestExpandMatrixInvalid(t *testing.T) {
+	for _, axis := range []Axis{
+		{Name: "empty"},
+		{Name: "default", Default: "missing", Variants: []Variant{{Name: "present"}}},
+		{Name: "nodefault", Variants: []Variant{{Name: "present"}}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", axis.Name)
+				}
+			}()
+			test := &Test{Name: "test", Matrix: &Matrix{Axes: []Axis{axis}}}
+			test.expandMatrix()
+		}()
+	}
+}
@@ -32,8 +32,30 @@ const (
 	NoEmergencyShellCheck             // don't check console output for emergency shell invocation
 	AllowConfigWarnings               // ignore Ignition and Butane warnings instead of failing
 	NoDracutFatalCheck                // don't check console output for dracut fatal errors
+	PrivateNetwork                    // connect the machines with a private network where the platform doesn't by default (QEMU)
 )
 
+// flagNames are the names of the flags in configuration files
+var flagNames = map[string]Flag{
+	"noSSHKeyInUserData":    NoSSHKeyInUserData,
+	"noSSHKeyInMetadata":    NoSSHKeyInMetadata,
+	"noInstanceCreds":       NoInstanceCreds,
+	"noEmergencyShellCheck": NoEmergencyShellCheck,
+	"allowConfigWarnings":   AllowConfigWarnings,
+	"noDracutFatalCheck":    NoDracutFatalCheck,
+	"privateNetwork":        PrivateNetwork,
+}
+
+// ParseFlag returns the flag with the given name, e.g.
+// noEmergencyShellCheck for NoEmergencyShellCheck.
+func ParseFlag(name string) (Flag, error) {
+	flag, ok := flagNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown flag %q", name)
+	}
+	return flag, nil
+}
+
 // NativeFuncWrap is a wrapper for the NativeFunc which includes an optional string of arches and/or distributions to
 // exclude for each native test.
 type NativeFuncWrap struct {
@@ -93,6 +115,10 @@ type Test struct {
 	// for budgeting memory usage for tests prior to the VMs starting up on the
 	// QEMU platform.
 	ReservedMemoryCountMiB int
+	// The vCPUs and scratch disk space in MiB reserved for the test's VMs
+	// on the QEMU platform while it runs.
+	ReservedCPUs    int
+	ReservedDiskMiB int
 
 	ExternalTest string
 	// DependencyDir is a path to directory that will be uploaded, normally used by external tests
@@ -109,6 +135,10 @@ type Test struct {
 	// Conflicts is non-empty iff nonexclusive is true
 	// Contains the tests that conflict with this particular test
 	Conflicts []string
+
+	// Matrix, if set, registers a variant of the test for each
+	// combination of the variants of its axes instead of the test itself
+	Matrix *Matrix
 }
 
 // Registered tests that run as part of `kola run` live here. Mapping of names
@@ -123,6 +153,12 @@ var UpgradeTests = map[string]*Test{}
 // harnesses knows which tests it can choose from. Panics if existing name is
 // registered
 func Register(m map[string]*Test, t *Test) {
+	if t.Matrix != nil {
+		for _, variant := range t.expandMatrix() {
+			Register(m, variant)
+		}
+		return
+	}
 	if len(t.Conflicts) > 0 && !t.NonExclusive {
 		panic("exclusive test cannot have non-empty conflicts entry")
 	}
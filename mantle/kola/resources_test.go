@@ -0,0 +1,121 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/system"
+)
+
+func TestGetNeededDiskMiB(t *testing.T) {
+	tests := []struct {
+		name    string
+		options platform.MachineOptions
+		size    int
+		miB     int
+	}{
+		{"default", platform.MachineOptions{}, 1, 0},
+		{"resized", platform.MachineOptions{MinDiskSize: 16}, 1, 16 * 1024},
+		{"primary disk", platform.MachineOptions{PrimaryDisk: "20G:mpath"}, 1, 20 * 1024},
+		// MinDiskSize overrides the size of the primary disk
+		{"resized primary disk", platform.MachineOptions{PrimaryDisk: "20G:mpath", MinDiskSize: 16}, 1, 16 * 1024},
+		{"primary disk without size", platform.MachineOptions{PrimaryDisk: ":mpath"}, 1, 0},
+		{"additional disks", platform.MachineOptions{MinDiskSize: 16, AdditionalDisks: []string{"1G", "2G:channel=nvme"}}, 1, 19 * 1024},
+		{"invalid disk", platform.MachineOptions{AdditionalDisks: []string{"1T", "2G"}}, 1, 2 * 1024},
+		{"cluster", platform.MachineOptions{MinDiskSize: 16, AdditionalDisks: []string{"1G"}}, 3, 3 * 17 * 1024},
+	}
+	for _, test := range tests {
+		if miB := getNeededDiskMiB(&register.Test{ClusterSize: test.size, MachineOptions: test.options}); miB != test.miB {
+			t.Errorf("%s: expected %d MiB, got %d", test.name, test.miB, miB)
+		}
+	}
+}
+
+func TestReserveCPUsForTest(t *testing.T) {
+	defer func() { reservedCPUs = 0 }()
+	tests := []struct {
+		reserved int
+		needed   int
+		ok       bool
+	}{
+		{0, 2, true},
+		// a test runs alone even if it needs more CPUs than the host has
+		{0, 8, true},
+		{2, 2, true},
+		{2, 3, false},
+		{4, 1, false},
+	}
+	for _, test := range tests {
+		reservedCPUs = test.reserved
+		rt := &register.Test{Name: "test"}
+		if ok := reserveCPUsForTest(rt, test.needed, 4); ok != test.ok {
+			t.Errorf("%d reserved, %d needed: expected %v, got %v", test.reserved, test.needed, test.ok, ok)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		if reservedCPUs != test.reserved+test.needed || rt.ReservedCPUs != test.needed {
+			t.Errorf("%d reserved, %d needed: expected the CPUs to be reserved, got %d", test.reserved, test.needed, reservedCPUs)
+		}
+		releaseCPUs(rt)
+		if reservedCPUs != test.reserved {
+			t.Errorf("%d reserved, %d needed: expected the CPUs to be released, got %d", test.reserved, test.needed, reservedCPUs)
+		}
+	}
+}
+
+func TestReserveDiskForTest(t *testing.T) {
+	defer func() { reservedDiskMiB = 0 }()
+	dirs := []string{t.TempDir(), t.TempDir()}
+	avail, err := system.GetDiskAvailableMiB(dirs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		reserved int
+		needed   int
+		ok       bool
+	}{
+		{"fits", 0, 1, true},
+		// half is left as slack for other writers to the filesystem
+		{"fits next to others", int(avail) / 2, 1, true},
+		// a test runs alone even if it needs more than is available
+		{"too large alone", 0, int(avail) * 2, true},
+		{"too large next to others", int(avail) / 2, int(avail), false},
+	}
+	for _, test := range tests {
+		reservedDiskMiB = test.reserved
+		rt := &register.Test{Name: "test"}
+		if ok := reserveDiskForTest(rt, test.needed, dirs, false); ok != test.ok {
+			t.Errorf("%s: expected %v, got %v", test.name, test.ok, ok)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		if reservedDiskMiB != test.reserved+test.needed || rt.ReservedDiskMiB != test.needed {
+			t.Errorf("%s: expected the space to be reserved, got %d MiB", test.name, reservedDiskMiB)
+		}
+		releaseDisk(rt)
+		if reservedDiskMiB != test.reserved {
+			t.Errorf("%s: expected the space to be released, got %d MiB", test.name, reservedDiskMiB)
+		}
+	}
+}
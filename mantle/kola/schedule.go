@@ -0,0 +1,187 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+// defaultExpectedDuration is the expected duration of every test if no
+// durations were ever recorded
+const defaultExpectedDuration = 5 * time.Minute
+
+// DurationsFile is the report.json of an earlier kola run whose test
+// durations are the expected durations of the tests. It is required for
+// duration sharding, since every shard must see the same durations, which
+// the flake databases of different hosts don't guarantee.
+var DurationsFile string
+
+// testDurations maps test names to how long they are expected to run.
+type testDurations map[string]time.Duration
+
+// loadExpectedDurations returns how long each of the tests is expected to
+// run on pltfrm, based on the durations in DurationsFile if set, or else on
+// those recorded in the flake database. Tests without recorded durations
+// are expected to take as long as the median test which has them.
+func loadExpectedDurations(tests map[string]*register.Test, pltfrm string) (testDurations, error) {
+	recorded := make(testDurations)
+	if DurationsFile != "" {
+		results, err := readReportResults(DurationsFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading durations")
+		}
+		for name, result := range results {
+			if result.result != testresult.Skip && result.duration > 0 {
+				recorded[name] = result.duration
+			}
+		}
+	} else if Sharding != "" && strings.HasPrefix(Sharding, "duration:") {
+		return nil, fmt.Errorf("duration sharding requires --durations-file")
+	} else if path := GetFlakeDBPath(); path != "" {
+		history, err := LoadFlakeHistory(path)
+		if err != nil {
+			return nil, err
+		}
+		for name := range tests {
+			if record := history.Lookup(name, pltfrm, Options.CosaBuildArch); record != nil {
+				recorded[name] = record.ExpectedDuration()
+			}
+		}
+	}
+
+	durations := make(testDurations)
+	var known []time.Duration
+	for name := range tests {
+		if d := recorded[name]; d > 0 {
+			durations[name] = d
+			known = append(known, d)
+		}
+	}
+	fallback := medianDuration(known)
+	if fallback == 0 {
+		fallback = defaultExpectedDuration
+	}
+	for name := range tests {
+		if _, ok := durations[name]; !ok {
+			durations[name] = fallback
+		}
+	}
+	return durations, nil
+}
+
+// longestFirst returns the tests sorted by decreasing expected duration,
+// then by name.
+func (d testDurations) longestFirst(tests []*register.Test) []*register.Test {
+	sorted := append([]*register.Test(nil), tests...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if d[a.Name] != d[b.Name] {
+			return d[a.Name] > d[b.Name]
+		}
+		return a.Name < b.Name
+	})
+	return sorted
+}
+
+// parseSharding parses a sharding specification of the form strategy:m/n.
+func parseSharding(sharding string) (string, uint, uint, error) {
+	strategy, shard, ok := strings.Cut(sharding, ":")
+	if !ok || (strategy != "hash" && strategy != "duration") {
+		return "", 0, 0, fmt.Errorf("invalid sharding syntax: %s", sharding)
+	}
+	parts := strings.SplitN(shard, "/", 2)
+	if len(parts) != 2 {
+		return "", 0, 0, fmt.Errorf("invalid sharding syntax: %s", sharding)
+	}
+	mv, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid sharding syntax '%s': %w", sharding, err)
+	}
+	nv, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid sharding syntax '%s': %w", sharding, err)
+	}
+	if mv > nv || nv < 1 || mv < 1 {
+		return "", 0, 0, fmt.Errorf("invalid sharding in '%s'", sharding)
+	}
+	return strategy, uint(mv), uint(nv), nil
+}
+
+// shardTests filters tests to a particular shard, as assigned by
+// assignShards.
+func shardTests(tests map[string]*register.Test, sharding string, durations testDurations) (map[string]*register.Test, error) {
+	if sharding == "" {
+		return tests, nil
+	}
+	strategy, m, n, err := parseSharding(sharding)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*register.Test)
+	for name, shard := range assignShards(tests, strategy, n, durations) {
+		if shard == m {
+			ret[name] = tests[name]
+		}
+	}
+	return ret, nil
+}
+
+// assignShards returns the shard, from 1 to n, of each of the tests. With
+// the hash strategy, a shard is the group of tests whose name hashes to the
+// same value. With the duration strategy, tests are assigned longest first
+// to the shard with the least total expected duration so far, so that all
+// shards take about as long. Every shard must then see the same expected
+// durations.
+func assignShards(tests map[string]*register.Test, strategy string, n uint, durations testDurations) map[string]uint {
+	shards := make(map[string]uint)
+	if strategy == "hash" {
+		for name := range tests {
+			h := fnv.New64()
+			h.Write([]byte(name))
+			shards[name] = uint(h.Sum64()%uint64(n)) + 1
+		}
+		return shards
+	}
+
+	var all []*register.Test
+	for _, test := range tests {
+		all = append(all, test)
+	}
+	loads := make([]time.Duration, n)
+	counts := make([]int, n)
+	for _, test := range durations.longestFirst(all) {
+		shard := 0
+		for i := range loads {
+			if loads[i] < loads[shard] || (loads[i] == loads[shard] && counts[i] < counts[shard]) {
+				shard = i
+			}
+		}
+		loads[shard] += durations[test.Name]
+		counts[shard]++
+		shards[test.Name] = uint(shard) + 1
+	}
+	return shards
+}
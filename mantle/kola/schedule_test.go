@@ -0,0 +1,92 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+func TestLoadExpectedDurations(t *testing.T) {
+	savedFile, savedSharding, savedPath := DurationsFile, Sharding, FlakeDBPath
+	defer func() {
+		DurationsFile, Sharding, FlakeDBPath = savedFile, savedSharding, savedPath
+	}()
+	// the flake database must not be used
+	FlakeDBPath = filepath.Join(t.TempDir(), "kola-flakes.json")
+
+	tests := map[string]*register.Test{"a": {}, "b": {}, "c": {}, "d": {}}
+
+	Sharding = "duration:1/2"
+	DurationsFile = ""
+	if _, err := loadExpectedDurations(tests, "qemu"); err == nil {
+		t.Error("expected an error for duration sharding without a durations file")
+	}
+	DurationsFile = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := loadExpectedDurations(tests, "qemu"); err == nil {
+		t.Error("expected an error for a missing durations file")
+	}
+
+	DurationsFile = filepath.Join(t.TempDir(), "report.json")
+	report := `{"tests": [
+		{"name": "a", "result": "PASS", "duration": 60000000000},
+		{"name": "b", "result": "FAIL", "duration": 180000000000},
+		{"name": "c", "result": "SKIP", "duration": 1},
+		{"name": "non-exclusive-test-bucket-0", "result": "PASS", "duration": 600000000000},
+		{"name": "non-exclusive-test-bucket-0/d", "result": "PASS", "duration": 120000000000},
+		{"name": "gone", "result": "PASS", "duration": 3600000000000}
+	]}`
+	if err := os.WriteFile(DurationsFile, []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+	durations, err := loadExpectedDurations(tests, "qemu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testDurations{
+		"a": time.Minute,
+		"b": 3 * time.Minute,
+		// skipped, so the median of the others
+		"c": 2 * time.Minute,
+		"d": 2 * time.Minute,
+	}
+	for name, d := range expected {
+		if durations[name] != d {
+			t.Errorf("expected %s for %s, got %s", d, name, durations[name])
+		}
+	}
+	if len(durations) != len(tests) {
+		t.Errorf("expected durations only for the tests, got %v", durations)
+	}
+}
+
+func TestAssignShardsByDuration(t *testing.T) {
+	tests := map[string]*register.Test{}
+	durations := testDurations{"a": 8 * time.Minute, "b": 5 * time.Minute, "c": 4 * time.Minute, "d": 3 * time.Minute}
+	for name := range durations {
+		tests[name] = &register.Test{Name: name}
+	}
+	shards := assignShards(tests, "duration", 2, durations)
+	expected := map[string]uint{"a": 1, "b": 2, "c": 2, "d": 1}
+	for name, shard := range expected {
+		if shards[name] != shard {
+			t.Errorf("expected %s in shard %d, got %d", name, shard, shards[name])
+		}
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"github.com/pborman/uuid"
 
 	"github.com/coreos/coreos-assembler/mantle/kola/register"
-	"github.com/coreos/coreos-assembler/mantle/platform"
 )
 
 const (
@@ -57,43 +56,56 @@ func init() {
 		Run:         LocalTests,
 		ClusterSize: 1,
 		NativeFuncs: nativeFuncs,
-	})
-	register.RegisterTest(&register.Test{
-		Name:          "basic.uefi",
-		Description:   "Verify basic functionalities like SSH, systemd services, useradd, etc, with UEFI enabled",
-		Run:           LocalTests,
-		Platforms:     []string{"qemu"},
-		ClusterSize:   1,
-		NativeFuncs:   nativeFuncs,
-		Architectures: []string{"x86_64", "aarch64"},
-		MachineOptions: platform.MachineOptions{
-			Firmware: uefi,
-		},
-	})
-	register.RegisterTest(&register.Test{
-		Name:          "basic.uefi-secure",
-		Description:   "Verify basic functionalities like SSH, systemd services, useradd, etc, with UEFI Secure Boot enabled",
-		Run:           LocalTests,
-		Platforms:     []string{"qemu"},
-		ClusterSize:   1,
-		NativeFuncs:   nativeFuncs,
-		Architectures: []string{"x86_64"},
-		MachineOptions: platform.MachineOptions{
-			Firmware: uefiSecure,
-		},
-	})
-	register.RegisterTest(&register.Test{
-		Name:        "basic.nvme",
-		Description: "Verify basic functionalities like SSH, systemd services, useradd, etc, with nvme enabled",
-		Run:         LocalTests,
-		Platforms:   []string{"qemu"},
-		ClusterSize: 1,
-		NativeFuncs: nativeFuncs,
-		// NVMe in theory is supported on all arches, but the way we test it seems to
-		// only work on x86_64 and aarch64.
-		Architectures: []string{"x86_64", "aarch64"},
-		MachineOptions: platform.MachineOptions{
-			Nvme: true,
+		Matrix: &register.Matrix{
+			Axes: []register.Axis{
+				{
+					Name:    "firmware",
+					Default: "default",
+					Variants: []register.Variant{
+						{Name: "default"},
+						{
+							Name: "uefi",
+							Apply: func(t *register.Test) {
+								t.Description = "Verify basic functionalities like SSH, systemd services, useradd, etc, with UEFI enabled"
+								t.Platforms = []string{"qemu"}
+								t.Architectures = []string{"x86_64", "aarch64"}
+								t.MachineOptions.Firmware = uefi
+							},
+						},
+						{
+							Name: "uefi-secure",
+							Apply: func(t *register.Test) {
+								t.Description = "Verify basic functionalities like SSH, systemd services, useradd, etc, with UEFI Secure Boot enabled"
+								t.Platforms = []string{"qemu"}
+								t.Architectures = []string{"x86_64"}
+								t.MachineOptions.Firmware = uefiSecure
+							},
+						},
+					},
+				},
+				{
+					Name:    "disk",
+					Default: "virtio",
+					Variants: []register.Variant{
+						{Name: "virtio"},
+						{
+							Name: "nvme",
+							Apply: func(t *register.Test) {
+								t.Description = "Verify basic functionalities like SSH, systemd services, useradd, etc, with nvme enabled"
+								t.Platforms = []string{"qemu"}
+								// NVMe in theory is supported on all arches, but the way we test it seems to
+								// only work on x86_64 and aarch64.
+								t.Architectures = []string{"x86_64", "aarch64"}
+								t.MachineOptions.Nvme = true
+							},
+						},
+					},
+				},
+			},
+			// Only vary one thing at a time
+			Exclude: func(combination map[string]string) bool {
+				return combination["firmware"] != "default" && combination["disk"] != "virtio"
+			},
 		},
 	})
 	register.RegisterTest(&register.Test{
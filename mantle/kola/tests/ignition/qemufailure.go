@@ -15,9 +15,8 @@
 package ignition
 
 import (
-	"context"
-	"fmt"
 	"os/exec"
+	"regexp"
 	"time"
 
 	"github.com/pkg/errors"
@@ -29,11 +28,6 @@ import (
 	"github.com/coreos/coreos-assembler/mantle/platform/conf"
 	"github.com/coreos/coreos-assembler/mantle/util"
 	"github.com/coreos/ignition/v2/config/v3_2/types"
-	"github.com/coreos/pkg/capnslog"
-)
-
-var (
-	plog = capnslog.NewPackageLogger("github.com/coreos/coreos-assembler/mantle", "kola/tests/ignition/qemufailure")
 )
 
 // qemuFailureTestMemoryMiB is the memory for the raw QemuBuilder
@@ -100,75 +94,39 @@ func runDualBootfsIgnitionFailure(c cluster.TestCluster) {
 	}
 }
 
-// Start the VM, take string and grep for it in the temporary console logs
+// Start the VM and wait on its console for the error matching
+// searchPattern and the emergency shell of the initramfs.
 func verifyError(builder *platform.QemuBuilder, searchPattern string) error {
+	builder.InteractiveConsole = true
 	inst, err := builder.Exec()
 	if err != nil {
 		return err
 	}
 	defer inst.Destroy()
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-
-	defer cancel()
-
-	checkConsole := func(path string, searchPattern string) error {
-		// Expected initramfs failure, checking the console file to ensure
-		// that it failed the expected way
-		found, err := util.FileContainsPattern(path, searchPattern)
-		if err != nil {
-			return errors.Wrapf(err, "looking for pattern '%s' in file '%s' failed", searchPattern, path)
-		} else if !found {
-			return fmt.Errorf("pattern '%s' in file '%s' not found", searchPattern, path)
-		}
-		return nil
+	console, err := inst.Console()
+	if err != nil {
+		return err
 	}
+	console.Timeout = 1 * time.Minute
 
-	errchan := make(chan error)
-	go func() {
-		resultingError := inst.WaitAll(ctx)
-		if resultingError == nil {
-			resultingError = fmt.Errorf("ignition unexpectedly succeeded")
-		} else if resultingError == platform.ErrInitramfsEmergency {
-			resultingError = checkConsole(builder.ConsoleFile, searchPattern)
-		} else {
-			resultingError = errors.Wrapf(resultingError, "expected initramfs emergency.target error")
-		}
-		errchan <- resultingError
-	}()
-
-	select {
-	case <-ctx.Done():
-		if err := inst.Kill(); err != nil {
-			return errors.Wrapf(err, "failed to kill the vm instance")
-		}
-		// The journal dumping can fail if the `com.coreos.ignition.journal`
-		// device doesn't show up for some reason (race condition) [1].
-		// Let's try to detect that case and check the console anyway for
-		// the original failure we were looking for.
-		//
-		// We just ignore errors here. This effort is only trying to help
-		// be more informative about why things failed. This "string"
-		// we are searching for comes from ignition-virtio-dump-journal
-		// [1] https://github.com/coreos/fedora-coreos-tracker/issues/2019
-		virtioPortSearchPattern := "Didn't find virtio port /dev/virtio-ports/com.coreos.ignition.journal"
-		found, _ := util.FileContainsPattern(builder.ConsoleFile, virtioPortSearchPattern)
-		if found {
-			plog.Warning("Journal dumping during emergency.target failed. Continuing best effort.")
-			// Check the log even though there was a timeout
-			if err := checkConsole(builder.ConsoleFile, searchPattern); err == nil {
-				// Even though there was a timeout we found the string
-				// we wanted in the console log anyway. Let's just
-				// take the win.
-				return nil
-			}
-		}
-		return errors.Wrapf(ctx.Err(), "timed out waiting for initramfs error")
-	case err := <-errchan:
-		if err != nil {
-			return err
-		}
-		return nil
+	// The logs of the failed units are displayed before the prompt
+	if _, err := console.Expect(regexp.QuoteMeta(searchPattern)); err != nil {
+		return errors.Wrapf(err, "expected initramfs error")
 	}
+	if _, err := console.Expect("Press Enter for emergency shell"); err != nil {
+		return errors.Wrapf(err, "expected initramfs emergency shell")
+	}
+	if err := console.SendKey("enter"); err != nil {
+		return err
+	}
+	// Compute the marker so that the echoed command doesn't match
+	if err := console.SendLine("test -f /etc/initrd-release && echo initramfs-$((6*7))"); err != nil {
+		return err
+	}
+	if _, err := console.Expect("initramfs-42"); err != nil {
+		return errors.Wrapf(err, "expected the emergency shell to run in the initramfs")
+	}
+	return nil
 }
 
 func ignitionFailure(c cluster.TestCluster) error {
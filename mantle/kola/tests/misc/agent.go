@@ -0,0 +1,85 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+)
+
+var noSSHD = conf.Butane(`
+variant: fcos
+version: 1.4.0
+systemd:
+  units:
+    - name: sshd.service
+      mask: true
+    - name: sshd.socket
+      mask: true`)
+
+func init() {
+	register.RegisterTest(&register.Test{
+		Run:                 agentWithoutSSH,
+		ClusterSize:         1,
+		TestManagedMachines: true,
+		Name:                "coreos.agent.no-sshd",
+		Description:         "Verify that the kola agent reaches a machine which never starts sshd.",
+		Platforms:           []string{"qemu"},
+	})
+}
+
+// agentWithoutSSH checks a machine with sshd masked through the agent, as
+// the harness must not wait for SSH for it to start.
+func agentWithoutSSH(c cluster.TestCluster) {
+	m, err := c.Cluster.NewMachineWithOptions(noSSHD, platform.MachineOptions{Agent: true})
+	if err != nil {
+		c.Fatal(err)
+	}
+	agent, err := m.(platform.QEMUMachine).Agent()
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	out, _, err := agent.Exec("systemctl show --property LoadState --value sshd.service")
+	if err != nil {
+		c.Fatalf("getting the state of sshd: %v", err)
+	}
+	if string(out) != "masked" {
+		c.Fatalf("expected sshd to be masked, got %q", out)
+	}
+	if out, _, err := agent.Exec("systemctl is-active sshd.service"); err == nil {
+		c.Fatalf("expected sshd not to run, got %q", out)
+	}
+
+	if err := agent.WriteFile("/run/kola-agent", []byte("written"), 0600); err != nil {
+		c.Fatalf("writing a file: %v", err)
+	}
+	out, _, err = agent.Exec("stat --format %a /run/kola-agent && cat /run/kola-agent")
+	if err != nil {
+		c.Fatalf("reading the written file: %v", err)
+	}
+	if string(out) != "600\nwritten" {
+		c.Fatalf("expected the written file, got %q", out)
+	}
+	data, err := agent.ReadFile("/etc/os-release")
+	if err != nil {
+		c.Fatalf("reading a file: %v", err)
+	}
+	if len(data) == 0 {
+		c.Fatal("expected /etc/os-release not to be empty")
+	}
+}
@@ -62,8 +62,7 @@ type seBooleanState struct {
 	newValue      string // new, opposite boolean value
 }
 
-// testSelinuxCmds will run a list of commands, optionally check their output, and
-// ultimately reboot the host
+// testSelinuxCmds will run a list of commands and optionally check their output
 func testSelinuxCmds(c cluster.TestCluster, m platform.Machine, cmds []cmdCheckOutput) {
 	for _, cmd := range cmds {
 		output := c.MustSSH(m, cmd.cmdline)
@@ -75,11 +74,6 @@ func testSelinuxCmds(c cluster.TestCluster, m platform.Machine, cmds []cmdCheckO
 			}
 		}
 	}
-
-	err := m.Reboot()
-	if err != nil {
-		c.Fatalf("failed to reboot machine: %v", err)
-	}
 }
 
 // getSelinuxBooleanState checks the original value of a provided SELinux boolean
@@ -122,28 +116,42 @@ func SelinuxBoolean(c cluster.TestCluster) {
 
 	m := c.Machines()[0]
 
-	tempBoolState, err := getSelinuxBooleanState(c, m, seBoolean)
-	if err != nil {
-		c.Fatalf(`Failed to gather SELinux boolean state: %v`, err)
-	}
-
-	// construct a regexp that looks like ".*off" or ".*on"
-	tempBoolRegexp := ".*" + tempBoolState.newValue
-	cmdList := []cmdCheckOutput{
-		{fmt.Sprintf("sudo setsebool %s %s", seBoolean, tempBoolState.newValue), false, ""},
-		{"getsebool " + seBoolean, true, tempBoolRegexp},
-	}
-
-	testSelinuxCmds(c, m, cmdList)
-
-	// since we didn't persist the change, should return to default value
-	postOut := c.MustSSH(m, "getsebool "+seBoolean)
-	postBool := strings.Split(string(postOut), " ")[2]
-
-	// newBool[0] contains the original value of the boolean
-	if postBool != tempBoolState.originalValue {
-		c.Fatalf(`The SELinux boolean "%q" is incorrectly configured: wanted %q, got %q`, seBoolean, tempBoolState.originalValue, postBool)
-	}
+	var tempBoolState seBooleanState
+	c.RunPhases(m,
+		cluster.Phase{
+			Name:   "set",
+			Reboot: cluster.Reboot,
+			Run: func(c cluster.TestCluster, _ string) {
+				var err error
+				tempBoolState, err = getSelinuxBooleanState(c, m, seBoolean)
+				if err != nil {
+					c.Fatalf(`Failed to gather SELinux boolean state: %v`, err)
+				}
+
+				// construct a regexp that looks like ".*off" or ".*on"
+				tempBoolRegexp := ".*" + tempBoolState.newValue
+				cmdList := []cmdCheckOutput{
+					{fmt.Sprintf("sudo setsebool %s %s", seBoolean, tempBoolState.newValue), false, ""},
+					{"getsebool " + seBoolean, true, tempBoolRegexp},
+				}
+
+				testSelinuxCmds(c, m, cmdList)
+			},
+		},
+		cluster.Phase{
+			Name: "verify",
+			Run: func(c cluster.TestCluster, _ string) {
+				// since we didn't persist the change, should return to default value
+				postOut := c.MustSSH(m, "getsebool "+seBoolean)
+				postBool := strings.Split(string(postOut), " ")[2]
+
+				// newBool[0] contains the original value of the boolean
+				if postBool != tempBoolState.originalValue {
+					c.Fatalf(`The SELinux boolean "%q" is incorrectly configured: wanted %q, got %q`, seBoolean, tempBoolState.originalValue, postBool)
+				}
+			},
+		},
+	)
 }
 
 // SelinuxBooleanPersist checks that you can tweak a boolean and have it
@@ -153,27 +161,41 @@ func SelinuxBooleanPersist(c cluster.TestCluster) {
 
 	m := c.Machines()[0]
 
-	persistBoolState, err := getSelinuxBooleanState(c, m, seBoolean)
-	if err != nil {
-		c.Fatalf(`Failed to gather SELinux boolean state: %v`, err)
-	}
-
-	// construct a regexp that looks like ".*off" or ".*on"
-	persistBoolRegexp := ".*" + persistBoolState.newValue
-	cmdList := []cmdCheckOutput{
-		{fmt.Sprintf("sudo setsebool -P %s %s", seBoolean, persistBoolState.newValue), false, ""},
-		{"getsebool " + seBoolean, true, persistBoolRegexp},
-	}
-
-	testSelinuxCmds(c, m, cmdList)
-
-	// the change should be persisted after a reboot
-	postOut := c.MustSSH(m, "getsebool "+seBoolean)
-	postBool := strings.Split(string(postOut), " ")[2]
-
-	if postBool != persistBoolState.newValue {
-		c.Fatalf(`The SELinux boolean "%q" is incorrectly configured: wanted %q, got %q`, seBoolean, persistBoolState.newValue, postBool)
-	}
+	var persistBoolState seBooleanState
+	c.RunPhases(m,
+		cluster.Phase{
+			Name:   "set",
+			Reboot: cluster.Reboot,
+			Run: func(c cluster.TestCluster, _ string) {
+				var err error
+				persistBoolState, err = getSelinuxBooleanState(c, m, seBoolean)
+				if err != nil {
+					c.Fatalf(`Failed to gather SELinux boolean state: %v`, err)
+				}
+
+				// construct a regexp that looks like ".*off" or ".*on"
+				persistBoolRegexp := ".*" + persistBoolState.newValue
+				cmdList := []cmdCheckOutput{
+					{fmt.Sprintf("sudo setsebool -P %s %s", seBoolean, persistBoolState.newValue), false, ""},
+					{"getsebool " + seBoolean, true, persistBoolRegexp},
+				}
+
+				testSelinuxCmds(c, m, cmdList)
+			},
+		},
+		cluster.Phase{
+			Name: "verify",
+			Run: func(c cluster.TestCluster, _ string) {
+				// the change should be persisted after a reboot
+				postOut := c.MustSSH(m, "getsebool "+seBoolean)
+				postBool := strings.Split(string(postOut), " ")[2]
+
+				if postBool != persistBoolState.newValue {
+					c.Fatalf(`The SELinux boolean "%q" is incorrectly configured: wanted %q, got %q`, seBoolean, persistBoolState.newValue, postBool)
+				}
+			},
+		},
+	)
 }
 
 // SelinuxManage checks that you can modify an SELinux file context and
@@ -187,8 +209,20 @@ func SelinuxManage(c cluster.TestCluster) {
 
 	m := c.Machines()[0]
 
-	testSelinuxCmds(c, m, cmdList)
-
-	// the change should be persisted after a reboot
-	c.AssertCmdOutputMatches(m, "sudo semanage fcontext -l | grep pam_shield", regexp.MustCompile(".*system_u:object_r:httpd_log_t:s0"))
+	c.RunPhases(m,
+		cluster.Phase{
+			Name:   "modify",
+			Reboot: cluster.Reboot,
+			Run: func(c cluster.TestCluster, _ string) {
+				testSelinuxCmds(c, m, cmdList)
+			},
+		},
+		cluster.Phase{
+			Name: "verify",
+			Run: func(c cluster.TestCluster, _ string) {
+				// the change should be persisted after a reboot
+				c.AssertCmdOutputMatches(m, "sudo semanage fcontext -l | grep pam_shield", regexp.MustCompile(".*system_u:object_r:httpd_log_t:s0"))
+			},
+		},
+	)
 }
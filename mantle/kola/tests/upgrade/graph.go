@@ -0,0 +1,144 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+)
+
+// hostAddress is the address of the host from QEMU user mode networking
+const hostAddress = "10.0.2.2"
+
+// RegisterGraphTests registers an fcos.upgrade.graph.<version> test for each
+// path, which runs the fcos.upgrade.basic upgrade from the start of the path
+// through its hops to the build under test. The OCI archives are served from
+// the host so that the machines don't need the network.
+func RegisterGraphTests(paths []kola.UpgradePath) {
+	for _, path := range paths {
+		path := path
+		desc := fmt.Sprintf("Verify that %s upgrades to the build under test", path.Start)
+		if len(path.Hops) > 0 {
+			desc += fmt.Sprintf(" through %d barrier release(s)", len(path.Hops))
+		}
+		register.RegisterUpgradeTest(&register.Test{
+			Run: func(c cluster.TestCluster) {
+				fcosUpgradeGraph(c, path)
+			},
+			ClusterSize: 1,
+			Name:        "fcos.upgrade.graph." + path.Start,
+			Description: desc,
+			Tags:        []string{"upgrade", "upgrade-graph"},
+			Distros:     []string{"fcos"},
+			// the archives are served to QEMU user mode networking
+			Platforms: []string{"qemu"},
+			Timeout:   time.Duration(len(path.Hops)+1) * 15 * time.Minute,
+			MachineOptions: platform.MachineOptions{
+				OverrideBackingFile: path.DiskImage,
+			},
+			// the starting release shouldn't update itself before we do
+			UserData: conf.Butane(`
+variant: fcos
+version: 1.4.0
+storage:
+  files:
+    - path: /etc/zincati/config.d/99-kola-disable-updates.toml
+      contents:
+        inline: |
+          [updates]
+          enabled = false
+`),
+		})
+	}
+}
+
+func fcosUpgradeGraph(c cluster.TestCluster, path kola.UpgradePath) {
+	m := c.Machines()[0]
+
+	hops := append([]kola.UpgradeHop{}, path.Hops...)
+	hops = append(hops, kola.UpgradeHop{
+		Version:    kola.CosaBuild.Meta.OstreeVersion,
+		OciArchive: filepath.Join(kola.CosaBuild.Dir, kola.CosaBuild.Meta.BuildArtifacts.Ostree.Path),
+	})
+
+	baseURL, stop, err := serveOciArchives(hops)
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer stop()
+
+	for _, hop := range hops {
+		hop := hop
+		ok := c.Run("upgrade-to-"+hop.Version, func(c cluster.TestCluster) {
+			name := filepath.Base(hop.OciArchive)
+			c.RunCmdSyncf(m, "sudo curl -sSfL -o /var/tmp/%s %s/%s", name, baseURL, name)
+			rpmostreeRebase(c, m, fmt.Sprintf("ostree-unverified-image:oci-archive:/var/tmp/%s", name), hop.Version)
+			c.RunCmdSyncf(m, "sudo rm /var/tmp/%s", name)
+		})
+		// the next hops would start from the wrong release
+		if !ok {
+			c.Fatalf("upgrade to %s failed; not trying the following hops", hop.Version)
+		}
+	}
+}
+
+// serveOciArchives serves the OCI archives of hops on the host with the
+// server of `kola http-server`, from a directory of symlinks to them. It
+// returns the URL of the server as seen from the machines, and a function
+// stopping it.
+func serveOciArchives(hops []kola.UpgradeHop) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "kola-upgrade-graph")
+	if err != nil {
+		return "", nil, err
+	}
+	for _, hop := range hops {
+		archive, err := filepath.Abs(hop.OciArchive)
+		if err == nil {
+			err = os.Symlink(archive, filepath.Join(dir, filepath.Base(archive)))
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	srv := kola.NewHTTPServer(dir)
+	go func() {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			plog.Errorf("serving OCI archives: %v", err)
+		}
+	}()
+	stop := func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	return fmt.Sprintf("http://%s:%d", hostAddress, port), stop, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+// UpgradeHop is a release an upgrade path goes through.
+type UpgradeHop struct {
+	Version string
+	// OciArchive is the path on the host of the ostree OCI archive of the
+	// release
+	OciArchive string
+}
+
+// UpgradePath is how a machine installed from a historical release of a
+// stream gets to the build under test: through every barrier release newer
+// than its starting point, as Zincati would.
+type UpgradePath struct {
+	Start string
+	// StartBarrier is true if the starting point is itself a barrier
+	StartBarrier bool
+	// DiskImage is the path on the host of the QEMU image of the starting
+	// point
+	DiskImage string
+	// Hops are the releases upgraded to before the build under test
+	Hops []UpgradeHop
+}
+
+// PlanUpgradePaths picks the starting points of upgrade graph tests from the
+// releases of a stream, oldest first: the latest count releases, and the
+// latest barrier along with the release preceding it, so that crossing a
+// barrier is always tested. barriers are the versions of the barrier
+// releases. The hops of the returned paths only have their version set.
+func PlanUpgradePaths(releases []string, barriers map[string]bool, count int) []UpgradePath {
+	starts := make(map[int]bool)
+	for i := len(releases) - count; i < len(releases); i++ {
+		if i >= 0 {
+			starts[i] = true
+		}
+	}
+	for i := len(releases) - 1; i >= 0; i-- {
+		if barriers[releases[i]] {
+			starts[i] = true
+			if i > 0 {
+				starts[i-1] = true
+			}
+			break
+		}
+	}
+
+	var paths []UpgradePath
+	for i, version := range releases {
+		if !starts[i] {
+			continue
+		}
+		path := UpgradePath{Start: version, StartBarrier: barriers[version]}
+		for _, next := range releases[i+1:] {
+			if barriers[next] {
+				path.Hops = append(path.Hops, UpgradeHop{Version: next})
+			}
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
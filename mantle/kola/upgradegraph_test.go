@@ -0,0 +1,86 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanUpgradePaths(t *testing.T) {
+	releases := []string{"1", "2", "3", "4", "5", "6"}
+	path := func(start string, hops ...string) UpgradePath {
+		p := UpgradePath{Start: start}
+		for _, hop := range hops {
+			p.Hops = append(p.Hops, UpgradeHop{Version: hop})
+		}
+		return p
+	}
+	barrier := func(p UpgradePath) UpgradePath {
+		p.StartBarrier = true
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		barriers map[string]bool
+		count    int
+		paths    []UpgradePath
+	}{
+		{
+			name:  "no barriers",
+			count: 2,
+			paths: []UpgradePath{path("5"), path("6")},
+		},
+		{
+			name:     "latest barrier and its predecessor",
+			barriers: map[string]bool{"2": true, "4": true},
+			count:    1,
+			paths:    []UpgradePath{path("3", "4"), barrier(path("4")), path("6")},
+		},
+		{
+			name:     "hops through every later barrier",
+			barriers: map[string]bool{"2": true, "4": true},
+			count:    6,
+			paths: []UpgradePath{
+				path("1", "2", "4"), barrier(path("2", "4")), path("3", "4"),
+				barrier(path("4")), path("5"), path("6"),
+			},
+		},
+		{
+			name:     "barrier among the latest releases",
+			barriers: map[string]bool{"6": true},
+			count:    1,
+			paths:    []UpgradePath{path("5", "6"), barrier(path("6"))},
+		},
+		{
+			name:     "first release is a barrier",
+			barriers: map[string]bool{"1": true},
+			count:    0,
+			paths:    []UpgradePath{barrier(path("1"))},
+		},
+		{
+			name:  "more releases asked for than there are",
+			count: 10,
+			paths: []UpgradePath{path("1"), path("2"), path("3"), path("4"), path("5"), path("6")},
+		},
+	}
+	for _, test := range tests {
+		paths := PlanUpgradePaths(releases, test.barriers, test.count)
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.paths, paths)
+		}
+	}
+}
@@ -207,6 +207,7 @@ func (bc *BaseCluster) DelMach(m Machine) {
 	defer bc.machlock.Unlock()
 	delete(bc.machmap, m.ID())
 	bc.consolemap[m.ID()] = m.ConsoleOutput()
+	bc.rconf.machineEvent(MachineEventDestroyed, m.ID())
 }
 
 func (bc *BaseCluster) AllocateMachineSerial() uint {
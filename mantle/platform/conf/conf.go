@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	butane "github.com/coreos/butane/config"
@@ -82,6 +83,10 @@ const (
 // for streaming the systemd journal out of a guest VM.
 const VirtioJournalDeviceName = "mantlejournal"
 
+// AgentDeviceName is the name of the virtio-serial device used by the
+// kolet agent to run commands without SSH.
+const AgentDeviceName = "mantleagent"
+
 var plog = capnslog.NewPackageLogger("github.com/coreos/coreos-assembler/mantle", "platform/conf")
 
 // UserData is an immutable, unvalidated configuration for a CoreOS
@@ -1711,6 +1716,36 @@ func (c *Conf) AddVirtioJournalUnit(queryArguments ...string) {
 	c.AddSystemdUnit("mantle-virtio-journal-stream.service", streamJournalUnit, Enable)
 }
 
+// AddAgentUnit adds a systemd unit that runs the kolet agent at koletPath
+// on the virtio-serial device defined by the AgentDeviceName constant. The
+// agent starts early and survives isolating to emergency.target, so that
+// the harness can inspect machines which never reach sshd. It is not
+// available in the initramfs, which kolet isn't part of.
+func (c *Conf) AddAgentUnit(koletPath string) {
+	var agentUnit = fmt.Sprintf(`[Unit]
+	Description=Kola agent
+	Requires=dev-virtio\\x2dports-%s.device
+	After=dev-virtio\\x2dports-%s.device
+	RequiresMountsFor=%s
+	IgnoreOnIsolate=true
+	ConditionPathExists=!/etc/initrd-release
+	# Like the journal stream, don't get pulled down with sysinit.target
+	DefaultDependencies=no
+	Conflicts=shutdown.target
+	Before=shutdown.target
+	[Service]
+	Type=simple
+	Restart=always
+	# Wrap in /bin/bash to hack around SELinux
+	# https://bugzilla.redhat.com/show_bug.cgi?id=1942198
+	ExecStart=/usr/bin/bash -c "exec %s agent /dev/virtio-ports/%s"
+	[Install]
+	WantedBy=sysinit.target
+	`, AgentDeviceName, AgentDeviceName, filepath.Dir(koletPath), koletPath, AgentDeviceName)
+
+	c.AddSystemdUnit("mantle-agent.service", agentUnit, Enable)
+}
+
 func makeGzipDataUrl(data []byte) (string, error) {
 	var buf bytes.Buffer
 	gz, err := gzip.NewWriterLevel(&buf, 9)
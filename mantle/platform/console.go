@@ -0,0 +1,297 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultConsoleTimeout is how long Console.Expect waits by default
+	DefaultConsoleTimeout = 5 * time.Minute
+	// consolePollInterval is how often the console log is read
+	consolePollInterval = 100 * time.Millisecond
+	// consoleErrorContext is how much unmatched output errors include
+	consoleErrorContext = 512
+)
+
+// ConsoleKeys are the escape sequences of keys which can be sent with
+// Console.SendKey, e.g. to navigate the GRUB menu.
+var ConsoleKeys = map[string]string{
+	"up":     "\x1b[A",
+	"down":   "\x1b[B",
+	"right":  "\x1b[C",
+	"left":   "\x1b[D",
+	"enter":  "\r",
+	"esc":    "\x1b",
+	"tab":    "\t",
+	"ctrl-c": "\x03",
+	"ctrl-d": "\x04",
+	"ctrl-x": "\x18",
+}
+
+// Console automates a serial console in the manner of expect(1): it waits
+// for output matching regexes and sends keystrokes. Output is read from the
+// console log, so no output is missed even if the console is created after
+// the machine has booted; output matched by Expect is consumed.
+type Console struct {
+	// Timeout is how long Expect waits for a match.
+	Timeout time.Duration
+	// Transcript, if set, receives the console output as it is read, and
+	// the input as it is sent, marked as <<send "...">>.
+	Transcript io.Writer
+
+	path  string
+	input io.Writer
+	log   *os.File
+	buf   []byte
+}
+
+// NewConsole returns a Console reading the console log at path, as
+// returned by Machine.ConsolePath(). Keystrokes are written to input; a
+// Console with a nil input can only wait for output.
+func NewConsole(path string, input io.Writer) *Console {
+	return &Console{
+		Timeout: DefaultConsoleTimeout,
+		path:    path,
+		input:   input,
+	}
+}
+
+// Expect waits for output matching the regex re, and returns the match
+// and its submatches.
+func (c *Console) Expect(re string) ([]string, error) {
+	return c.ExpectTimeout(re, c.Timeout)
+}
+
+// ExpectTimeout is Expect with an explicit timeout.
+func (c *Console) ExpectTimeout(re string, timeout time.Duration) ([]string, error) {
+	r, err := regexp.Compile(re)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := c.read(); err != nil {
+			return nil, err
+		}
+		if loc := r.FindSubmatchIndex(c.buf); loc != nil {
+			var matches []string
+			for i := 0; i < len(loc); i += 2 {
+				if loc[i] >= 0 {
+					matches = append(matches, string(c.buf[loc[i]:loc[i+1]]))
+				} else {
+					matches = append(matches, "")
+				}
+			}
+			c.buf = c.buf[loc[1]:]
+			return matches, nil
+		}
+		if time.Now().After(deadline) {
+			tail := c.buf
+			if len(tail) > consoleErrorContext {
+				tail = tail[len(tail)-consoleErrorContext:]
+			}
+			return nil, fmt.Errorf("timed out after %s waiting for %q on console; last output: %q", timeout, re, tail)
+		}
+		time.Sleep(consolePollInterval)
+	}
+}
+
+// Send types s on the console.
+func (c *Console) Send(s string) error {
+	if c.input == nil {
+		return errors.New("console is read-only")
+	}
+	if c.Transcript != nil {
+		fmt.Fprintf(c.Transcript, "<<send %q>>", s)
+	}
+	_, err := io.WriteString(c.input, s)
+	return errors.Wrapf(err, "sending to console")
+}
+
+// SendLine types s followed by Enter on the console.
+func (c *Console) SendLine(s string) error {
+	return c.Send(s + ConsoleKeys["enter"])
+}
+
+// SendKey presses one of the ConsoleKeys on the console.
+func (c *Console) SendKey(name string) error {
+	key, ok := ConsoleKeys[name]
+	if !ok {
+		return fmt.Errorf("unknown key %q", name)
+	}
+	return c.Send(key)
+}
+
+// Close closes the console log.
+func (c *Console) Close() error {
+	if c.log == nil {
+		return nil
+	}
+	err := c.log.Close()
+	c.log = nil
+	return err
+}
+
+// read appends the output written to the console log since the last read
+// to the buffer.
+func (c *Console) read() error {
+	if c.log == nil {
+		f, err := os.Open(c.path)
+		if os.IsNotExist(err) {
+			// QEMU hasn't created it yet
+			return nil
+		} else if err != nil {
+			return err
+		}
+		c.log = f
+	}
+	buf, err := io.ReadAll(c.log)
+	if err != nil {
+		return errors.Wrapf(err, "reading console log")
+	}
+	if c.Transcript != nil {
+		if _, err := c.Transcript.Write(buf); err != nil {
+			return err
+		}
+	}
+	c.buf = append(c.buf, buf...)
+	return nil
+}
+
+// Console returns a Console for the serial console of the instance, which
+// must have been built with QemuBuilder.InteractiveConsole.
+func (inst *QemuInstance) Console() (*Console, error) {
+	if inst.console != nil {
+		return inst.console, nil
+	}
+	if inst.consoleSocketPath == "" {
+		return nil, errors.New("the console is not interactive")
+	}
+	conn, err := net.Dial("unix", inst.consoleSocketPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting to console")
+	}
+	// The output is read from the log; drain the socket so that QEMU
+	// never blocks writing to it.
+	go io.Copy(io.Discard, conn) //nolint // Ignore Errors
+	inst.consoleConn = conn
+	inst.console = NewConsole(inst.consoleFile, conn)
+	return inst.console, nil
+}
+
+// ConsoleStep is a step of a console script.
+type ConsoleStep struct {
+	// Op is one of expect, send, sendline, key, timeout or sleep
+	Op  string
+	Arg string
+	// Duration is the argument of timeout and sleep
+	Duration time.Duration
+	// Line is the line of the step in the script
+	Line int
+}
+
+// ParseConsoleScript parses a console script, with one step per line:
+//
+//	expect REGEX      wait for output matching REGEX
+//	send TEXT         type TEXT
+//	sendline TEXT     type TEXT followed by Enter
+//	key NAME          press one of the ConsoleKeys
+//	timeout DURATION  set how long the following expects wait
+//	sleep DURATION    wait before the next step
+//
+// Arguments may be quoted as Go strings to include escapes and leading or
+// trailing spaces. Empty lines and lines starting with # are ignored.
+func ParseConsoleScript(r io.Reader) ([]ConsoleStep, error) {
+	var steps []ConsoleStep
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		op, arg, _ := strings.Cut(text, " ")
+		arg = strings.TrimSpace(arg)
+		if strings.HasPrefix(arg, `"`) {
+			unquoted, err := strconv.Unquote(arg)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: parsing %s: %w", line, arg, err)
+			}
+			arg = unquoted
+		}
+		step := ConsoleStep{Op: op, Arg: arg, Line: line}
+		switch op {
+		case "expect":
+			if _, err := regexp.Compile(arg); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		case "send", "sendline":
+		case "key":
+			if _, ok := ConsoleKeys[arg]; !ok {
+				return nil, fmt.Errorf("line %d: unknown key %q", line, arg)
+			}
+		case "timeout", "sleep":
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			step.Duration = d
+		default:
+			return nil, fmt.Errorf("line %d: unknown command %q", line, op)
+		}
+		steps = append(steps, step)
+	}
+	return steps, scanner.Err()
+}
+
+// RunScript runs the steps of a console script in order.
+func (c *Console) RunScript(steps []ConsoleStep) error {
+	for _, step := range steps {
+		var err error
+		switch step.Op {
+		case "expect":
+			_, err = c.Expect(step.Arg)
+		case "send":
+			err = c.Send(step.Arg)
+		case "sendline":
+			err = c.SendLine(step.Arg)
+		case "key":
+			err = c.SendKey(step.Arg)
+		case "timeout":
+			c.Timeout = step.Duration
+		case "sleep":
+			time.Sleep(step.Duration)
+		default:
+			err = fmt.Errorf("unknown command %q", step.Op)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "console script line %d", step.Line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.txt")
+	var input, transcript bytes.Buffer
+	console := NewConsole(path, &input)
+	console.Transcript = &transcript
+	defer console.Close()
+
+	// the log doesn't exist until QEMU creates it
+	if _, err := console.ExpectTimeout("login:", 0); err == nil {
+		t.Fatal("expected a timeout without a log")
+	}
+
+	if err := os.WriteFile(path, []byte("Please enter passphrase for disk root: "), 0644); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := console.Expect(`passphrase for disk (\w+)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matches, []string{"passphrase for disk root", "root"}) {
+		t.Errorf("unexpected matches %v", matches)
+	}
+	if err := console.SendLine("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if input.String() != "secret\r" {
+		t.Errorf("expected the passphrase and Enter, got %q", input.String())
+	}
+
+	// output is consumed by matches
+	if _, err := console.ExpectTimeout("passphrase", 0); err == nil {
+		t.Error("expected matched output to be consumed")
+	} else if !strings.Contains(err.Error(), `": "`) {
+		t.Errorf("expected the unmatched output in %v", err)
+	}
+	if !strings.Contains(transcript.String(), `root: <<send "secret\r">>`) {
+		t.Errorf("unexpected transcript %q", transcript.String())
+	}
+
+	if err := NewConsole(path, nil).Send("x"); err == nil {
+		t.Error("expected an error sending to a read-only console")
+	}
+}
+
+func TestConsoleScript(t *testing.T) {
+	steps, err := ParseConsoleScript(strings.NewReader(`
+# select the previous deployment
+timeout 30s
+expect "GRUB version"
+key down
+sendline " poweroff "
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ConsoleStep{
+		{Op: "timeout", Arg: "30s", Duration: 30 * time.Second, Line: 3},
+		{Op: "expect", Arg: "GRUB version", Line: 4},
+		{Op: "key", Arg: "down", Line: 5},
+		{Op: "sendline", Arg: " poweroff ", Line: 6},
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("expected %+v, got %+v", expected, steps)
+	}
+
+	path := filepath.Join(t.TempDir(), "console.txt")
+	if err := os.WriteFile(path, []byte("Welcome to GRUB version 2.12"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var input bytes.Buffer
+	console := NewConsole(path, &input)
+	defer console.Close()
+	if err := console.RunScript(steps); err != nil {
+		t.Fatal(err)
+	}
+	if console.Timeout != 30*time.Second || input.String() != "\x1b[B poweroff \r" {
+		t.Errorf("unexpected timeout %s or input %q", console.Timeout, input.String())
+	}
+
+	for _, script := range []string{"expect (", "key f13", "sleep soon", "send \"unterminated", "reboot"} {
+		if _, err := ParseConsoleScript(strings.NewReader(script)); err == nil {
+			t.Errorf("expected an error parsing %q", script)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -38,8 +39,22 @@ type Cluster struct {
 	*platform.BaseCluster
 	flight *flight
 
+	// privateNetwork connects the machines if requested by
+	// RuntimeConfig.PrivateNetwork
+	privateNetwork *privateNetwork
+
 	// Use atomic.Bool to prevent race conditions
 	tearingDown atomic.Bool
+
+	usageLock sync.Mutex
+	usage     []MachineResourceUsage
+}
+
+// MachineResourceUsage is the resource usage of a machine of the cluster,
+// measured right before the machine was destroyed.
+type MachineResourceUsage struct {
+	ID string `json:"id"`
+	platform.QemuResourceUsage
 }
 
 // MachineBuilder provides hooks to customize machine creation.
@@ -108,10 +123,17 @@ func (qc *Cluster) NewMachineWithBuilder(userdata any, options platform.MachineO
 		qemuBuilder.MountHost(src, dest, readonly)
 		config.MountHost(dest, readonly)
 	}
+	if options.Agent {
+		if err := qemuBuilder.EnableAgent(config); err != nil {
+			return nil, err
+		}
+	}
 
 	qemuBuilder.UUID = qm.id
 	qemuBuilder.ConsoleFile = qm.consolePath
+	qemuBuilder.InteractiveConsole = options.InteractiveConsole
 	qemuBuilder.NumaNodes = options.NumaNodes
+	qemuBuilder.HotplugSlots = options.HotplugSlots
 
 	if err := builder.SetupDisks(options, qemuBuilder); err != nil {
 		return nil, err
@@ -119,6 +141,17 @@ func (qc *Cluster) NewMachineWithBuilder(userdata any, options platform.MachineO
 	if err := builder.SetupNetwork(options, qemuBuilder); err != nil {
 		return nil, err
 	}
+	if qc.privateNetwork != nil {
+		ip, mac, err := qc.privateNetwork.allocate()
+		if err != nil {
+			return nil, err
+		}
+		qemuBuilder.AddPrivateNetwork(qc.privateNetwork.sw, mac)
+		if config != nil {
+			config.AddFile(privateNetworkConnection, privateNetworkKeyfile(ip, mac), 0600)
+			qm.privateIP = ip
+		}
+	}
 
 	// S390x specific stuff
 	if qc.flight.opts.SecureExecution {
@@ -162,8 +195,18 @@ func (qc *Cluster) NewMachineWithBuilder(userdata any, options platform.MachineO
 
 	// Run StartMachine, which blocks on the machine being booted up enough
 	// for SSH access, but only if we have a config, else there's no
-	// SSH key for us to use to get in so don't bother.
-	if config != nil {
+	// SSH key for us to use to get in so don't bother. Machines with the
+	// agent may not have SSH at all, so wait for the agent instead.
+	if options.Agent {
+		agent, err := inst.Agent()
+		if err == nil {
+			err = platform.StartMachineWithAgent(qm, qm.journal, agent)
+		}
+		if err != nil {
+			qm.Destroy()
+			return nil, err
+		}
+	} else if config != nil {
 		if err := platform.StartMachine(qm, qm.journal); err != nil {
 			qm.Destroy()
 			return nil, err
@@ -186,9 +229,31 @@ func (qc *Cluster) NewMachineWithBuilder(userdata any, options platform.MachineO
 	return qm, nil
 }
 
+// ResourceUsage returns the resource usage of the machines of the cluster
+// which were destroyed so far.
+func (qc *Cluster) ResourceUsage() []MachineResourceUsage {
+	qc.usageLock.Lock()
+	defer qc.usageLock.Unlock()
+	return append([]MachineResourceUsage(nil), qc.usage...)
+}
+
+func (qc *Cluster) recordResourceUsage(m *machine) {
+	usage, err := m.inst.ResourceUsage()
+	if err != nil {
+		plog.Debugf("Failed to get resource usage of machine %s: %v", m.ID(), err)
+		return
+	}
+	qc.usageLock.Lock()
+	defer qc.usageLock.Unlock()
+	qc.usage = append(qc.usage, MachineResourceUsage{ID: m.ID(), QemuResourceUsage: usage})
+}
+
 func (qc *Cluster) Destroy() {
 	qc.tearingDown.Store(true)
 	qc.BaseCluster.Destroy()
+	if qc.privateNetwork != nil {
+		qc.privateNetwork.sw.Close()
+	}
 	qc.flight.DelCluster(qc)
 }
 
@@ -296,6 +361,7 @@ func (qc *Cluster) InitDefaultBuilder(options platform.MachineOptions, builder *
 		builder.MemoryMiB = 4096 // SE needs at least 4GB
 	}
 	builder.Swtpm = qc.flight.opts.Swtpm
+	builder.GraphicsConsole = qc.flight.opts.ScreendumpOnFailure
 	builder.Hostname = fmt.Sprintf("qemu%d", qc.BaseCluster.AllocateMachineSerial())
 	if options.Firmware != "" {
 		builder.Firmware = options.Firmware
@@ -68,6 +68,12 @@ type Options struct {
 	// Option to create IBM cex based luks encryption
 	Cex bool
 
+	// Save a screendump of the graphical console of the machines of
+	// failed tests; this attaches a display device to every machine
+	ScreendumpOnFailure bool
+	// Save a dump of the guest memory of the machines of failed tests
+	DumpMemoryOnFailure bool
+
 	*platform.Options
 }
 
@@ -112,6 +118,9 @@ func (qf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, e
 		BaseCluster: bc,
 		flight:      qf,
 	}
+	if rconf.PrivateNetwork {
+		qc.privateNetwork = newPrivateNetwork()
+	}
 
 	qf.AddCluster(qc)
 
@@ -17,6 +17,7 @@ package qemu
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
@@ -33,6 +34,7 @@ type machine struct {
 	consolePath string
 	console     string
 	ip          string
+	privateIP   string // address on the cluster's private network, if any
 }
 
 func (m *machine) ID() string {
@@ -44,6 +46,9 @@ func (m *machine) IP() string {
 }
 
 func (m *machine) PrivateIP() string {
+	if m.privateIP != "" {
+		return m.privateIP
+	}
 	return m.ip
 }
 
@@ -97,6 +102,9 @@ func (m *machine) Destroy() {
 	}
 
 	if m.inst != nil {
+		if m.qc != nil {
+			m.qc.recordResourceUsage(m)
+		}
 		m.inst.Destroy()
 		m.inst = nil
 	}
@@ -146,3 +154,75 @@ func (m *machine) RemovePrimaryBlockDevice() error {
 func (m *machine) RemoveBlockDeviceForMultipath(device string) error {
 	return m.inst.RemoveBlockDeviceForMultipath(device)
 }
+
+func (m *machine) TriggerDiskFault(serial string) error {
+	return m.inst.TriggerDiskFault(serial)
+}
+
+func (m *machine) ThrottleDisk(serial string, iops, bps int64) error {
+	return m.inst.ThrottleDisk(serial, iops, bps)
+}
+
+func (m *machine) HotplugDisk(spec string) error {
+	return m.inst.HotplugDisk(spec)
+}
+
+func (m *machine) UnplugDisk(serial string) error {
+	return m.inst.UnplugDisk(serial)
+}
+
+func (m *machine) HotplugNIC(name, mac string) error {
+	return m.inst.HotplugNIC(name, mac)
+}
+
+func (m *machine) UnplugNIC(name string) error {
+	return m.inst.UnplugNIC(name)
+}
+
+func (m *machine) SetLinkState(nic string, up bool) error {
+	return m.inst.SetLinkState(nic, up)
+}
+
+func (m *machine) ImpairNetwork(n platform.NetworkImpairment) error {
+	return m.inst.ImpairNetwork(n)
+}
+
+func (m *machine) PartitionNetwork(other platform.QEMUMachine, partitioned bool) error {
+	o, ok := other.(*machine)
+	if !ok || o.qc != m.qc {
+		return fmt.Errorf("machine %q is not in the cluster of %q", other.ID(), m.ID())
+	}
+	return m.inst.PartitionNetwork(o.inst, partitioned)
+}
+
+func (m *machine) Agent() (*platform.Agent, error) {
+	return m.inst.Agent()
+}
+
+func (m *machine) Console() (*platform.Console, error) {
+	return m.inst.Console()
+}
+
+func (m *machine) Screendump(path string) error {
+	return m.inst.Screendump(path)
+}
+
+func (m *machine) DumpMemory(path string) error {
+	return m.inst.DumpMemory(path)
+}
+
+func (m *machine) Snapshot(name string) error {
+	return m.inst.SaveSnapshot(name)
+}
+
+func (m *machine) Revert(name string) error {
+	if err := m.inst.RestoreSnapshot(name); err != nil {
+		return err
+	}
+	// The guest was restored with its network state from the time of the
+	// snapshot; make sure it is reachable and healthy before handing it back.
+	if err := platform.CheckMachine(m); err != nil {
+		return fmt.Errorf("machine %q failed basic checks after revert: %v", m.ID(), err)
+	}
+	return nil
+}
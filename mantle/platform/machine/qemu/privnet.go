@@ -0,0 +1,83 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qemu
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+const (
+	// privateNetworkSubnet is the /24 used for the private network of
+	// every cluster. It doesn't clash with the usermode network
+	// (10.0.2.0/24) since each cluster is its own L2 segment.
+	privateNetworkSubnet = "10.77.0"
+	// first and last host addresses handed out to machines
+	privateNetworkFirstHost = 10
+	privateNetworkLastHost  = 254
+
+	privateNetworkConnection = "/etc/NetworkManager/system-connections/kola-private.nmconnection"
+)
+
+// privateNetwork is an L2 network shared by the machines of a cluster,
+// implemented by a platform.VirtualSwitch. Machines get static addresses.
+type privateNetwork struct {
+	sw *platform.VirtualSwitch
+
+	mu   sync.Mutex
+	next int
+}
+
+func newPrivateNetwork() *privateNetwork {
+	return &privateNetwork{
+		sw:   platform.NewVirtualSwitch(),
+		next: privateNetworkFirstHost,
+	}
+}
+
+// allocate returns the address and MAC address for a new machine.
+func (n *privateNetwork) allocate() (string, string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.next > privateNetworkLastHost {
+		return "", "", fmt.Errorf("private network is out of addresses")
+	}
+	host := n.next
+	n.next++
+	return fmt.Sprintf("%s.%d", privateNetworkSubnet, host), fmt.Sprintf("52:54:00:4b:4c:%02x", host), nil
+}
+
+// privateNetworkKeyfile returns a NetworkManager connection statically
+// configuring the NIC with the given MAC address.
+func privateNetworkKeyfile(ip, mac string) string {
+	return fmt.Sprintf(`[connection]
+id=kola-private
+type=ethernet
+autoconnect=true
+
+[ethernet]
+mac-address=%s
+
+[ipv4]
+method=manual
+address1=%s/24
+never-default=true
+
+[ipv6]
+method=disabled
+`, mac, ip)
+}
@@ -190,6 +190,15 @@ type MachineOptions struct {
 	BindMountHostRO           []string
 	BootFrom                  string
 	NoIgnition                bool
+	// HotplugSlots is the number of devices which can be hotplugged at
+	// once on machines with PCIe
+	HotplugSlots int
+	// Agent runs the kolet agent in the machine, to run commands without
+	// SSH through QEMUMachine.Agent()
+	Agent bool
+	// InteractiveConsole makes the serial console accept input through
+	// QEMUMachine.Console()
+	InteractiveConsole bool
 
 	// RequiredHostPorts lists host ports that this test requires exclusive
 	// access to (e.g., well-known service ports like NFS 2049 that cannot
@@ -253,6 +262,15 @@ func (m *MachineOptions) EnsureNoQEMUOnlyOptions(platformName string) error {
 	if len(m.RequiredHostPorts) > 0 {
 		return fmt.Errorf("platform %s does not support RequiredHostPorts", platformName)
 	}
+	if m.HotplugSlots > 0 {
+		return fmt.Errorf("platform %s does not support HotplugSlots", platformName)
+	}
+	if m.Agent {
+		return fmt.Errorf("platform %s does not support Agent", platformName)
+	}
+	if m.InteractiveConsole {
+		return fmt.Errorf("platform %s does not support InteractiveConsole", platformName)
+	}
 	return nil
 }
 
@@ -302,6 +320,10 @@ type RuntimeConfig struct {
 
 	// InternetAccess is true if the cluster should be Internet connected
 	InternetAccess bool
+	// PrivateNetwork is true if the machines of the cluster should be
+	// connected to each other by a private network. Only QEMU supports
+	// this; other platforms always have one.
+	PrivateNetwork bool
 	EarlyRelease   func()
 
 	// whether a Manhole into a machine should be created on detected failure
@@ -312,6 +334,24 @@ type RuntimeConfig struct {
 	// in-flight SSH commands when the test times out. If nil,
 	// context.Background() is used (no timeout).
 	TestExecTimeout context.Context
+
+	// MachineEvent, if set, is called with one of the MachineEvent*
+	// constants and the machine ID as machines change state.
+	MachineEvent func(event, id string)
+}
+
+// Machine state changes reported to RuntimeConfig.MachineEvent
+const (
+	MachineEventCreated    = "machine_created"
+	MachineEventSSHReady   = "ssh_ready"
+	MachineEventAgentReady = "agent_ready"
+	MachineEventDestroyed  = "machine_destroyed"
+)
+
+func (rc *RuntimeConfig) machineEvent(event, id string) {
+	if rc.MachineEvent != nil {
+		rc.MachineEvent(event, id)
+	}
 }
 
 // Wrap a StdoutPipe as a io.ReadCloser
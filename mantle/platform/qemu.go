@@ -38,6 +38,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -58,6 +59,10 @@ var (
 	// ErrInitramfsEmergency is the marker error returned upon node blocking in emergency mode in initramfs.
 	ErrInitramfsEmergency = errors.New("entered emergency.target in initramfs")
 
+	// snapshotNameRe restricts snapshot names to what can be safely passed
+	// through to the savevm/loadvm monitor commands.
+	snapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
 	ConsoleKernelArgument = map[string]string{
 		"x86_64":  "ttyS0,115200n8",
 		"ppc64le": "hvc0",
@@ -83,25 +88,71 @@ type QEMUMachine interface {
 	RemovePrimaryBlockDevice() error
 	// RemoveBlockDeviceForMultipath removes the specified device on multipath.
 	RemoveBlockDeviceForMultipath(device string) error
+	// TriggerDiskFault starts injecting the fault of the disk with the
+	// given serial, which was set up to wait for a trigger.
+	TriggerDiskFault(serial string) error
+	// ThrottleDisk limits the requests per second and the bytes per second
+	// of the disk with the given serial; 0 means no limit.
+	ThrottleDisk(serial string, iops, bps int64) error
+	// HotplugDisk adds a disk described by a spec as parsed by ParseDisk to
+	// the running machine. The disk is named after its serial.
+	HotplugDisk(spec string) error
+	// UnplugDisk removes a hotplugged disk given its serial.
+	UnplugDisk(serial string) error
+	// HotplugNIC adds a virtio-net NIC with user mode networking to the
+	// running machine. An empty mac picks one.
+	HotplugNIC(name, mac string) error
+	// UnplugNIC removes a hotplugged NIC.
+	UnplugNIC(name string) error
+	// SetLinkState brings the link of a NIC up or down. nic is the name
+	// of the netdev: "eth0" for the primary NIC, "eth1" and so on for
+	// additional NICs and "priv0" for the private network.
+	SetLinkState(nic string, up bool) error
+	// ImpairNetwork degrades the machine's traffic on the private network.
+	// The zero NetworkImpairment restores it.
+	ImpairNetwork(n NetworkImpairment) error
+	// PartitionNetwork drops or restores all traffic on the private
+	// network between the machine and another one of its cluster.
+	PartitionNetwork(other QEMUMachine, partitioned bool) error
+	// Snapshot saves the complete state of the machine under the given name.
+	Snapshot(name string) error
+	// Revert restores the machine to a state saved with Snapshot and waits
+	// for it to be reachable again.
+	Revert(name string) error
+	// Screendump saves an image of the graphical console to path.
+	Screendump(path string) error
+	// DumpMemory saves the guest memory as an ELF core file to path.
+	DumpMemory(path string) error
+	// Agent returns the client of the kolet agent, if enabled with
+	// MachineOptions.Agent.
+	Agent() (*Agent, error)
+	// Console returns an expect-style client of the serial console, if
+	// enabled with MachineOptions.InteractiveConsole.
+	Console() (*Console, error)
 }
 
 // Disk holds the details of a virtual disk.
 type Disk struct {
-	Size              string   // disk image size in bytes, optional suffixes "K", "M", "G", "T" allowed.
-	BackingFile       string   // raw disk image to use.
-	BackingFormat     string   // qcow2, raw, etc.  If unspecified will be autodetected.
-	Channel           string   // virtio (default), nvme, scsi
-	DeviceOpts        []string // extra options to pass to qemu -device. "serial=XXXX" makes disks show up as /dev/disk/by-id/virtio-<serial>
-	DriveOpts         []string // extra options to pass to -drive
-	SectorSize        int      // if not 0, override disk sector size
-	LogicalSectorSize int      // if not 0, override disk sector size
-	NbdDisk           bool     // if true, the disks should be presented over nbd:unix socket
-	MultiPathDisk     bool     // if true, present multiple paths
-	Wwn               uint64   // Optional World wide name for the SCSI disk. If not set or set to 0, a random one will be generated. Used only with "channel=scsi". Must be an integer
+	Size              string     // disk image size in bytes, optional suffixes "K", "M", "G", "T" allowed.
+	BackingFile       string     // raw disk image to use.
+	BackingFormat     string     // qcow2, raw, etc.  If unspecified will be autodetected.
+	Channel           string     // virtio (default), nvme, scsi
+	DeviceOpts        []string   // extra options to pass to qemu -device. "serial=XXXX" makes disks show up as /dev/disk/by-id/virtio-<serial>
+	DriveOpts         []string   // extra options to pass to -drive
+	SectorSize        int        // if not 0, override disk sector size
+	LogicalSectorSize int        // if not 0, override disk sector size
+	NbdDisk           bool       // if true, the disks should be presented over nbd:unix socket
+	MultiPathDisk     bool       // if true, present multiple paths
+	Wwn               uint64     // Optional World wide name for the SCSI disk. If not set or set to 0, a random one will be generated. Used only with "channel=scsi". Must be an integer
+	Fault             *DiskFault // if set, inject I/O errors
+	ThrottleIops      int64      // if not 0, limit the disk to this many requests per second
+	ThrottleBps       int64      // if not 0, limit the disk to this many bytes per second
 
 	attachEndPoint string   // qemuPath to attach to
 	dstFileName    string   // the prepared file
 	nbdServCmd     exec.Cmd // command to serve the disk
+	serial         string   // the serial of the device
+	driveIDs       []string // the -drive ids of the disk
 }
 
 func ParseDisk(spec string, allowNoSize bool) (*Disk, error) {
@@ -111,6 +162,8 @@ func ParseDisk(spec string, allowNoSize bool) (*Disk, error) {
 	serialOpt := []string{}
 	multipathed := false
 	var wwn uint64
+	var fault *DiskFault
+	var iops, bps int64
 
 	size, diskmap, err := util.ParseDiskSpec(spec, allowNoSize)
 	if err != nil {
@@ -136,6 +189,23 @@ func ParseDisk(spec string, allowNoSize bool) (*Disk, error) {
 			if err != nil {
 				return nil, fmt.Errorf("invalid value %s for wwn. Must be an integer", value)
 			}
+		case "fail", "fail-after", "fail-sector", "fail-trigger":
+			if fault == nil {
+				fault = &DiskFault{}
+			}
+			if err := parseDiskFault(fault, key, value); err != nil {
+				return nil, err
+			}
+		case "iops":
+			iops, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || iops <= 0 {
+				return nil, fmt.Errorf("invalid value %s for iops. Must be a positive integer", value)
+			}
+		case "bps":
+			bps, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || bps <= 0 {
+				return nil, fmt.Errorf("invalid value %s for bps. Must be a positive integer", value)
+			}
 		default:
 			return nil, fmt.Errorf("invalid key %q", key)
 		}
@@ -153,6 +223,9 @@ func ParseDisk(spec string, allowNoSize bool) (*Disk, error) {
 		LogicalSectorSize: logicalSectorSize,
 		MultiPathDisk:     multipathed,
 		Wwn:               wwn,
+		Fault:             fault,
+		ThrottleIops:      iops,
+		ThrottleBps:       bps,
 	}, nil
 }
 
@@ -185,6 +258,32 @@ type QemuInstance struct {
 
 	qmpSocket     *qmp.SocketMonitor
 	qmpSocketPath string
+
+	privateNetworkPort *SwitchPort
+
+	disks []*Disk
+	// snapshotBlockers describe the devices which prevent savevm
+	snapshotBlockers []string
+
+	// hotplugPCIe is true if hotplugged PCI devices need a free port
+	hotplugPCIe bool
+	// hotplugPorts are the free root ports for hotplugged devices
+	hotplugPorts []string
+	// hotplugged are the devices hotplugged by name
+	hotplugged map[string]*hotplugDevice
+	// hotplugCount is the number of disks hotplugged so far, including
+	// the unplugged ones, to name them
+	hotplugCount       int
+	restrictNetworking bool
+
+	agent *Agent
+
+	consoleFile       string
+	consoleSocketPath string
+	console           *Console
+	consoleConn       net.Conn
+
+	memoryMiB int
 }
 
 // Signaled returns whether QEMU process was signaled.
@@ -304,6 +403,15 @@ func (inst *QemuInstance) Destroy() {
 		inst.qmpSocket = nil
 		os.Remove(inst.qmpSocketPath) //nolint // Ignore Errors
 	}
+	if inst.agent != nil {
+		inst.agent.Close() //nolint // Ignore Errors
+		inst.agent = nil
+	}
+	if inst.console != nil {
+		inst.console.Close()     //nolint // Ignore Errors
+		inst.consoleConn.Close() //nolint // Ignore Errors
+		inst.console = nil
+	}
 	if inst.journalPipe != nil {
 		plog.Debugf("Sleep 1 to allow for more journal messages to get flushed")
 		time.Sleep(1 * time.Second)
@@ -324,6 +432,10 @@ func (inst *QemuInstance) Destroy() {
 		}
 	}
 	inst.helpers = nil
+	if inst.privateNetworkPort != nil {
+		inst.privateNetworkPort.Close()
+		inst.privateNetworkPort = nil
+	}
 
 	if inst.tempdir != "" {
 		if err := os.RemoveAll(inst.tempdir); err != nil {
@@ -476,6 +588,111 @@ func (inst *QemuInstance) RemoveBlockDeviceForMultipath(device string) error {
 	return nil
 }
 
+// SaveSnapshot saves the VM state (memory, devices and the contents of all
+// writable disks) as an internal snapshot. The guest is paused while the
+// snapshot is written. All writable drives must be qcow2, so this does not
+// work with UEFI firmware (whose variable store is raw), NBD-backed disks or
+// disks with injected faults; virtiofs mounts can't be snapshotted either.
+func (inst *QemuInstance) SaveSnapshot(name string) error {
+	if !snapshotNameRe.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	if len(inst.snapshotBlockers) > 0 {
+		return fmt.Errorf("cannot snapshot a machine with %s", strings.Join(inst.snapshotBlockers, ", "))
+	}
+	return inst.saveVM(name)
+}
+
+// RestoreSnapshot reverts the VM to a snapshot taken with SaveSnapshot.
+// The guest resumes running from the saved state immediately.
+func (inst *QemuInstance) RestoreSnapshot(name string) error {
+	if !snapshotNameRe.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return inst.loadVM(name)
+}
+
+// Screendump saves the graphical console as a PNG image. This requires
+// QemuBuilder.GraphicsConsole.
+func (inst *QemuInstance) Screendump(path string) error {
+	return inst.screendump(path)
+}
+
+// DumpMemory writes the guest's memory to path as an ELF core file, which
+// can be analyzed with crash like a kdump vmcore. The guest is paused while
+// the dump is written.
+func (inst *QemuInstance) DumpMemory(path string) error {
+	return inst.dumpGuestMemory(path)
+}
+
+// TriggerDiskFault starts injecting the fault of the disk with the given
+// serial, which must have been set up with DiskFault.Trigger.
+func (inst *QemuInstance) TriggerDiskFault(serial string) error {
+	disk, err := inst.findDisk(serial)
+	if err != nil {
+		return err
+	}
+	if disk.Fault == nil || !disk.Fault.Trigger {
+		return fmt.Errorf("disk %s has no fault waiting for a trigger", serial)
+	}
+	for _, id := range disk.driveIDs {
+		if err := inst.insertBlkdebug(id, disk.Fault); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ThrottleDisk limits the requests per second and the bytes per second of
+// the disk with the given serial; 0 means no limit.
+func (inst *QemuInstance) ThrottleDisk(serial string, iops, bps int64) error {
+	disk, err := inst.findDisk(serial)
+	if err != nil {
+		return err
+	}
+	for _, id := range disk.driveIDs {
+		if err := inst.setIOThrottle(id, iops, bps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (inst *QemuInstance) findDisk(serial string) (*Disk, error) {
+	for _, disk := range inst.disks {
+		if disk.serial == serial {
+			return disk, nil
+		}
+	}
+	return nil, fmt.Errorf("no disk with serial %q", serial)
+}
+
+// SetLinkState brings the link of a NIC up or down, as if its cable was
+// plugged in or pulled. nic is the name of the netdev: "eth0" for the
+// primary NIC (which also carries SSH), "eth1" and so on for additional
+// NICs and "priv0" for the private network.
+func (inst *QemuInstance) SetLinkState(nic string, up bool) error {
+	return inst.setLink(nic, up)
+}
+
+// ImpairNetwork degrades the instance's traffic on the private network.
+// Pass the zero NetworkImpairment to restore it.
+func (inst *QemuInstance) ImpairNetwork(n NetworkImpairment) error {
+	if inst.privateNetworkPort == nil {
+		return fmt.Errorf("instance is not connected to a private network")
+	}
+	return inst.privateNetworkPort.SetImpairment(n)
+}
+
+// PartitionNetwork drops or restores all traffic between the instance and
+// another one on the same private network.
+func (inst *QemuInstance) PartitionNetwork(other *QemuInstance, partitioned bool) error {
+	if inst.privateNetworkPort == nil || other.privateNetworkPort == nil {
+		return fmt.Errorf("instance is not connected to a private network")
+	}
+	return inst.privateNetworkPort.sw.SetPartitioned(inst.privateNetworkPort, other.privateNetworkPort, partitioned)
+}
+
 // A directory mounted from the host into the guest, via 9p or virtiofs
 type HostMount struct {
 	src      string
@@ -493,6 +710,9 @@ type QemuBuilder struct {
 
 	// File to which to redirect the serial console
 	ConsoleFile string
+	// InteractiveConsole makes the serial console logged to ConsoleFile
+	// accept input through QemuInstance.Console()
+	InteractiveConsole bool
 
 	// If set, use QEMU full emulation for the target architecture
 	architecture string
@@ -510,6 +730,14 @@ type QemuBuilder struct {
 	Pdeathsig  bool
 	Argv       []string
 
+	// GraphicsConsole attaches a display device (but no window) so that
+	// screendumps of the graphical console can be taken
+	GraphicsConsole bool
+
+	// HotplugSlots is the number of PCIe root ports left empty for
+	// hotplugged devices, on machines which need them
+	HotplugSlots int
+
 	// AppendKernelArgs are appended to the bootloader config
 	AppendKernelArgs string
 
@@ -540,6 +768,8 @@ type QemuBuilder struct {
 	RestrictNetworking        bool
 	requestedHostForwardPorts []HostForwardPort
 	additionalNics            int
+	privateNetwork            *VirtualSwitch
+	privateNetworkMAC         string
 	netbootP                  string
 	netbootDir                string
 	netbootIndex              string
@@ -551,6 +781,8 @@ type QemuBuilder struct {
 	virtioSerialID uint
 	// hostMounts is an array of directories mounted (via 9p or virtiofs) from the host
 	hostMounts []HostMount
+	// agentSocketPath is the socket of the kolet agent channel, if enabled
+	agentSocketPath string
 	// fds is file descriptors we own to pass to qemu
 	fds []*os.File
 
@@ -691,6 +923,14 @@ func (builder *QemuBuilder) AddAdditionalNics(additionalNics int) {
 	builder.additionalNics = additionalNics
 }
 
+// AddPrivateNetwork adds a NIC with the given MAC address connected to a
+// new port on sw. All instances connected to the same switch share an L2
+// network.
+func (builder *QemuBuilder) AddPrivateNetwork(sw *VirtualSwitch, mac string) {
+	builder.privateNetwork = sw
+	builder.privateNetworkMAC = mac
+}
+
 func (builder *QemuBuilder) setupNetworking() error {
 	netdev := "user,id=eth0"
 	for i := range builder.requestedHostForwardPorts {
@@ -778,6 +1018,22 @@ func (builder *QemuBuilder) setupAdditionalNetworking() error {
 	return nil
 }
 
+func (builder *QemuBuilder) setupPrivateNetworking(inst *QemuInstance) error {
+	port, err := builder.privateNetwork.AddPort()
+	if err != nil {
+		return errors.Wrapf(err, "connecting to private network")
+	}
+	inst.privateNetworkPort = port
+	netdev := port.NetdevArg("priv0")
+	device := virtio(builder.architecture, "net", fmt.Sprintf("netdev=priv0,mac=%s", builder.privateNetworkMAC))
+	// Keep the device out of the way of the additional NICs; see above
+	if builder.architecture == "s390x" {
+		device += ",devno=fe.2.0000"
+	}
+	builder.Append("-netdev", netdev, "-device", device)
+	return nil
+}
+
 // SetArchitecture enables qemu full emulation for the target architecture.
 func (builder *QemuBuilder) SetArchitecture(arch string) error {
 	switch arch {
@@ -893,6 +1149,18 @@ func (builder *QemuBuilder) supportsSwtpm() bool {
 	return true
 }
 
+// graphicsDevice returns the display device for the architecture, or "" if
+// there is none the firmware can use
+func (builder *QemuBuilder) graphicsDevice() string {
+	switch builder.architecture {
+	case "x86_64", "ppc64le":
+		return "VGA"
+	case "aarch64":
+		return "virtio-gpu-pci"
+	}
+	return ""
+}
+
 // fileRemoteLocation is a bit misleading. We are NOT putting the ignition config in the root parition. We mount the boot partition on / just to get around the fact that
 // the root partition does not need to be mounted to inject ignition config. Now that we have LUKS , we have to do more work to detect a LUKS root partition
 // and it is not needed here.
@@ -1137,7 +1405,18 @@ func (disk *Disk) prepare(builder *QemuBuilder) error {
 	}
 	disk.dstFileName = tmpf.Name()
 
-	imgOpts := []string{"create", "-f", "qcow2", disk.dstFileName}
+	imgFormat := "qcow2"
+	if disk.Fault != nil {
+		if err := disk.Fault.validate(); err != nil {
+			return err
+		}
+		// the sectors of faults are those of the image, so it must be raw
+		if disk.BackingFile != "" || disk.MultiPathDisk || disk.NbdDisk {
+			return errors.New("fault injection is only supported on local disks without a backing file")
+		}
+		imgFormat = "raw"
+	}
+	imgOpts := []string{"create", "-f", imgFormat, disk.dstFileName}
 	// On filesystems like btrfs, qcow2 files can become much more fragmented
 	// if copy-on-write is enabled.  We don't need that, our disks are ephemeral.
 	// https://gitlab.gnome.org/GNOME/gnome-boxes/-/issues/88
@@ -1227,15 +1506,18 @@ func (builder *QemuBuilder) addDiskImpl(disk *Disk, primary bool) error {
 	diskOpts := disk.DeviceOpts
 	if primary {
 		diskOpts = append(diskOpts, "serial=primary-disk")
+		disk.serial = "primary-disk"
 	} else {
 		foundserial := false
 		for _, opt := range diskOpts {
 			if strings.HasPrefix(opt, "serial=") {
 				foundserial = true
+				disk.serial = strings.TrimPrefix(opt, "serial=")
 			}
 		}
 		if !foundserial {
-			diskOpts = append(diskOpts, "serial="+fmt.Sprintf("disk%d", builder.diskID))
+			disk.serial = fmt.Sprintf("disk%d", builder.diskID)
+			diskOpts = append(diskOpts, "serial="+disk.serial)
 		}
 	}
 	channel := disk.Channel
@@ -1265,10 +1547,30 @@ func (builder *QemuBuilder) addDiskImpl(disk *Disk, primary bool) error {
 	// Avoid file locking detection, and the disks we create
 	// here are always currently ephemeral.
 	defaultDiskOpts := "auto-read-only=off,cache=unsafe"
+	if disk.ThrottleIops > 0 {
+		defaultDiskOpts += fmt.Sprintf(",throttling.iops-total=%d", disk.ThrottleIops)
+	}
+	if disk.ThrottleBps > 0 {
+		defaultDiskOpts += fmt.Sprintf(",throttling.bps-total=%d", disk.ThrottleBps)
+	}
 	if len(disk.DriveOpts) > 0 {
 		defaultDiskOpts += "," + strings.Join(disk.DriveOpts, ",")
 	}
 
+	// Faults are injected by a blkdebug node between the raw format and
+	// the file, either from the start or once triggered, for which the
+	// file node needs a name.
+	fileOpt := "file=" + disk.attachEndPoint
+	if disk.Fault != nil && disk.Fault.Trigger {
+		fileOpt = fmt.Sprintf("format=raw,file=%s,file.node-name=%s-file", disk.attachEndPoint, id)
+	} else if disk.Fault != nil {
+		file, err := disk.Fault.driveFile(disk.attachEndPoint)
+		if err != nil {
+			return err
+		}
+		fileOpt = "format=raw,file=" + file
+	}
+
 	if disk.MultiPathDisk || channel == "scsi" {
 		// Fake a NVME or SCSI device with a fake WWN.
 		// The WWN needs to be a unique uint64 number
@@ -1304,6 +1606,7 @@ func (builder *QemuBuilder) addDiskImpl(disk *Disk, primary bool) error {
 						scsiID, pID, wwn, opts))
 				builder.Append("-drive", fmt.Sprintf("if=none,id=%s,format=raw,file=%s,media=disk,%s",
 					pID, disk.attachEndPoint, defaultDiskOpts))
+				disk.driveIDs = append(disk.driveIDs, pID)
 			}
 		} else {
 			scsiID := fmt.Sprintf("scsi_%d", builder.diskID)
@@ -1311,8 +1614,9 @@ func (builder *QemuBuilder) addDiskImpl(disk *Disk, primary bool) error {
 			builder.Append("-device",
 				fmt.Sprintf("scsi-hd,bus=%s.0,drive=%s,wwn=%d%s",
 					scsiID, id, wwn, opts))
-			builder.Append("-drive", fmt.Sprintf("if=none,id=%s,file=%s,media=disk,%s",
-				id, disk.attachEndPoint, defaultDiskOpts))
+			builder.Append("-drive", fmt.Sprintf("if=none,id=%s,%s,media=disk,%s",
+				id, fileOpt, defaultDiskOpts))
+			disk.driveIDs = append(disk.driveIDs, id)
 		}
 
 	} else {
@@ -1333,8 +1637,9 @@ func (builder *QemuBuilder) addDiskImpl(disk *Disk, primary bool) error {
 		}
 
 		// Default to cache=unsafe
-		builder.Append("-drive", fmt.Sprintf("if=none,id=%s,file=%s,%s",
-			id, disk.attachEndPoint, defaultDiskOpts))
+		builder.Append("-drive", fmt.Sprintf("if=none,id=%s,%s,%s",
+			id, fileOpt, defaultDiskOpts))
+		disk.driveIDs = append(disk.driveIDs, id)
 	}
 	return nil
 }
@@ -1744,6 +2049,61 @@ func (builder *QemuBuilder) VirtioChannelRead(name string) (*os.File, error) {
 	return r, nil
 }
 
+// VirtioChannel creates a bidirectional virtio-serial port, backed by a unix
+// socket on which QEMU listens. It returns the path of the socket, which
+// exists once the instance is started.
+func (builder *QemuBuilder) VirtioChannel(name string) (string, error) {
+	if err := builder.ensureTempdir(); err != nil {
+		return "", err
+	}
+	if builder.virtioSerialID == 0 {
+		builder.Append("-device", "virtio-serial")
+	}
+	builder.virtioSerialID++
+	id := fmt.Sprintf("virtioserial%d", builder.virtioSerialID)
+	path := filepath.Join(builder.tempdir, id+".sock")
+	builder.Append("-chardev", fmt.Sprintf("socket,id=%s,path=%s,server=on,wait=off", id, path))
+	builder.Append("-device", fmt.Sprintf("virtserialport,chardev=%s,name=%s", id, name))
+
+	return path, nil
+}
+
+// EnableAgent configures the OS and VM to run the kolet agent on a
+// virtio-serial channel, so that QemuInstance.Agent() can run commands and
+// transfer files without SSH. A copy of the kolet binary for the target
+// architecture is mounted read-only in the guest. The agent only runs in
+// the real root, not in the initramfs; use the console there.
+func (builder *QemuBuilder) EnableAgent(config *conf.Conf) error {
+	if builder.agentSocketPath != "" {
+		return errors.New("agent already enabled")
+	}
+	if config == nil {
+		return errors.New("the agent needs an Ignition config")
+	}
+	koletPath, err := FindKolet(builder.architecture)
+	if err != nil {
+		return err
+	}
+	path, err := builder.VirtioChannel(conf.AgentDeviceName)
+	if err != nil {
+		return err
+	}
+	// Share a copy rather than the directory of kolet, which may hold
+	// anything
+	dir := filepath.Join(builder.tempdir, "kolet")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	if err := system.CopyRegularFile(koletPath, filepath.Join(dir, "kolet")); err != nil {
+		return errors.Wrapf(err, "copying kolet")
+	}
+	builder.agentSocketPath = path
+	builder.MountHost(dir, agentMountPath, true)
+	config.MountHost(agentMountPath, true)
+	config.AddAgentUnit(filepath.Join(agentMountPath, "kolet"))
+	return nil
+}
+
 // SerialPipe reads the serial console output into a pipe
 func (builder *QemuBuilder) SerialPipe() (*os.File, error) {
 	r, w, err := os.Pipe()
@@ -1796,6 +2156,28 @@ func createVirtiofsCmd(directory, socketPath string) exec.Cmd {
 	return cmd
 }
 
+// snapshotBlockers returns the devices of the machine which prevent savevm:
+// it only supports writable drives in qcow2 format, and migration (on which
+// it is built) isn't supported by vhost-user-fs.
+func (builder *QemuBuilder) snapshotBlockers() []string {
+	var blockers []string
+	if strings.HasPrefix(builder.Firmware, "uefi") {
+		blockers = append(blockers, "UEFI firmware (raw pflash variable store)")
+	}
+	for _, disk := range builder.disks {
+		switch {
+		case disk.Fault != nil:
+			blockers = append(blockers, fmt.Sprintf("raw disk %s (fault injection)", disk.serial))
+		case disk.MultiPathDisk || disk.NbdDisk:
+			blockers = append(blockers, fmt.Sprintf("raw disk %s (NBD)", disk.serial))
+		}
+	}
+	if len(builder.hostMounts) > 0 {
+		blockers = append(blockers, "virtiofs mounts")
+	}
+	return blockers
+}
+
 // Exec tries to run a QEMU instance with the given settings.
 func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 	builder.finalize()
@@ -1805,7 +2187,7 @@ func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 		return nil, errors.Wrapf(err, "rendering ignition")
 	}
 
-	inst := QemuInstance{}
+	inst := QemuInstance{memoryMiB: builder.MemoryMiB}
 	cleanupInst := false
 	defer func() {
 		if cleanupInst {
@@ -1872,6 +2254,18 @@ func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 		return nil, fmt.Errorf("unknown firmware: %s", builder.Firmware)
 	}
 
+	// Devices can only be hotplugged into root ports on PCIe machines;
+	// elsewhere they are hotplugged into the main bus.
+	inst.hotplugPCIe = builder.architecture == "aarch64" || (builder.architecture == "x86_64" && strings.HasPrefix(builder.Firmware, "uefi"))
+	if inst.hotplugPCIe {
+		for i := 0; i < builder.HotplugSlots; i++ {
+			id := fmt.Sprintf("hotplug-port%d", i)
+			argv = append(argv, "-device", fmt.Sprintf("pcie-root-port,id=%s,chassis=%d", id, i+1))
+			inst.hotplugPorts = append(inst.hotplugPorts, id)
+		}
+	}
+	inst.restrictNetworking = builder.RestrictNetworking
+
 	// We always provide a random source
 	argv = append(argv, "-object", "rng-random,filename=/dev/urandom,id=rng0",
 		"-device", virtio(builder.architecture, "rng", "rng=rng0"))
@@ -1884,6 +2278,11 @@ func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 
 	// We want to customize everything from scratch, so avoid defaults
 	argv = append(argv, "-nodefaults")
+	if builder.GraphicsConsole {
+		if dev := builder.graphicsDevice(); dev != "" {
+			argv = append(argv, "-device", dev)
+		}
+	}
 
 	// We only render Ignition lazily, because we want to support calling
 	// SetConfig() after AddPrimaryDisk() or AddInstallIso().
@@ -1932,6 +2331,8 @@ func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 			inst.helpers = append(inst.helpers, cmd)
 		}
 	}
+	inst.disks = builder.disks
+	inst.snapshotBlockers = builder.snapshotBlockers()
 
 	// Handle Usermode Networking
 	if builder.UsermodeNetworking {
@@ -1948,6 +2349,13 @@ func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 		}
 	}
 
+	// Handle the private network shared with other instances
+	if builder.privateNetwork != nil {
+		if err := builder.setupPrivateNetworking(&inst); err != nil {
+			return nil, err
+		}
+	}
+
 	// Handle Software TPM
 	if builder.Swtpm && builder.supportsSwtpm() {
 		err = builder.ensureTempdir()
@@ -2071,7 +2479,16 @@ func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 		fdnum++
 	}
 
-	if builder.ConsoleFile != "" {
+	if builder.ConsoleFile != "" && builder.InteractiveConsole {
+		if err := builder.ensureTempdir(); err != nil {
+			return nil, err
+		}
+		// The socket takes the input; the output is read from the log
+		// so that none is lost while no client is connected.
+		inst.consoleFile = builder.ConsoleFile
+		inst.consoleSocketPath = filepath.Join(builder.tempdir, "console.sock")
+		builder.Append("-display", "none", "-chardev", fmt.Sprintf("socket,id=log,path=%s,server=on,wait=off,logfile=%s", inst.consoleSocketPath, builder.ConsoleFile), "-serial", "chardev:log")
+	} else if builder.ConsoleFile != "" {
 		builder.Append("-display", "none", "-chardev", "file,id=log,path="+builder.ConsoleFile, "-serial", "chardev:log")
 	} else {
 		builder.Append("-serial", "mon:stdio")
@@ -2106,6 +2523,13 @@ func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 
 	plog.Debugf("Started qemu (%v) with args: %v", inst.qemu.Pid(), argv)
 
+	if builder.agentSocketPath != "" {
+		path := builder.agentSocketPath
+		inst.agent = newAgent(func() (net.Conn, error) {
+			return net.Dial("unix", path)
+		})
+	}
+
 	// Transfer ownership of the tempdir
 	inst.tempdir = builder.tempdir
 	builder.tempdir = ""
@@ -0,0 +1,51 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotBlockers(t *testing.T) {
+	builder := &QemuBuilder{Firmware: "bios"}
+	builder.disks = []*Disk{{serial: "primary-disk"}}
+	if blockers := builder.snapshotBlockers(); len(blockers) != 0 {
+		t.Errorf("expected no blockers, got %v", blockers)
+	}
+
+	builder = &QemuBuilder{Firmware: "uefi-secure"}
+	builder.disks = []*Disk{
+		{serial: "primary-disk"},
+		{serial: "mpath", MultiPathDisk: true},
+		{serial: "bad", Fault: &DiskFault{IOType: "read"}},
+	}
+	builder.hostMounts = []HostMount{{src: "/srv", dest: "/srv"}}
+	expected := []string{
+		"UEFI firmware (raw pflash variable store)",
+		"raw disk mpath (NBD)",
+		"raw disk bad (fault injection)",
+		"virtiofs mounts",
+	}
+	if blockers := builder.snapshotBlockers(); !reflect.DeepEqual(blockers, expected) {
+		t.Errorf("expected %v, got %v", expected, blockers)
+	}
+
+	inst := &QemuInstance{snapshotBlockers: expected}
+	if err := inst.SaveSnapshot("clean"); err == nil || !strings.Contains(err.Error(), "virtiofs mounts") {
+		t.Errorf("expected an error naming the blockers, got %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The kolet agent runs commands and transfers files for the harness over a
+// virtio-serial port, so that machines without SSH (restricted networking,
+// emergency mode, sshd not started yet) can still be inspected. The protocol
+// is one JSON object per line in each direction; the agent answers requests
+// in order, and each response carries the ID of its request.
+
+// agentMountPath is where the copy of kolet is mounted in the guest
+const agentMountPath = "/run/kolet"
+
+// DefaultAgentTimeout is the default Agent.Timeout. It includes booting,
+// as the first request waits for the agent to start.
+const DefaultAgentTimeout = 10 * time.Minute
+
+const (
+	agentOpExec  = "exec"
+	agentOpRead  = "read"
+	agentOpWrite = "write"
+)
+
+// AgentRequest is a request to the kolet agent.
+type AgentRequest struct {
+	ID uint64 `json:"id"`
+	// Op is one of exec, read or write
+	Op string `json:"op"`
+	// Command is run with /bin/sh -c for exec
+	Command string `json:"command,omitempty"`
+	// Path is the file to read or write
+	Path string `json:"path,omitempty"`
+	// Data is the content to write
+	Data []byte      `json:"data,omitempty"`
+	Mode os.FileMode `json:"mode,omitempty"`
+}
+
+// AgentResponse is the answer of the kolet agent to an AgentRequest.
+type AgentResponse struct {
+	ID         uint64 `json:"id"`
+	Stdout     []byte `json:"stdout,omitempty"`
+	Stderr     []byte `json:"stderr,omitempty"`
+	ExitStatus int    `json:"exitStatus"`
+	// Data is the content of a file read
+	Data []byte `json:"data,omitempty"`
+	// Error is set if the request could not be handled at all
+	Error string `json:"error,omitempty"`
+}
+
+// AgentExitError is returned by Agent.Exec for commands which exit with a
+// non-zero status.
+type AgentExitError struct {
+	Command    string
+	ExitStatus int
+}
+
+func (e *AgentExitError) Error() string {
+	return fmt.Sprintf("command %q exited with status %d", e.Command, e.ExitStatus)
+}
+
+// Agent is a client of the kolet agent of a machine. Requests are
+// serialized; it is safe for concurrent use.
+type Agent struct {
+	// Timeout bounds each request, including waiting for the agent to
+	// start. It defaults to DefaultAgentTimeout; zero means no timeout.
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	dial   func() (net.Conn, error)
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID uint64
+}
+
+func newAgent(dial func() (net.Conn, error)) *Agent {
+	return &Agent{Timeout: DefaultAgentTimeout, dial: dial}
+}
+
+// Agent returns the client of the kolet agent enabled with
+// QemuBuilder.EnableAgent.
+func (inst *QemuInstance) Agent() (*Agent, error) {
+	if inst.agent == nil {
+		return nil, errors.New("the agent is not enabled")
+	}
+	return inst.agent, nil
+}
+
+// Exec runs cmd as root in the guest and returns its stdout and stderr,
+// with leading and trailing whitespace trimmed, like Machine.SSH. If the
+// command exits with a non-zero status, the error is an *AgentExitError.
+func (a *Agent) Exec(cmd string) ([]byte, []byte, error) {
+	resp, err := a.do(AgentRequest{Op: agentOpExec, Command: cmd})
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout := bytes.TrimSpace(resp.Stdout)
+	stderr := bytes.TrimSpace(resp.Stderr)
+	if resp.ExitStatus != 0 {
+		return stdout, stderr, &AgentExitError{Command: cmd, ExitStatus: resp.ExitStatus}
+	}
+	return stdout, stderr, nil
+}
+
+// ReadFile returns the content of a file in the guest.
+func (a *Agent) ReadFile(path string) ([]byte, error) {
+	resp, err := a.do(AgentRequest{Op: agentOpRead, Path: path})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// WriteFile writes a file in the guest, replacing it if it exists. A zero
+// mode means 0644.
+func (a *Agent) WriteFile(path string, data []byte, mode os.FileMode) error {
+	_, err := a.do(AgentRequest{Op: agentOpWrite, Path: path, Data: data, Mode: mode})
+	return err
+}
+
+// Close closes the connection to the agent.
+func (a *Agent) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn == nil {
+		return nil
+	}
+	err := a.conn.Close()
+	a.conn = nil
+	return err
+}
+
+func (a *Agent) do(req AgentRequest) (*AgentResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.nextID++
+	req.ID = a.nextID
+	resp, err := a.roundTrip(req)
+	if err != nil {
+		// The connection may be in the middle of a response; start over
+		// with a fresh one for the next request. Stale responses are
+		// skipped by ID.
+		if a.conn != nil {
+			a.conn.Close()
+			a.conn = nil
+		}
+		return nil, errors.Wrapf(err, "agent request %s", req.Op)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("agent request %s: %s", req.Op, resp.Error)
+	}
+	return resp, nil
+}
+
+func (a *Agent) roundTrip(req AgentRequest) (*AgentResponse, error) {
+	if a.conn == nil {
+		conn, err := a.dial()
+		if err != nil {
+			return nil, err
+		}
+		a.conn = conn
+		a.reader = bufio.NewReader(conn)
+	}
+	deadline := time.Time{}
+	if a.Timeout != 0 {
+		deadline = time.Now().Add(a.Timeout)
+	}
+	if err := a.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.conn.Write(append(buf, '\n')); err != nil {
+		return nil, err
+	}
+	for {
+		line, err := a.reader.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		var resp AgentResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			plog.Debugf("skipping malformed agent response: %v", err)
+			continue
+		}
+		if resp.ID == req.ID {
+			return &resp, nil
+		}
+		plog.Debugf("skipping stale agent response %d", resp.ID)
+	}
+}
+
+// ServeAgent handles agent requests read from rw until it reaches EOF. It
+// is the guest side of the agent, run by kolet.
+func ServeAgent(rw io.ReadWriter) error {
+	r := bufio.NewReader(rw)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var req AgentRequest
+		var resp AgentResponse
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = fmt.Sprintf("parsing request: %v", err)
+		} else {
+			resp = handleAgentRequest(req)
+		}
+		buf, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		if _, err := rw.Write(append(buf, '\n')); err != nil {
+			return err
+		}
+	}
+}
+
+func handleAgentRequest(req AgentRequest) AgentResponse {
+	resp := AgentResponse{ID: req.ID}
+	switch req.Op {
+	case agentOpExec:
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command("/bin/sh", "-c", req.Command)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		resp.Stdout = stdout.Bytes()
+		resp.Stderr = stderr.Bytes()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			resp.ExitStatus = exitErr.ExitCode()
+		} else if err != nil {
+			resp.Error = err.Error()
+		}
+	case agentOpRead:
+		data, err := os.ReadFile(req.Path)
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		resp.Data = data
+	case agentOpWrite:
+		if err := writeFileAtomic(req.Path, req.Data, req.Mode); err != nil {
+			resp.Error = err.Error()
+		}
+	default:
+		resp.Error = fmt.Sprintf("unknown op %q", req.Op)
+	}
+	return resp
+}
+
+// writeFileAtomic writes a file through a temporary file in the same
+// directory, so that path units don't see it half-written.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	if mode == 0 {
+		mode = 0644
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
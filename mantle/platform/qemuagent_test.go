@@ -0,0 +1,172 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+)
+
+func TestAgent(t *testing.T) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		server, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer server.Close()
+		// stale responses from an earlier connection come first
+		if _, err := server.Write([]byte("garbage\n{\"id\":42}\n")); err != nil {
+			return
+		}
+		ServeAgent(server) //nolint // Ignore Errors
+	}()
+	agent := newAgent(func() (net.Conn, error) { return net.Dial("unix", l.Addr().String()) })
+	defer agent.Close()
+
+	stdout, stderr, err := agent.Exec("echo out; echo err >&2; exit 3")
+	exitErr, ok := err.(*AgentExitError)
+	if !ok || exitErr.ExitStatus != 3 {
+		t.Fatalf("expected exit status 3, got %v", err)
+	}
+	if string(stdout) != "out" || string(stderr) != "err" {
+		t.Errorf("expected trimmed output, got %q and %q", stdout, stderr)
+	}
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := agent.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a file with mode 0600, got %v, %v", info, err)
+	}
+	data, err := agent.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("expected data, got %q", data)
+	}
+
+	if _, err := agent.ReadFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error reading a missing file")
+	}
+}
+
+func TestEnableAgent(t *testing.T) {
+	// FindKolet looks in the current directory first
+	koletDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(koletDir, "kolet"), []byte("kolet"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(koletDir, "secret"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(koletDir)
+
+	config, err := conf.EmptyIgnition().Render(conf.FailWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := NewQemuBuilder()
+	if err := builder.EnableAgent(nil); err == nil {
+		t.Error("expected enabling the agent without a config to fail")
+	}
+	if err := builder.EnableAgent(config); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(builder.tempdir)
+
+	// only a copy of kolet is shared
+	if len(builder.hostMounts) != 1 || filepath.Dir(builder.hostMounts[0].src) != builder.tempdir {
+		t.Fatalf("expected kolet to be shared from the builder tempdir, got %+v", builder.hostMounts)
+	}
+	entries, err := os.ReadDir(builder.hostMounts[0].src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "kolet" {
+		t.Errorf("expected only kolet to be shared, got %v", entries)
+	}
+
+	// the agent doesn't run in the initramfs
+	if !strings.Contains(config.String(), "ConditionPathExists=!/etc/initrd-release") {
+		t.Error("expected the agent unit to be skipped in the initramfs")
+	}
+	if err := builder.EnableAgent(config); err == nil {
+		t.Error("expected enabling the agent twice to fail")
+	}
+}
+
+// agentMachine is a machine reached only through the agent; it has no SSH.
+type agentMachine struct {
+	Machine
+
+	mu     sync.Mutex
+	events []string
+}
+
+func (m *agentMachine) ID() string { return "agent-machine" }
+
+func (m *agentMachine) IgnitionError() error {
+	// Ignition succeeded
+	return nil
+}
+
+func (m *agentMachine) RuntimeConf() RuntimeConfig {
+	return RuntimeConfig{
+		MachineEvent: func(event, id string) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.events = append(m.events, event)
+		},
+	}
+}
+
+func TestStartMachineWithAgent(t *testing.T) {
+	m := &agentMachine{}
+	agent := newAgent(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			ServeAgent(server) //nolint // Ignore Errors
+		}()
+		return client, nil
+	})
+	defer agent.Close()
+	if err := StartMachineWithAgent(m, nil, agent); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{MachineEventCreated, MachineEventAgentReady}; !reflect.DeepEqual(m.events, expected) {
+		t.Errorf("expected events %v, got %v", expected, m.events)
+	}
+
+	agent = newAgent(func() (net.Conn, error) { return nil, errors.New("no agent") })
+	if err := StartMachineWithAgent(&agentMachine{}, nil, agent); err == nil || !strings.Contains(err.Error(), "no agent") {
+		t.Errorf("expected the agent error, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// maxFaultAfter bounds DiskFault.After, since counting requests takes a
+// blkdebug rule per request
+const maxFaultAfter = 10000
+
+// DiskFault describes I/O errors injected into a disk with QEMU's blkdebug
+// driver. Disks with faults are raw images without a backing file, so that
+// sectors of the image are sectors of the disk as seen by the guest.
+type DiskFault struct {
+	// IOType is the kind of requests which fail: "read", "write" or "" for
+	// both
+	IOType string
+	// After is the number of requests of IOType which succeed before
+	// requests start failing
+	After int
+	// Sectors, if not empty, restricts the errors to requests touching
+	// one of these 512-byte sectors
+	Sectors []int64
+	// Trigger delays the errors until QemuInstance.TriggerDiskFault is
+	// called; After then counts from the trigger
+	Trigger bool
+}
+
+// blkdebugInjectError and blkdebugSetState are the rules of a blkdebug node
+// as in QEMU's BlockdevOptionsBlkdebug
+type blkdebugInjectError struct {
+	Event  string `json:"event"`
+	State  int    `json:"state"`
+	IOType string `json:"iotype"`
+	Errno  int    `json:"errno"`
+	Sector *int64 `json:"sector,omitempty"`
+	Once   bool   `json:"once"`
+}
+
+type blkdebugSetState struct {
+	Event    string `json:"event"`
+	State    int    `json:"state"`
+	NewState int    `json:"new_state"`
+}
+
+type blkdebugOptions struct {
+	Driver      string                `json:"driver"`
+	NodeName    string                `json:"node-name,omitempty"`
+	Image       interface{}           `json:"image"`
+	InjectError []blkdebugInjectError `json:"inject-error,omitempty"`
+	SetState    []blkdebugSetState    `json:"set-state,omitempty"`
+}
+
+// parseDiskFault sets the fault injection option key of a disk spec.
+func parseDiskFault(fault *DiskFault, key, value string) error {
+	switch key {
+	case "fail":
+		switch value {
+		case "read", "write":
+			fault.IOType = value
+		case "all":
+			fault.IOType = ""
+		default:
+			return fmt.Errorf("invalid value %q for fail; must be read, write or all", value)
+		}
+	case "fail-after":
+		after, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for fail-after. Must be an integer", value)
+		}
+		fault.After = after
+	case "fail-sector":
+		sector, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for fail-sector. Must be an integer", value)
+		}
+		fault.Sectors = append(fault.Sectors, sector)
+	case "fail-trigger":
+		fault.Trigger = true
+	default:
+		return fmt.Errorf("invalid key %q", key)
+	}
+	return nil
+}
+
+func (f *DiskFault) validate() error {
+	switch f.IOType {
+	case "", "read", "write":
+	default:
+		return fmt.Errorf("invalid fault I/O type %q", f.IOType)
+	}
+	if f.After < 0 || f.After > maxFaultAfter {
+		return fmt.Errorf("invalid fault after %d requests; must be between 0 and %d", f.After, maxFaultAfter)
+	}
+	for _, sector := range f.Sectors {
+		if sector < 0 {
+			return fmt.Errorf("invalid negative fault sector %d", sector)
+		}
+	}
+	return nil
+}
+
+// blkdebugOptions returns the options of a blkdebug node injecting the
+// fault on top of image, which is either the name of a node or its options.
+// blkdebug only knows the events the raw format driver sends it before each
+// request, so requests are counted by stepping through a state per request.
+func (f *DiskFault) blkdebugOptions(nodeName string, image interface{}) blkdebugOptions {
+	opts := blkdebugOptions{
+		Driver:   "blkdebug",
+		NodeName: nodeName,
+		Image:    image,
+	}
+	iotypes := []string{"read", "write"}
+	if f.IOType != "" {
+		iotypes = []string{f.IOType}
+	}
+	for _, iotype := range iotypes {
+		event := iotype + "_aio"
+		for state := 1; state <= f.After; state++ {
+			opts.SetState = append(opts.SetState, blkdebugSetState{Event: event, State: state, NewState: state + 1})
+		}
+		rule := blkdebugInjectError{
+			Event:  event,
+			State:  f.After + 1,
+			IOType: iotype,
+			Errno:  int(syscall.EIO),
+		}
+		if len(f.Sectors) == 0 {
+			opts.InjectError = append(opts.InjectError, rule)
+		}
+		for _, sector := range f.Sectors {
+			sector := sector
+			rule.Sector = &sector
+			opts.InjectError = append(opts.InjectError, rule)
+		}
+	}
+	return opts
+}
+
+// driveFile returns the file option of -drive for a disk with a fault, as a
+// json: pseudo-protocol filename with its commas escaped for the command
+// line.
+func (f *DiskFault) driveFile(filename string) (string, error) {
+	image := map[string]string{"driver": "file", "filename": filename}
+	buf, err := json.Marshal(f.blkdebugOptions("", image))
+	if err != nil {
+		return "", err
+	}
+	return "json:" + strings.ReplaceAll(string(buf), ",", ",,"), nil
+}
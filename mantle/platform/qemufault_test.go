@@ -0,0 +1,96 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDiskFault(t *testing.T) {
+	disk, err := ParseDisk("5G:serial=bad,fail=read,fail-after=2,fail-sector=2048,fail-trigger,iops=100", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &DiskFault{IOType: "read", After: 2, Sectors: []int64{2048}, Trigger: true}
+	if !reflect.DeepEqual(disk.Fault, expected) {
+		t.Errorf("expected fault %+v, got %+v", expected, disk.Fault)
+	}
+	if disk.ThrottleIops != 100 || disk.ThrottleBps != 0 {
+		t.Errorf("expected throttle of 100 iops, got %d iops and %d bps", disk.ThrottleIops, disk.ThrottleBps)
+	}
+
+	disk, err = ParseDisk("5G:serial=good", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disk.Fault != nil {
+		t.Errorf("expected no fault, got %+v", disk.Fault)
+	}
+
+	for _, spec := range []string{"5G:fail=sometimes", "5G:fail-after=x", "5G:fail-sector=", "5G:iops=0", "5G:bps=-1"} {
+		if _, err := ParseDisk(spec, false); err == nil {
+			t.Errorf("expected an error parsing %q", spec)
+		}
+	}
+}
+
+func TestDiskFaultBlkdebugOptions(t *testing.T) {
+	sector := int64(8)
+	opts := (&DiskFault{IOType: "write", After: 2, Sectors: []int64{sector}}).blkdebugOptions("node", "image")
+	expected := blkdebugOptions{
+		Driver:   "blkdebug",
+		NodeName: "node",
+		Image:    "image",
+		InjectError: []blkdebugInjectError{
+			{Event: "write_aio", State: 3, IOType: "write", Errno: 5, Sector: &sector},
+		},
+		SetState: []blkdebugSetState{
+			{Event: "write_aio", State: 1, NewState: 2},
+			{Event: "write_aio", State: 2, NewState: 3},
+		},
+	}
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, opts)
+	}
+
+	opts = (&DiskFault{}).blkdebugOptions("", "image")
+	if len(opts.InjectError) != 2 || len(opts.SetState) != 0 {
+		t.Fatalf("expected a rule for each of reads and writes, got %+v", opts)
+	}
+	for _, rule := range opts.InjectError {
+		if rule.State != 1 || rule.Sector != nil {
+			t.Errorf("expected rule %+v to fail all requests from the start", rule)
+		}
+	}
+}
+
+func TestDiskFaultDriveFile(t *testing.T) {
+	file, err := (&DiskFault{IOType: "read"}).driveFile("/dev/fdset/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(file, "json:{") {
+		t.Errorf("expected a json: filename, got %s", file)
+	}
+	// every comma of the JSON must be doubled for the command line
+	if strings.Contains(strings.ReplaceAll(file, ",,", ""), ",") {
+		t.Errorf("unescaped comma in %s", file)
+	}
+	if !strings.Contains(file, `"image":{"driver":"file",,"filename":"/dev/fdset/3"}`) {
+		t.Errorf("expected the file as image in %s", file)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/system/exec"
+	"github.com/pkg/errors"
+)
+
+// unplugTimeout is how long the guest has to release an unplugged device
+const unplugTimeout = 60 * time.Second
+
+// hotplugNameRe restricts the names of hotplugged devices to valid QEMU ids
+var hotplugNameRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*$`)
+
+// hotplugDevice is a disk or NIC added to a running instance.
+type hotplugDevice struct {
+	// devices are the ids of the QEMU devices, in the order they were added
+	devices []string
+	// ports are the root ports the devices use
+	ports []string
+	// node is the block node of a disk
+	node string
+	// netdev is the network backend of a NIC
+	netdev string
+	// file is the image of a disk
+	file string
+}
+
+// hotplugDeviceArgs are the arguments of device_add
+type hotplugDeviceArgs map[string]interface{}
+
+// hotplugDiskDevices returns the devices to add for a hotplugged disk: the
+// disk itself and, for SCSI, its controller first. bus returns the bus of
+// each PCI device.
+func hotplugDiskDevices(arch string, disk *Disk, serial, node string, bus func() (string, error)) ([]hotplugDeviceArgs, error) {
+	pci := func(args hotplugDeviceArgs) (hotplugDeviceArgs, error) {
+		b, err := bus()
+		if err != nil {
+			return nil, err
+		}
+		if b != "" {
+			args["bus"] = b
+		}
+		return args, nil
+	}
+	virtioSuffix := "pci"
+	if arch == "s390x" {
+		virtioSuffix = "ccw"
+		pci = func(args hotplugDeviceArgs) (hotplugDeviceArgs, error) { return args, nil }
+	}
+
+	id := "hotplug-" + serial
+	dev := hotplugDeviceArgs{"id": id, "drive": node, "serial": serial}
+	if disk.SectorSize != 0 {
+		logical := disk.LogicalSectorSize
+		if logical == 0 {
+			logical = disk.SectorSize
+		}
+		dev["physical_block_size"] = disk.SectorSize
+		dev["logical_block_size"] = logical
+	}
+
+	switch disk.Channel {
+	case "", "virtio":
+		dev["driver"] = "virtio-blk-" + virtioSuffix
+		dev, err := pci(dev)
+		if err != nil {
+			return nil, err
+		}
+		return []hotplugDeviceArgs{dev}, nil
+	case "nvme":
+		dev["driver"] = "nvme"
+		dev, err := pci(dev)
+		if err != nil {
+			return nil, err
+		}
+		return []hotplugDeviceArgs{dev}, nil
+	case "scsi":
+		controller, err := pci(hotplugDeviceArgs{"driver": "virtio-scsi-" + virtioSuffix, "id": id + "-scsi"})
+		if err != nil {
+			return nil, err
+		}
+		dev["driver"] = "scsi-hd"
+		dev["bus"] = id + "-scsi.0"
+		wwn := disk.Wwn
+		if wwn == 0 {
+			wwn = rand.Uint64()
+		}
+		dev["wwn"] = wwn
+		return []hotplugDeviceArgs{controller, dev}, nil
+	default:
+		return nil, fmt.Errorf("unhandled channel %q", disk.Channel)
+	}
+}
+
+// hotplugDiskSerial returns the serial of a hotplugged disk, given with
+// the serial option or else hotplugN for the nth hotplugged disk. Other
+// options can't be passed to device_add and blockdev-add as they are, so
+// they are rejected.
+func hotplugDiskSerial(disk *Disk, n int) (string, error) {
+	if len(disk.DriveOpts) > 0 {
+		return "", fmt.Errorf("drive options %v are not supported for hotplugged disks", disk.DriveOpts)
+	}
+	serial := fmt.Sprintf("hotplug%d", n)
+	for _, opt := range disk.DeviceOpts {
+		if !strings.HasPrefix(opt, "serial=") {
+			return "", fmt.Errorf("device option %q is not supported for hotplugged disks", opt)
+		}
+		serial = strings.TrimPrefix(opt, "serial=")
+	}
+	return serial, nil
+}
+
+// HotplugDisk adds a disk described by spec, as parsed by ParseDisk, to the
+// running instance. The disk is named after its serial, which defaults to
+// hotplugN. On machines with PCIe, each virtio or NVMe disk takes one of the
+// QemuBuilder.HotplugSlots, and a SCSI disk takes one for its controller.
+func (inst *QemuInstance) HotplugDisk(spec string) error {
+	disk, err := ParseDisk(spec, false)
+	if err != nil {
+		return err
+	}
+	if disk.MultiPathDisk || disk.Fault != nil || disk.ThrottleIops != 0 || disk.ThrottleBps != 0 {
+		return fmt.Errorf("disk spec %q: multipath, faults and throttling are not supported for hotplugged disks", spec)
+	}
+	serial, err := hotplugDiskSerial(disk, inst.hotplugCount)
+	if err != nil {
+		return errors.Wrapf(err, "disk spec %q", spec)
+	}
+	inst.hotplugCount++
+	if err := inst.checkHotplugName(serial); err != nil {
+		return err
+	}
+
+	file := filepath.Join(inst.tempdir, "hotplug-"+serial+".qcow2")
+	qemuImg := exec.Command("qemu-img", "create", "-f", "qcow2", "-o", "nocow=on", file, disk.Size)
+	qemuImg.Stderr = os.Stderr
+	if err := qemuImg.Run(); err != nil {
+		return errors.Wrapf(err, "creating disk %s", serial)
+	}
+
+	hd := &hotplugDevice{node: "hotplug-" + serial, file: file}
+	if err := inst.addBlockdev(hd.node, file); err != nil {
+		os.Remove(file)
+		return err
+	}
+	devices, err := hotplugDiskDevices(inst.architecture, disk, serial, hd.node, func() (string, error) {
+		port, err := inst.takeHotplugPort()
+		if err == nil && port != "" {
+			hd.ports = append(hd.ports, port)
+		}
+		return port, err
+	})
+	if err == nil {
+		for _, dev := range devices {
+			if err = inst.addDevice(dev); err != nil {
+				break
+			}
+			hd.devices = append(hd.devices, dev["id"].(string))
+		}
+	}
+	if err != nil {
+		if uerr := inst.unplug(hd); uerr != nil {
+			plog.Errorf("Cleaning up disk %s: %v", serial, uerr)
+		}
+		return err
+	}
+	inst.hotplugged[serial] = hd
+	return nil
+}
+
+// UnplugDisk removes a disk added with HotplugDisk given its serial, and
+// waits for the guest to release it.
+func (inst *QemuInstance) UnplugDisk(serial string) error {
+	hd, ok := inst.hotplugged[serial]
+	if !ok || hd.node == "" {
+		return fmt.Errorf("no hotplugged disk %q", serial)
+	}
+	delete(inst.hotplugged, serial)
+	return inst.unplug(hd)
+}
+
+// HotplugNIC adds a virtio-net NIC with its own user mode network to the
+// running instance, like the additional NICs of QemuBuilder. If mac is
+// empty, a locally administered address is picked. On machines with PCIe,
+// the NIC takes one of the QemuBuilder.HotplugSlots.
+func (inst *QemuInstance) HotplugNIC(name, mac string) error {
+	if err := inst.checkHotplugName(name); err != nil {
+		return err
+	}
+	if mac == "" {
+		mac = fmt.Sprintf("52:55:00:d2:%02x:%02x", rand.Intn(256), rand.Intn(256))
+	}
+	hd := &hotplugDevice{netdev: name}
+	if err := inst.addUserNetdev(name, inst.restrictNetworking); err != nil {
+		return err
+	}
+	dev := hotplugDeviceArgs{"id": name, "netdev": name, "mac": mac}
+	var err error
+	if inst.architecture == "s390x" {
+		dev["driver"] = "virtio-net-ccw"
+	} else {
+		dev["driver"] = "virtio-net-pci"
+		var port string
+		if port, err = inst.takeHotplugPort(); err == nil && port != "" {
+			dev["bus"] = port
+			hd.ports = append(hd.ports, port)
+		}
+	}
+	if err == nil {
+		if err = inst.addDevice(dev); err == nil {
+			hd.devices = append(hd.devices, name)
+		}
+	}
+	if err != nil {
+		if uerr := inst.unplug(hd); uerr != nil {
+			plog.Errorf("Cleaning up NIC %s: %v", name, uerr)
+		}
+		return err
+	}
+	inst.hotplugged[name] = hd
+	return nil
+}
+
+// UnplugNIC removes a NIC added with HotplugNIC, and waits for the guest
+// to release it.
+func (inst *QemuInstance) UnplugNIC(name string) error {
+	hd, ok := inst.hotplugged[name]
+	if !ok || hd.netdev == "" {
+		return fmt.Errorf("no hotplugged NIC %q", name)
+	}
+	delete(inst.hotplugged, name)
+	return inst.unplug(hd)
+}
+
+func (inst *QemuInstance) checkHotplugName(name string) error {
+	if !hotplugNameRe.MatchString(name) {
+		return fmt.Errorf("invalid name %q for a hotplugged device", name)
+	}
+	if inst.hotplugged == nil {
+		inst.hotplugged = make(map[string]*hotplugDevice)
+	}
+	if _, ok := inst.hotplugged[name]; ok {
+		return fmt.Errorf("device %q is already hotplugged", name)
+	}
+	return nil
+}
+
+// takeHotplugPort returns a free root port for a PCI device, or "" if the
+// machine doesn't need one.
+func (inst *QemuInstance) takeHotplugPort() (string, error) {
+	if !inst.hotplugPCIe {
+		return "", nil
+	}
+	if len(inst.hotplugPorts) == 0 {
+		return "", errors.New("no free hotplug slot; increase HotplugSlots")
+	}
+	port := inst.hotplugPorts[0]
+	inst.hotplugPorts = inst.hotplugPorts[1:]
+	return port, nil
+}
+
+// unplug removes the devices of hd in reverse order, then their backends.
+func (inst *QemuInstance) unplug(hd *hotplugDevice) error {
+	for i := len(hd.devices) - 1; i >= 0; i-- {
+		if err := inst.deleteDeviceAndWait(hd.devices[i], unplugTimeout); err != nil {
+			return err
+		}
+	}
+	inst.hotplugPorts = append(inst.hotplugPorts, hd.ports...)
+	if hd.node != "" {
+		if err := inst.deleteBlockdev(hd.node); err != nil {
+			return err
+		}
+	}
+	if hd.netdev != "" {
+		if err := inst.deleteNetdev(hd.netdev); err != nil {
+			return err
+		}
+	}
+	if hd.file != "" {
+		return os.Remove(hd.file)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHotplugDiskDevices(t *testing.T) {
+	ports := []string{"port0", "port1"}
+	bus := func() (string, error) {
+		if len(ports) == 0 {
+			return "", errors.New("no free port")
+		}
+		port := ports[0]
+		ports = ports[1:]
+		return port, nil
+	}
+
+	devices, err := hotplugDiskDevices("x86_64", &Disk{Channel: "nvme", SectorSize: 4096}, "data", "node", bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []hotplugDeviceArgs{{
+		"driver":              "nvme",
+		"id":                  "hotplug-data",
+		"drive":               "node",
+		"serial":              "data",
+		"bus":                 "port0",
+		"physical_block_size": 4096,
+		"logical_block_size":  4096,
+	}}
+	if !reflect.DeepEqual(devices, expected) {
+		t.Errorf("expected %v, got %v", expected, devices)
+	}
+
+	// the SCSI controller takes the port, not the disk
+	devices, err = hotplugDiskDevices("x86_64", &Disk{Channel: "scsi", Wwn: 11}, "data", "node", bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 || devices[0]["driver"] != "virtio-scsi-pci" || devices[0]["bus"] != "port1" {
+		t.Fatalf("expected a SCSI controller on port1 first, got %v", devices)
+	}
+	if devices[1]["bus"] != "hotplug-data-scsi.0" || devices[1]["wwn"] != uint64(11) {
+		t.Errorf("expected the disk on the controller, got %v", devices[1])
+	}
+
+	if _, err := hotplugDiskDevices("x86_64", &Disk{}, "data", "node", bus); err == nil {
+		t.Error("expected an error without free ports")
+	}
+
+	// s390x doesn't need ports
+	devices, err = hotplugDiskDevices("s390x", &Disk{}, "data", "node", bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if devices[0]["driver"] != "virtio-blk-ccw" {
+		t.Errorf("expected a virtio-blk-ccw device, got %v", devices[0])
+	}
+	if _, ok := devices[0]["bus"]; ok {
+		t.Errorf("expected no bus, got %v", devices[0])
+	}
+}
+
+func TestHotplugDiskSerial(t *testing.T) {
+	tests := []struct {
+		disk   Disk
+		serial string
+		ok     bool
+	}{
+		{Disk{}, "hotplug3", true},
+		{Disk{DeviceOpts: []string{"serial=data"}}, "data", true},
+		{Disk{DeviceOpts: []string{"bootindex=1"}}, "", false},
+		{Disk{DeviceOpts: []string{"serial=data", "bootindex=1"}}, "", false},
+		{Disk{DriveOpts: []string{"cache=none"}}, "", false},
+	}
+	for _, test := range tests {
+		serial, err := hotplugDiskSerial(&test.disk, 3)
+		if (err == nil) != test.ok {
+			t.Errorf("%+v: expected ok %v, got %v", test.disk, test.ok, err)
+		} else if serial != test.serial {
+			t.Errorf("%+v: expected serial %q, got %q", test.disk, test.serial, serial)
+		}
+	}
+}
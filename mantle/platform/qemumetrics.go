@@ -0,0 +1,140 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// userHZ is the unit of the CPU times in /proc/<pid>/stat, which is 100 on
+// all the architectures we run on
+const userHZ = 100
+
+// QemuResourceUsage is the resource consumption of a QEMU instance since
+// it started. The host side is read from /proc/<pid> of the QEMU process,
+// the guest side is queried over QMP.
+type QemuResourceUsage struct {
+	// MemoryMiB is the memory given to the guest
+	MemoryMiB int `json:"memory_mib"`
+	// PeakRSSBytes is the high water mark of the QEMU process' resident
+	// memory, which includes the guest memory it touched
+	PeakRSSBytes uint64 `json:"peak_rss_bytes"`
+	// CPUSeconds is the user and system CPU time used by QEMU
+	CPUSeconds float64 `json:"cpu_seconds"`
+	// ReadBytes and WriteBytes are the bytes QEMU caused to be read from
+	// and written to storage
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+	// GuestStats are the numeric statistics of the VM kept by the
+	// accelerator, from QMP query-stats, keyed by provider and name
+	// (e.g. kvm.pages_4k). They are missing if QEMU or the accelerator
+	// doesn't support query-stats.
+	GuestStats map[string]int64 `json:"guest_stats,omitempty"`
+}
+
+// ResourceUsage returns the resource consumption of the instance so far.
+// It must be called while QEMU is still running.
+func (inst *QemuInstance) ResourceUsage() (QemuResourceUsage, error) {
+	usage := QemuResourceUsage{MemoryMiB: inst.memoryMiB}
+	procDir := fmt.Sprintf("/proc/%d", inst.Pid())
+
+	status, err := readProcKeyValues(procDir + "/status")
+	if err != nil {
+		return usage, err
+	}
+	// e.g. "VmHWM:	  123456 kB"
+	if hwm, ok := status["VmHWM"]; ok {
+		kb, err := strconv.ParseUint(strings.TrimSuffix(hwm, " kB"), 10, 64)
+		if err != nil {
+			return usage, errors.Wrapf(err, "parsing VmHWM")
+		}
+		usage.PeakRSSBytes = kb * 1024
+	}
+
+	stat, err := os.ReadFile(procDir + "/stat")
+	if err != nil {
+		return usage, err
+	}
+	// The command name may contain spaces, so skip past it. The remaining
+	// fields start at the third one, the state.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	if len(fields) < 13 {
+		return usage, fmt.Errorf("parsing %s/stat: too few fields", procDir)
+	}
+	for _, field := range fields[11:13] { // utime and stime
+		ticks, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return usage, errors.Wrapf(err, "parsing %s/stat", procDir)
+		}
+		usage.CPUSeconds += float64(ticks) / userHZ
+	}
+
+	ioStats, err := readProcKeyValues(procDir + "/io")
+	if err != nil {
+		return usage, err
+	}
+	if usage.ReadBytes, err = strconv.ParseUint(ioStats["read_bytes"], 10, 64); err != nil {
+		return usage, errors.Wrapf(err, "parsing read_bytes")
+	}
+	if usage.WriteBytes, err = strconv.ParseUint(ioStats["write_bytes"], 10, 64); err != nil {
+		return usage, errors.Wrapf(err, "parsing write_bytes")
+	}
+
+	if stats, err := inst.queryVMStats(); err != nil {
+		plog.Debugf("Failed to query VM stats of qemu (%v): %v", inst.Pid(), err)
+	} else {
+		for _, provider := range stats.Return {
+			for _, stat := range provider.Stats {
+				// skip histograms and booleans
+				var value int64
+				if json.Unmarshal(stat.Value, &value) != nil {
+					continue
+				}
+				if usage.GuestStats == nil {
+					usage.GuestStats = make(map[string]int64)
+				}
+				usage.GuestStats[provider.Provider+"."+stat.Name] = value
+			}
+		}
+	}
+	return usage, nil
+}
+
+// readProcKeyValues parses a /proc file made of "key: value" lines.
+func readProcKeyValues(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), ":"); ok {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "reading %s", path)
+	}
+	return values, nil
+}
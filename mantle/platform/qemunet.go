@@ -0,0 +1,229 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxFrameSize is large enough for any frame QEMU sends over a socket netdev
+const maxFrameSize = 65536
+
+// NetworkImpairment describes how the traffic of a QEMU instance on a
+// private network is degraded. It applies to frames both sent and received
+// by the instance. The zero value means no impairment.
+type NetworkImpairment struct {
+	// Loss is the fraction of frames dropped, between 0 and 1
+	Loss float64
+	// Delay is added to every frame
+	Delay time.Duration
+	// Jitter is the maximum random delay added on top of Delay. Frames
+	// may be reordered.
+	Jitter time.Duration
+}
+
+func (n NetworkImpairment) validate() error {
+	if n.Loss < 0 || n.Loss > 1 {
+		return fmt.Errorf("invalid packet loss %v; must be between 0 and 1", n.Loss)
+	}
+	if n.Delay < 0 || n.Jitter < 0 {
+		return fmt.Errorf("invalid negative delay")
+	}
+	return nil
+}
+
+// VirtualSwitch is an Ethernet hub in userspace connecting QEMU instances
+// through UDP socket netdevs on the loopback interface. Since it handles
+// every frame, it can drop, delay and partition the traffic of each port
+// without needing privileges on the host.
+type VirtualSwitch struct {
+	mu          sync.Mutex
+	ports       map[*SwitchPort]bool
+	partitioned map[[2]*SwitchPort]bool
+	closed      bool
+}
+
+// SwitchPort is the connection of one QEMU instance to a VirtualSwitch.
+type SwitchPort struct {
+	sw         *VirtualSwitch
+	conn       *net.UDPConn // switch side
+	guest      *net.UDPAddr // QEMU side
+	impairment NetworkImpairment
+}
+
+// NewVirtualSwitch creates a switch without any ports.
+func NewVirtualSwitch() *VirtualSwitch {
+	return &VirtualSwitch{
+		ports:       make(map[*SwitchPort]bool),
+		partitioned: make(map[[2]*SwitchPort]bool),
+	}
+}
+
+// AddPort creates a new port and starts forwarding the frames sent to it.
+func (sw *VirtualSwitch) AddPort() (*SwitchPort, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+	// Possible race condition between getting the port here and QEMU
+	// binding it -- same trade-off as for the host forwarded ports
+	l, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	guest := l.LocalAddr().(*net.UDPAddr)
+	l.Close()
+
+	p := &SwitchPort{
+		sw:    sw,
+		conn:  conn,
+		guest: guest,
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed {
+		conn.Close()
+		return nil, fmt.Errorf("virtual switch is closed")
+	}
+	sw.ports[p] = true
+	go p.forward()
+	return p, nil
+}
+
+// Close disconnects all ports.
+func (sw *VirtualSwitch) Close() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.closed = true
+	for p := range sw.ports {
+		p.conn.Close()
+	}
+	sw.ports = make(map[*SwitchPort]bool)
+}
+
+// SetPartitioned drops or restores all traffic between two ports.
+func (sw *VirtualSwitch) SetPartitioned(a, b *SwitchPort, partitioned bool) error {
+	if a.sw != sw || b.sw != sw {
+		return fmt.Errorf("ports are not on the same switch")
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	for _, key := range [][2]*SwitchPort{{a, b}, {b, a}} {
+		if partitioned {
+			sw.partitioned[key] = true
+		} else {
+			delete(sw.partitioned, key)
+		}
+	}
+	return nil
+}
+
+// NetdevArg returns the -netdev argument connecting QEMU to the port.
+func (p *SwitchPort) NetdevArg(id string) string {
+	return fmt.Sprintf("socket,id=%s,udp=%s,localaddr=%s", id, p.conn.LocalAddr(), p.guest)
+}
+
+// SetImpairment replaces the impairment of the port's traffic.
+func (p *SwitchPort) SetImpairment(n NetworkImpairment) error {
+	if err := n.validate(); err != nil {
+		return err
+	}
+	p.sw.mu.Lock()
+	defer p.sw.mu.Unlock()
+	p.impairment = n
+	return nil
+}
+
+// Close disconnects the port from the switch.
+func (p *SwitchPort) Close() {
+	p.sw.mu.Lock()
+	defer p.sw.mu.Unlock()
+	delete(p.sw.ports, p)
+	for key := range p.sw.partitioned {
+		if key[0] == p || key[1] == p {
+			delete(p.sw.partitioned, key)
+		}
+	}
+	p.conn.Close()
+}
+
+// forward reads the frames sent by QEMU and sends them to all other ports
+// until the port is closed.
+func (p *SwitchPort) forward() {
+	buf := make([]byte, maxFrameSize)
+	for {
+		n, addr, err := p.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !addr.IP.Equal(p.guest.IP) || addr.Port != p.guest.Port {
+			continue
+		}
+
+		// decide under the lock, but send without holding it
+		type delivery struct {
+			dst   *SwitchPort
+			delay time.Duration
+		}
+		var deliveries []delivery
+		p.sw.mu.Lock()
+		for dst := range p.sw.ports {
+			if dst == p || p.sw.partitioned[[2]*SwitchPort{p, dst}] {
+				continue
+			}
+			delay, drop := combineImpairments(p.impairment, dst.impairment)
+			if !drop {
+				deliveries = append(deliveries, delivery{dst, delay})
+			}
+		}
+		p.sw.mu.Unlock()
+
+		for _, d := range deliveries {
+			frame := append([]byte(nil), buf[:n]...)
+			if d.delay == 0 {
+				d.dst.send(frame)
+			} else {
+				dst := d.dst
+				time.AfterFunc(d.delay, func() { dst.send(frame) })
+			}
+		}
+	}
+}
+
+func (p *SwitchPort) send(frame []byte) {
+	// errors mean the port or QEMU went away, which is fine
+	p.conn.WriteToUDP(frame, p.guest) //nolint // Ignore errors
+}
+
+// combineImpairments returns the delay for a frame from a port with
+// impairment src to a port with impairment dst, or whether to drop it.
+func combineImpairments(src, dst NetworkImpairment) (time.Duration, bool) {
+	var delay time.Duration
+	for _, n := range []NetworkImpairment{src, dst} {
+		if n.Loss > 0 && rand.Float64() < n.Loss {
+			return 0, true
+		}
+		delay += n.Delay
+		if n.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(n.Jitter) + 1))
+		}
+	}
+	return delay, false
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeGuest plays the QEMU side of a switch port
+type fakeGuest struct {
+	port *SwitchPort
+	conn *net.UDPConn
+}
+
+func newFakeGuest(t *testing.T, sw *VirtualSwitch) *fakeGuest {
+	port, err := sw.AddPort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp4", port.guest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &fakeGuest{port: port, conn: conn}
+}
+
+func (g *fakeGuest) send(t *testing.T, frame string) {
+	if _, err := g.conn.WriteToUDP([]byte(frame), g.port.conn.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receive returns the next frame, or "" if none arrives in time.
+func (g *fakeGuest) receive(t *testing.T, timeout time.Duration) string {
+	buf := make([]byte, maxFrameSize)
+	if err := g.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := g.conn.Read(buf)
+	if err != nil {
+		return ""
+	}
+	return string(buf[:n])
+}
+
+func TestVirtualSwitch(t *testing.T) {
+	sw := NewVirtualSwitch()
+	defer sw.Close()
+	a := newFakeGuest(t, sw)
+	b := newFakeGuest(t, sw)
+	c := newFakeGuest(t, sw)
+
+	a.send(t, "hello")
+	for _, g := range []*fakeGuest{b, c} {
+		if got := g.receive(t, time.Second); got != "hello" {
+			t.Fatalf("expected frame, got %q", got)
+		}
+	}
+	if got := a.receive(t, 100*time.Millisecond); got != "" {
+		t.Fatalf("frame echoed to sender: %q", got)
+	}
+
+	if err := sw.SetPartitioned(a.port, b.port, true); err != nil {
+		t.Fatal(err)
+	}
+	a.send(t, "partitioned")
+	if got := b.receive(t, 100*time.Millisecond); got != "" {
+		t.Fatalf("frame crossed partition: %q", got)
+	}
+	if got := c.receive(t, time.Second); got != "partitioned" {
+		t.Fatalf("expected frame, got %q", got)
+	}
+	if err := sw.SetPartitioned(b.port, a.port, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.port.SetImpairment(NetworkImpairment{Loss: 1}); err != nil {
+		t.Fatal(err)
+	}
+	a.send(t, "lossy")
+	if got := b.receive(t, time.Second); got != "lossy" {
+		t.Fatalf("expected frame, got %q", got)
+	}
+	if got := c.receive(t, 100*time.Millisecond); got != "" {
+		t.Fatalf("frame not dropped: %q", got)
+	}
+
+	delay := 200 * time.Millisecond
+	if err := c.port.SetImpairment(NetworkImpairment{Delay: delay}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	a.send(t, "slow")
+	if got := b.receive(t, time.Second); got != "slow" {
+		t.Fatalf("expected frame, got %q", got)
+	}
+	if got := c.receive(t, time.Second); got != "slow" {
+		t.Fatalf("expected frame, got %q", got)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("frame delayed by %v, expected at least %v", elapsed, delay)
+	}
+
+	if err := c.port.SetImpairment(NetworkImpairment{Loss: 2}); err == nil {
+		t.Fatalf("invalid impairment accepted")
+	}
+
+	c.port.Close()
+	a.send(t, "closed")
+	if got := b.receive(t, time.Second); got != "closed" {
+		t.Fatalf("expected frame, got %q", got)
+	}
+}
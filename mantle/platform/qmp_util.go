@@ -19,6 +19,8 @@ package platform
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -99,3 +101,228 @@ func (inst *QemuInstance) deleteBlockDevice(device string) error {
 	}
 	return nil
 }
+
+// setLink uses the qmp socket to set the link state of a network device.
+func (inst *QemuInstance) setLink(name string, up bool) error {
+	cmd := fmt.Sprintf(`{ "execute": "set_link", "arguments": { "name":"%s", "up":%t } }`, name, up)
+	if _, err := inst.runQmpCommand(cmd); err != nil {
+		return errors.Wrapf(err, "Setting link of %s to %t", name, up)
+	}
+	return nil
+}
+
+// screendump uses the qmp socket to save the graphical console as PNG.
+func (inst *QemuInstance) screendump(path string) error {
+	args, err := json.Marshal(map[string]string{
+		"filename": path,
+		"format":   "png",
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := inst.runQmpCommand(fmt.Sprintf(`{ "execute": "screendump", "arguments": %s }`, args)); err != nil {
+		return errors.Wrapf(err, "Saving screendump to %s", path)
+	}
+	return nil
+}
+
+// dumpGuestMemory uses the qmp socket to write the guest memory to an ELF
+// file. The command only returns once the dump is complete.
+func (inst *QemuInstance) dumpGuestMemory(path string) error {
+	args, err := json.Marshal(map[string]interface{}{
+		"paging":   false,
+		"protocol": "file:" + path,
+		"format":   "elf",
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := inst.runQmpCommand(fmt.Sprintf(`{ "execute": "dump-guest-memory", "arguments": %s }`, args)); err != nil {
+		return errors.Wrapf(err, "Dumping guest memory to %s", path)
+	}
+	return nil
+}
+
+// QOMStats is the output of query-stats.
+type QOMStats struct {
+	Return []struct {
+		Provider string `json:"provider"`
+		Target   string `json:"target"`
+		Stats    []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"stats"`
+	} `json:"return"`
+}
+
+// queryVMStats uses the qmp socket to query the statistics of the VM
+// kept by the accelerator, e.g. the guest memory mapped by KVM.
+func (inst *QemuInstance) queryVMStats() (*QOMStats, error) {
+	out, err := inst.runQmpCommand(`{ "execute": "query-stats", "arguments": { "target": "vm" } }`)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Running QMP query-stats command")
+	}
+	var stats QOMStats
+	if err = json.Unmarshal(out, &stats); err != nil {
+		return nil, errors.Wrapf(err, "De-serializing QMP query-stats output")
+	}
+	return &stats, nil
+}
+
+// runHmpCommand executes a human monitor command through the QMP socket
+// and returns its textual output. HMP reports failures in that output
+// rather than as a QMP error, so they are converted here.
+func (inst *QemuInstance) runHmpCommand(cmdline string) (string, error) {
+	quoted, err := json.Marshal(cmdline)
+	if err != nil {
+		return "", err
+	}
+	cmd := fmt.Sprintf(`{ "execute": "human-monitor-command", "arguments": { "command-line": %s } }`, quoted)
+	out, err := inst.runQmpCommand(cmd)
+	if err != nil {
+		return "", errors.Wrapf(err, "Running HMP command %q", cmdline)
+	}
+	var resp struct {
+		Return string `json:"return"`
+	}
+	if err = json.Unmarshal(out, &resp); err != nil {
+		return "", errors.Wrapf(err, "De-serializing HMP %q output", cmdline)
+	}
+	output := strings.TrimSpace(resp.Return)
+	if strings.HasPrefix(output, "Error") {
+		return "", fmt.Errorf("HMP command %q failed: %s", cmdline, output)
+	}
+	return output, nil
+}
+
+// saveVM uses the qmp socket to save the VM state into an internal snapshot.
+func (inst *QemuInstance) saveVM(name string) error {
+	if _, err := inst.runHmpCommand("savevm " + name); err != nil {
+		return errors.Wrapf(err, "Saving snapshot %s", name)
+	}
+	return nil
+}
+
+// loadVM uses the qmp socket to restore the VM state from an internal snapshot.
+func (inst *QemuInstance) loadVM(name string) error {
+	if _, err := inst.runHmpCommand("loadvm " + name); err != nil {
+		return errors.Wrapf(err, "Loading snapshot %s", name)
+	}
+	return nil
+}
+
+// insertBlkdebug uses the qmp socket to put a blkdebug node injecting fault
+// between the format and the file nodes of a drive. The format node is
+// reopened through qemu-io rather than blockdev-reopen since it keeps the
+// options not given.
+func (inst *QemuInstance) insertBlkdebug(drive string, fault *DiskFault) error {
+	opts, err := json.Marshal(fault.blkdebugOptions(drive+"-fault", drive+"-file"))
+	if err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf(`{ "execute": "blockdev-add", "arguments": %s }`, opts)
+	if _, err := inst.runQmpCommand(cmd); err != nil {
+		return errors.Wrapf(err, "Adding blkdebug node to drive %s", drive)
+	}
+	if _, err := inst.runHmpCommand(fmt.Sprintf(`qemu-io %s "reopen -o file=%s-fault"`, drive, drive)); err != nil {
+		return errors.Wrapf(err, "Inserting blkdebug node in drive %s", drive)
+	}
+	return nil
+}
+
+// setIOThrottle uses the qmp socket to set the I/O limits of a drive.
+func (inst *QemuInstance) setIOThrottle(drive string, iops, bps int64) error {
+	cmd := fmt.Sprintf(`{ "execute": "block_set_io_throttle", "arguments": { "device":"%s", "bps":%d, "bps_rd":0, "bps_wr":0, "iops":%d, "iops_rd":0, "iops_wr":0 } }`,
+		drive, bps, iops)
+	if _, err := inst.runQmpCommand(cmd); err != nil {
+		return errors.Wrapf(err, "Setting I/O throttle of drive %s", drive)
+	}
+	return nil
+}
+
+// addBlockdev uses the qmp socket to open a qcow2 image as a block node.
+func (inst *QemuInstance) addBlockdev(node, file string) error {
+	args, err := json.Marshal(map[string]interface{}{
+		"driver":    "qcow2",
+		"node-name": node,
+		"cache":     map[string]bool{"no-flush": true},
+		"file":      map[string]string{"driver": "file", "filename": file},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := inst.runQmpCommand(fmt.Sprintf(`{ "execute": "blockdev-add", "arguments": %s }`, args)); err != nil {
+		return errors.Wrapf(err, "Adding block node %s", node)
+	}
+	return nil
+}
+
+// deleteBlockdev uses the qmp socket to close a block node.
+func (inst *QemuInstance) deleteBlockdev(node string) error {
+	cmd := fmt.Sprintf(`{ "execute": "blockdev-del", "arguments": { "node-name":"%s" } }`, node)
+	if _, err := inst.runQmpCommand(cmd); err != nil {
+		return errors.Wrapf(err, "Deleting block node %s", node)
+	}
+	return nil
+}
+
+// addUserNetdev uses the qmp socket to add a user mode network backend.
+func (inst *QemuInstance) addUserNetdev(id string, restrict bool) error {
+	cmd := fmt.Sprintf(`{ "execute": "netdev_add", "arguments": { "type":"user", "id":"%s", "restrict":%t } }`, id, restrict)
+	if _, err := inst.runQmpCommand(cmd); err != nil {
+		return errors.Wrapf(err, "Adding netdev %s", id)
+	}
+	return nil
+}
+
+// deleteNetdev uses the qmp socket to remove a network backend.
+func (inst *QemuInstance) deleteNetdev(id string) error {
+	cmd := fmt.Sprintf(`{ "execute": "netdev_del", "arguments": { "id":"%s" } }`, id)
+	if _, err := inst.runQmpCommand(cmd); err != nil {
+		return errors.Wrapf(err, "Deleting netdev %s", id)
+	}
+	return nil
+}
+
+// addDevice uses the qmp socket to hotplug a device.
+func (inst *QemuInstance) addDevice(args map[string]interface{}) error {
+	buf, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	if _, err := inst.runQmpCommand(fmt.Sprintf(`{ "execute": "device_add", "arguments": %s }`, buf)); err != nil {
+		return errors.Wrapf(err, "Adding device %v", args["id"])
+	}
+	return nil
+}
+
+// deleteDeviceAndWait uses the qmp socket to unplug a device with an id,
+// and waits for the guest to release it, after which it is gone from
+// /machine/peripheral.
+func (inst *QemuInstance) deleteDeviceAndWait(id string, timeout time.Duration) error {
+	if err := inst.deleteBlockDevice(id); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := inst.runQmpCommand(`{ "execute": "qom-list", "arguments": { "path": "/machine/peripheral" } }`)
+		if err != nil {
+			return errors.Wrapf(err, "Running QMP qom-list command")
+		}
+		var devs QOMDev
+		if err = json.Unmarshal(out, &devs); err != nil {
+			return errors.Wrapf(err, "De-serializing QMP qom-list output")
+		}
+		found := false
+		for _, dev := range devs.Return {
+			found = found || dev.Name == id
+		}
+		if !found {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the guest to release device %s", id)
+		}
+		time.Sleep(time.Second)
+	}
+}
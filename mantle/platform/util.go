@@ -108,6 +108,21 @@ func StartReboot(m Machine) error {
 	return nil
 }
 
+// StartSoftReboot soft-reboots a machine, which restarts its userspace but
+// not the kernel. Afterwards use WaitForSoftReboot to wait for the machine
+// to be back.
+func StartSoftReboot(m Machine) error {
+	out, stderr, err := m.SSH("sudo systemctl soft-reboot")
+	if _, ok := err.(*ssh.ExitMissingError); ok {
+		// A terminated session is perfectly normal during soft-reboot.
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("issuing soft-reboot command failed: %s: %s: %s", out, err, stderr)
+	}
+	return nil
+}
+
 // RebootMachine will reboot a given machine, provided the machine's journal.
 func RebootMachine(m Machine, j *Journal) error {
 	bootId, err := GetMachineBootId(m)
@@ -191,6 +206,8 @@ func startMachineAfterBoot(m Machine, j *Journal) error {
 	if err := j.Start(m); err != nil {
 		return fmt.Errorf("machine %q failed to start journal: %v", m.ID(), err)
 	}
+	rconf := m.RuntimeConf()
+	rconf.machineEvent(MachineEventSSHReady, m.ID())
 	if err := CheckMachine(m); err != nil {
 		return fmt.Errorf("machine %q failed basic checks: %v", m.ID(), err)
 	}
@@ -199,6 +216,31 @@ func startMachineAfterBoot(m Machine, j *Journal) error {
 
 // StartMachine will start a given machine, provided the machine's journal.
 func StartMachine(m Machine, j *Journal) error {
+	return startMachine(m, j, func() error {
+		return startMachineAfterBoot(m, j)
+	})
+}
+
+// StartMachineWithAgent is StartMachine for machines with the kolet agent,
+// which may never start sshd or reach multi-user.target. It waits for the
+// agent to answer instead of SSH, and skips the basic machine checks. The
+// journal must come from a virtio pipe.
+func StartMachineWithAgent(m Machine, j *Journal, a *Agent) error {
+	return startMachine(m, j, func() error {
+		if _, _, err := a.Exec("true"); err != nil {
+			return fmt.Errorf("machine %q failed waiting for the agent: %v", m.ID(), err)
+		}
+		rconf := m.RuntimeConf()
+		rconf.machineEvent(MachineEventAgentReady, m.ID())
+		return nil
+	})
+}
+
+// startMachine waits for the machine to be started with wait, or for
+// Ignition to fail.
+func startMachine(m Machine, j *Journal, wait func() error) error {
+	rconf := m.RuntimeConf()
+	rconf.machineEvent(MachineEventCreated, m.ID())
 	errchan := make(chan error)
 	go func() {
 		err := m.IgnitionError()
@@ -213,7 +255,7 @@ func StartMachine(m Machine, j *Journal) error {
 		}
 	}()
 	go func() {
-		errchan <- startMachineAfterBoot(m, j)
+		errchan <- wait()
 	}()
 	return <-errchan
 }
@@ -327,3 +369,24 @@ func GenerateFakeKey() (string, error) {
 	}
 	return string(ssh.MarshalAuthorizedKey(sshKey)), nil
 }
+
+// FindKolet searches for the kolet binary for the given architecture next
+// to the current directory, the executable, and in /usr/lib/kola.
+func FindKolet(arch string) (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", errors.Wrapf(err, "finding path of executable")
+	}
+	for _, d := range []string{
+		".",
+		filepath.Dir(exePath),
+		filepath.Join(filepath.Dir(exePath), arch),
+		filepath.Join("/usr/lib/kola", arch),
+	} {
+		kolet := filepath.Join(d, "kolet")
+		if _, err := os.Stat(kolet); err == nil {
+			return kolet, nil
+		}
+	}
+	return "", fmt.Errorf("Unable to locate kolet binary for %s", arch)
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/coreos/coreos-assembler/mantle/system/exec"
 )
@@ -262,3 +263,13 @@ func getCgroupMemoryStatField(field string) (uint64, error) {
 	// Field not found; return 0 so callers degrade gracefully.
 	return 0, nil
 }
+
+// GetDiskAvailableMiB returns the space available to unprivileged users
+// on the filesystem containing path in MiB.
+func GetDiskAvailableMiB(path string) (uint, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, fmt.Errorf("statfs %s: %w", path, err)
+	}
+	return uint(st.Bavail * uint64(st.Bsize) / (1024 * 1024)), nil
+}